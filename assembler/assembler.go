@@ -0,0 +1,454 @@
+// Package assembler turns EVM assembly text into bytecode.
+//
+// A program is a list of statements separated by new lines or ';'. Comments
+// start with "//" or "#" and run to the end of the line.
+//
+//	.const SLOT 0x00           // Constant, usable as a PUSH operand
+//	.macro STORE slot value    // Macro, parameters are referred to with $name or @$name
+//	    PUSH $value
+//	    PUSH $slot
+//	    SSTORE
+//	.endmacro
+//	.macro SPIN                // Labels of a macro body are local to each expansion
+//	    again: JUMPDEST
+//	    PUSH @again
+//	    JUMP
+//	.endmacro
+//
+//	    PUSH @end              // Push the offset of a label, sized automatically
+//	    JUMP
+//	table: .data 0xdeadbeef    // Raw bytes copied into the code
+//	end: JUMPDEST              // Labels mark an offset, they do not emit a JUMPDEST
+//	    STORE SLOT 42
+//	    PUSH2 0x01             // Explicitly sized PUSH
+//	    STOP
+//
+// PUSH without a size uses the smallest PUSH holding its operand.
+package assembler
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/holiman/uint256"
+)
+
+// maxMacroDepth bounds nested macro expansion, which catches recursive macros
+const maxMacroDepth = 64
+
+// Assembly errors
+var (
+	ErrUnknownInstruction = errors.New("unknown instruction")
+	ErrUnknownLabel       = errors.New("unknown label")
+	ErrUnknownConstant    = errors.New("unknown constant")
+	ErrDuplicateLabel     = errors.New("duplicate label")
+	ErrDuplicateName      = errors.New("name already defined")
+	ErrInvalidValue       = errors.New("invalid value")
+	ErrValueTooLarge      = errors.New("value too large for the PUSH size")
+	ErrOperandCount       = errors.New("wrong number of operands")
+	ErrUnterminatedMacro  = errors.New("macro without .endmacro")
+	ErrMacroDepth         = errors.New("macro expansion too deep")
+)
+
+// statement is a single instruction, label or directive of the source
+type statement struct {
+	line   int
+	tokens []string
+}
+
+// macro is a named list of statements expanded in place when invoked
+type macro struct {
+	params []string
+	body   []statement
+}
+
+// item is a piece of the output code
+type item struct {
+	line  int
+	op    t.Opcode
+	size  int          // Immediate size of PUSH operations
+	auto  bool         // The PUSH size is chosen from its operand
+	value *uint256.Int // Operand of PUSH operations
+	label string       // Label whose offset is the operand of the PUSH
+	data  []byte       // Raw data, emitted instead of an operation
+}
+
+// length returns the number of bytes the item takes in the code
+func (it *item) length() int {
+	if it.data != nil {
+		return len(it.data)
+	}
+	return 1 + it.size
+}
+
+// assembler holds the state of the assembly of a program
+type assembler struct {
+	items     []*item
+	labels    map[string]int // Index of the item following each label
+	constants map[string]*uint256.Int
+	macros    map[string]*macro
+
+	expansions int // Number of macro expansions so far, numbers their local labels
+}
+
+// Assemble assembles the source into bytecode
+func Assemble(source string) ([]byte, error) {
+	a := &assembler{
+		labels:    make(map[string]int),
+		constants: make(map[string]*uint256.Int),
+		macros:    make(map[string]*macro),
+	}
+
+	statements := parse(source)
+	for i := 0; i < len(statements); i++ {
+		st := statements[i]
+		if st.tokens[0] != ".macro" {
+			if err := a.statement(st, 0); err != nil {
+				return nil, fmt.Errorf("line %d: %w", st.line, err)
+			}
+			continue
+		}
+
+		// Collect the macro body up to .endmacro
+		end := i + 1
+		for end < len(statements) && statements[end].tokens[0] != ".endmacro" {
+			end++
+		}
+		if end == len(statements) {
+			return nil, fmt.Errorf("line %d: %w", st.line, ErrUnterminatedMacro)
+		}
+		if err := a.defineMacro(st, statements[i+1:end]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", st.line, err)
+		}
+		i = end
+	}
+	return a.emit()
+}
+
+// parse splits the source into statements, dropping comments and blank lines
+func parse(source string) []statement {
+	var statements []statement
+	for number, line := range strings.Split(source, "\n") {
+		if index := strings.Index(line, "//"); index >= 0 {
+			line = line[:index]
+		}
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+		for _, part := range strings.Split(line, ";") {
+			if tokens := strings.Fields(part); len(tokens) > 0 {
+				statements = append(statements, statement{line: number + 1, tokens: tokens})
+			}
+		}
+	}
+	return statements
+}
+
+// defineMacro registers the macro declared by st with the given body
+func (a *assembler) defineMacro(st statement, body []statement) error {
+	if len(st.tokens) < 2 {
+		return ErrOperandCount
+	}
+	name := st.tokens[1]
+	if err := a.checkName(name); err != nil {
+		return err
+	}
+	a.macros[name] = &macro{params: st.tokens[2:], body: body}
+	return nil
+}
+
+// checkName ensures a constant or macro name does not shadow another name
+func (a *assembler) checkName(name string) error {
+	_, isConstant := a.constants[name]
+	_, isMacro := a.macros[name]
+	_, isOpcode := t.OpcodeByName(strings.ToUpper(name))
+	if isConstant || isMacro || isOpcode || strings.EqualFold(name, "PUSH") {
+		return fmt.Errorf("%w: %s", ErrDuplicateName, name)
+	}
+	return nil
+}
+
+// statement assembles a single statement, expanding macros up to depth
+func (a *assembler) statement(st statement, depth int) error {
+	tokens := st.tokens
+
+	// A leading "name:" defines a label at the current offset
+	if name, ok := strings.CutSuffix(tokens[0], ":"); ok {
+		if _, exists := a.labels[name]; exists {
+			return fmt.Errorf("%w: %s", ErrDuplicateLabel, name)
+		}
+		a.labels[name] = len(a.items)
+		if tokens = tokens[1:]; len(tokens) == 0 {
+			return nil
+		}
+	}
+
+	name, operands := tokens[0], tokens[1:]
+	switch name {
+	case ".const":
+		if len(operands) != 2 {
+			return ErrOperandCount
+		}
+		if err := a.checkName(operands[0]); err != nil {
+			return err
+		}
+		value, err := a.value(operands[1])
+		if err != nil {
+			return err
+		}
+		a.constants[operands[0]] = value
+		return nil
+
+	case ".data":
+		if len(operands) == 0 {
+			return ErrOperandCount
+		}
+		for _, operand := range operands {
+			data, err := decodeHex(operand)
+			if err != nil {
+				return err
+			}
+			a.items = append(a.items, &item{line: st.line, data: data})
+		}
+		return nil
+	}
+
+	if m, ok := a.macros[name]; ok {
+		return a.expand(m, operands, st.line, depth)
+	}
+	return a.instruction(st.line, strings.ToUpper(name), operands)
+}
+
+// expand assembles the body of a macro with its parameters replaced by the arguments
+func (a *assembler) expand(m *macro, args []string, line int, depth int) error {
+	if depth >= maxMacroDepth {
+		return ErrMacroDepth
+	}
+	if len(args) != len(m.params) {
+		return ErrOperandCount
+	}
+
+	// Labels defined in the body get a name of their own in every expansion, so
+	// that a macro can be invoked more than once. '#' starts a comment, so these
+	// names can not clash with the labels of the source.
+	a.expansions++
+	locals := make(map[string]string)
+	for _, st := range m.body {
+		if name, ok := strings.CutSuffix(st.tokens[0], ":"); ok {
+			locals[name] = fmt.Sprintf("%s#%d", name, a.expansions)
+		}
+	}
+
+	for _, st := range m.body {
+		tokens := make([]string, len(st.tokens))
+		// Index of the instruction or macro name, after the optional label
+		op := 0
+		if strings.HasSuffix(st.tokens[0], ":") {
+			op = 1
+		}
+		for i, token := range st.tokens {
+			if local, ok := localLabel(token, i > op, locals); ok {
+				tokens[i] = local
+				continue
+			}
+			for j, param := range m.params {
+				switch token {
+				case "$" + param:
+					token = args[j]
+				case "@$" + param:
+					token = "@" + args[j]
+				}
+			}
+			tokens[i] = token
+		}
+		// Errors are reported at the invocation, the body lines are of the definition
+		if err := a.statement(statement{line: line, tokens: tokens}, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localLabel renames a definition of or reference to a local label of a macro
+// expansion. Plain operands are references too, passed as arguments to other macros.
+func localLabel(token string, operand bool, locals map[string]string) (string, bool) {
+	if name, ok := strings.CutSuffix(token, ":"); ok {
+		if local, ok := locals[name]; ok {
+			return local + ":", true
+		}
+		return token, false
+	}
+	name, reference := strings.CutPrefix(token, "@")
+	local, ok := locals[name]
+	if !ok || !(reference || operand) {
+		return token, false
+	}
+	if reference {
+		return "@" + local, true
+	}
+	return local, true
+}
+
+// instruction assembles an opcode with its operands
+func (a *assembler) instruction(line int, name string, operands []string) error {
+	if name == "PUSH" {
+		if len(operands) != 1 {
+			return ErrOperandCount
+		}
+		it := &item{line: line, op: t.PUSH1, size: 1, auto: true}
+		if err := a.operand(it, operands[0]); err != nil {
+			return err
+		}
+		if it.value != nil {
+			it.size = byteLen(it.value)
+			it.op = t.PUSH1 + t.Opcode(it.size-1)
+		}
+		a.items = append(a.items, it)
+		return nil
+	}
+
+	op, ok := t.OpcodeByName(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownInstruction, name)
+	}
+	it := &item{line: line, op: op}
+	if op >= t.PUSH1 && op <= t.PUSH32 {
+		if len(operands) != 1 {
+			return ErrOperandCount
+		}
+		it.size = int(op-t.PUSH1) + 1
+		if err := a.operand(it, operands[0]); err != nil {
+			return err
+		}
+		if it.value != nil && byteLen(it.value) > it.size {
+			return ErrValueTooLarge
+		}
+	} else if len(operands) != 0 {
+		return ErrOperandCount
+	}
+	a.items = append(a.items, it)
+	return nil
+}
+
+// operand sets the PUSH operand of it, a label reference or a value
+func (a *assembler) operand(it *item, operand string) error {
+	if label, ok := strings.CutPrefix(operand, "@"); ok {
+		it.label = label
+		return nil
+	}
+	value, err := a.value(operand)
+	if err != nil {
+		return err
+	}
+	it.value = value
+	return nil
+}
+
+// value parses a decimal or hex number, or returns the value of a constant
+func (a *assembler) value(operand string) (*uint256.Int, error) {
+	if operand[0] >= '0' && operand[0] <= '9' {
+		if strings.HasPrefix(operand, "0x") || strings.HasPrefix(operand, "0X") {
+			data, err := decodeHex(operand)
+			if err != nil {
+				return nil, err
+			}
+			if len(data) > 32 {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidValue, operand)
+			}
+			return new(uint256.Int).SetBytes(data), nil
+		}
+		value, err := uint256.FromDecimal(operand)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidValue, operand)
+		}
+		return value, nil
+	}
+
+	value, ok := a.constants[operand]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownConstant, operand)
+	}
+	return value, nil
+}
+
+// emit resolves the labels and writes the code
+func (a *assembler) emit() ([]byte, error) {
+	// Growing a PUSH of a label moves the labels after it, which may require
+	// growing other PUSHes. Sizes only grow, so this ends.
+	offsets := make([]int, len(a.items)+1)
+	for changed := true; changed; {
+		changed = false
+		offset := 0
+		for i, it := range a.items {
+			offsets[i] = offset
+			offset += it.length()
+		}
+		offsets[len(a.items)] = offset
+
+		for _, it := range a.items {
+			if it.label == "" || !it.auto {
+				continue
+			}
+			index, ok := a.labels[it.label]
+			if !ok {
+				return nil, fmt.Errorf("line %d: %w: %s", it.line, ErrUnknownLabel, it.label)
+			}
+			if size := byteLen(uint256.NewInt(uint64(offsets[index]))); size > it.size {
+				it.size = size
+				it.op = t.PUSH1 + t.Opcode(size-1)
+				changed = true
+			}
+		}
+	}
+
+	code := make([]byte, 0, offsets[len(a.items)])
+	for _, it := range a.items {
+		if it.data != nil {
+			code = append(code, it.data...)
+			continue
+		}
+		code = append(code, byte(it.op))
+		if it.size == 0 {
+			continue
+		}
+
+		value := it.value
+		if it.label != "" {
+			index, ok := a.labels[it.label]
+			if !ok {
+				return nil, fmt.Errorf("line %d: %w: %s", it.line, ErrUnknownLabel, it.label)
+			}
+			value = uint256.NewInt(uint64(offsets[index]))
+			if byteLen(value) > it.size {
+				return nil, fmt.Errorf("line %d: %w", it.line, ErrValueTooLarge)
+			}
+		}
+		word := value.Bytes32()
+		code = append(code, word[32-it.size:]...)
+	}
+	return code, nil
+}
+
+// byteLen returns the number of bytes needed to push value, at least one
+func byteLen(value *uint256.Int) int {
+	return max(1, value.ByteLen())
+}
+
+// decodeHex decodes 0x prefixed hex, padding odd lengths with a leading zero
+func decodeHex(operand string) ([]byte, error) {
+	digits, ok := strings.CutPrefix(strings.ToLower(operand), "0x")
+	if !ok || digits == "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidValue, operand)
+	}
+	if len(digits)%2 == 1 {
+		digits = "0" + digits
+	}
+	data, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidValue, operand)
+	}
+	return data, nil
+}
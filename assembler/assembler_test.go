@@ -0,0 +1,129 @@
+package assembler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssemble(test *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{
+			name:     "Instructions and explicit pushes",
+			source:   "PUSH1 0x01\nPUSH1 0x02\nadd // comment\nPUSH2 1 # comment\nSTOP",
+			expected: "0x600160020161000100",
+		},
+		{
+			name:     "Automatic push sizing",
+			source:   "PUSH 0; PUSH 255; PUSH 256; PUSH 0x0000ff",
+			expected: "0x600060ff61010060ff",
+		},
+		{
+			name:     "Labels resolve forward and backward",
+			source:   "start: JUMPDEST; PUSH @end; JUMPI; PUSH @start; JUMP; end: JUMPDEST",
+			expected: "0x5b6007576000565b",
+		},
+		{
+			name:     "Explicitly sized label push",
+			source:   "PUSH2 @end; JUMP; end: JUMPDEST",
+			expected: "0x610004565b",
+		},
+		{
+			name:     "Constants",
+			source:   ".const SIZE 0x20\n.const OTHER SIZE\nPUSH SIZE\nPUSH32 OTHER",
+			expected: "0x6020" + "7f" + strings.Repeat("00", 31) + "20",
+		},
+		{
+			name: "Macros with parameters",
+			source: `.macro STORE slot value
+				PUSH $value
+				PUSH $slot
+				SSTORE
+			.endmacro
+			.macro GOTO dest; PUSH @$dest; JUMP; .endmacro
+			STORE 0 42
+			GOTO done
+			done: JUMPDEST`,
+			expected: "0x602a6000556008565b",
+		},
+		{
+			name: "Macro labels are local to each expansion",
+			source: `.macro SPIN
+				again: JUMPDEST
+				PUSH @again
+				JUMP
+			.endmacro
+			SPIN
+			SPIN`,
+			expected: "0x5b6000565b600456",
+		},
+		{
+			name: "Macro labels passed to other macros",
+			source: `.macro GOTO dest; PUSH @$dest; JUMP; .endmacro
+			.macro SPIN; again: JUMPDEST; GOTO again; .endmacro
+			SPIN
+			SPIN`,
+			expected: "0x5b6000565b600456",
+		},
+		{
+			name:     "Data sections",
+			source:   "PUSH @table; STOP; table: .data 0xdeadbeef 0xf",
+			expected: "0x600300deadbeef0f",
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			code, err := Assemble(tt.source)
+			require.NoError(test, err)
+			assert.Equal(test, common.FromHex(tt.expected), code)
+		})
+	}
+}
+
+func TestAssembleLabelGrowth(test *testing.T) {
+	// The label is past 255 bytes, so its PUSH needs two bytes, which moves it again
+	source := "PUSH @end\n.data 0x" + strings.Repeat("00", 254) + "\nend: JUMPDEST"
+	code, err := Assemble(source)
+	require.NoError(test, err)
+	assert.Equal(test, []byte{0x61, 0x01, 0x01}, code[:3])
+	assert.Equal(test, byte(0x5b), code[0x101])
+}
+
+func TestAssembleErrors(test *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		err    error
+		line   string
+	}{
+		{"Unknown instruction", "PUSH1 1\nFOO", ErrUnknownInstruction, "line 2"},
+		{"Unknown label", "PUSH @nowhere", ErrUnknownLabel, "line 1"},
+		{"Unknown constant", "PUSH SIZE", ErrUnknownConstant, "line 1"},
+		{"Duplicate label", "a: STOP\na: STOP", ErrDuplicateLabel, "line 2"},
+		{"Constant shadowing an opcode", ".const add 1", ErrDuplicateName, "line 1"},
+		{"Invalid value", "PUSH 0xzz", ErrInvalidValue, "line 1"},
+		{"Value too large", "PUSH1 0x0100", ErrValueTooLarge, "line 1"},
+		{"Label too large", "PUSH1 @end\n.data 0x" + strings.Repeat("00", 300) + "\nend: STOP", ErrValueTooLarge, "line 1"},
+		{"Missing operand", "PUSH2", ErrOperandCount, "line 1"},
+		{"Unexpected operand", "ADD 1", ErrOperandCount, "line 1"},
+		{"Unterminated macro", ".macro M\nSTOP", ErrUnterminatedMacro, "line 1"},
+		{"Macro label outside of its expansion", ".macro M; again: JUMPDEST; .endmacro\nM\nPUSH @again", ErrUnknownLabel, "line 3"},
+		{"Recursive macro", ".macro M; M; .endmacro\nM", ErrMacroDepth, "line 2"},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			_, err := Assemble(tt.source)
+			assert.ErrorIs(test, err, tt.err)
+			assert.ErrorContains(test, err, tt.line)
+		})
+	}
+}
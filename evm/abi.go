@@ -0,0 +1,388 @@
+package evm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Errors of the ABI encoding of calls
+var (
+	ErrUnknownMethod   = errors.New("unknown method")
+	ErrAmbiguousMethod = errors.New("ambiguous method, use its signature")
+	ErrArgumentCount   = errors.New("wrong number of arguments")
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// ABIRegistry holds the methods and events of the contract ABIs loaded, to encode
+// calls and decode their return values and logs
+type ABIRegistry struct {
+	methods map[[4]byte]abi.Method
+	events  map[common.Hash]abi.Event
+}
+
+// NewABIRegistry creates an empty ABIRegistry
+func NewABIRegistry() *ABIRegistry {
+	return &ABIRegistry{
+		methods: make(map[[4]byte]abi.Method),
+		events:  make(map[common.Hash]abi.Event),
+	}
+}
+
+// Add adds the methods and events of a contract ABI
+func (r *ABIRegistry) Add(contract *abi.ABI) {
+	for _, method := range contract.Methods {
+		r.methods[[4]byte(method.ID)] = method
+	}
+	for _, event := range contract.Events {
+		if !event.Anonymous {
+			r.events[event.ID] = event
+		}
+	}
+}
+
+// Load adds the contract ABI of a file, given bare or as the "abi" field of a
+// compiler artifact, and returns it
+func (r *ABIRegistry) Load(path string) (*abi.ABI, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	contract, err := ReadABI(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	r.Add(contract)
+	return contract, nil
+}
+
+// Method resolves a method given by its signature, e.g. "transfer(address,uint256)",
+// or by its name if the loaded ABIs hold a single method with that name. A
+// signature may give the return types, e.g. "balanceOf(address)(uint256)", which
+// are otherwise taken from the ABIs. The outputs of a method neither gives are nil.
+func (r *ABIRegistry) Method(signature string) (abi.Method, error) {
+	if !strings.Contains(signature, "(") {
+		var found []abi.Method
+		for _, method := range r.methods {
+			if method.RawName == signature {
+				found = append(found, method)
+			}
+		}
+		switch len(found) {
+		case 0:
+			return abi.Method{}, fmt.Errorf("%w: %s", ErrUnknownMethod, signature)
+		case 1:
+			return found[0], nil
+		}
+		return abi.Method{}, fmt.Errorf("%w: %s", ErrAmbiguousMethod, signature)
+	}
+
+	name, inputs, outputs, err := ParseMethodSignature(signature)
+	if err != nil {
+		return abi.Method{}, err
+	}
+	method := abi.NewMethod(name, name, abi.Function, "", false, false, inputs, outputs)
+	if known, ok := r.methods[[4]byte(method.ID)]; ok && outputs == nil {
+		return known, nil
+	}
+	return method, nil
+}
+
+// EncodeCall returns the call data of a method called with arguments given as text
+func EncodeCall(method abi.Method, args []string) ([]byte, error) {
+	if len(args) != len(method.Inputs) {
+		return nil, fmt.Errorf("%w: %s takes %d, got %d", ErrArgumentCount, method.Sig, len(method.Inputs), len(args))
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		value, err := ParseArgument(method.Inputs[i].Type, arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d of %s: %w", i, method.Sig, err)
+		}
+		values[i] = value
+	}
+	data, err := method.Inputs.Pack(values...)
+	if err != nil {
+		return nil, err
+	}
+	return append(common.CopyBytes(method.ID), data...), nil
+}
+
+// ParseArgument converts an argument given as text to the Go value of its ABI
+// type. Integers are decimal or 0x prefixed hex, bytes are hex, arrays are
+// written between brackets, e.g. "[1,2]", and tuples between parentheses.
+func ParseArgument(typ abi.Type, text string) (interface{}, error) {
+	value, err := parseValue(typ, strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q as %s: %v", ErrInvalidArgument, text, typ, err)
+	}
+	return value.Interface(), nil
+}
+
+// parseValue converts text to a reflect.Value of the Go type of typ
+func parseValue(typ abi.Type, text string) (reflect.Value, error) {
+	goType := typ.GetType()
+	switch typ.T {
+	case abi.AddressTy:
+		if !common.IsHexAddress(text) {
+			return reflect.Value{}, errors.New("not an address")
+		}
+		return reflect.ValueOf(common.HexToAddress(text)), nil
+
+	case abi.BoolTy:
+		b, err := strconv.ParseBool(text)
+		return reflect.ValueOf(b), err
+
+	case abi.StringTy:
+		if unquoted, err := strconv.Unquote(text); err == nil {
+			text = unquoted
+		}
+		return reflect.ValueOf(text), nil
+
+	case abi.IntTy, abi.UintTy:
+		n, err := parseInteger(text)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if goType == reflect.TypeOf(n) {
+			if !integerFits(n, typ) {
+				return reflect.Value{}, errors.New("out of range")
+			}
+			return reflect.ValueOf(n), nil
+		}
+		// Sizes up to 64 bits use the Go integer of the same size
+		value := reflect.New(goType).Elem()
+		if typ.T == abi.UintTy {
+			if n.Sign() < 0 || !n.IsUint64() || value.OverflowUint(n.Uint64()) {
+				return reflect.Value{}, errors.New("out of range")
+			}
+			value.SetUint(n.Uint64())
+		} else {
+			if !n.IsInt64() || value.OverflowInt(n.Int64()) {
+				return reflect.Value{}, errors.New("out of range")
+			}
+			value.SetInt(n.Int64())
+		}
+		return value, nil
+
+	case abi.BytesTy:
+		b, err := hexutil.Decode(text)
+		return reflect.ValueOf(b), err
+
+	case abi.FixedBytesTy:
+		b, err := hexutil.Decode(text)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if len(b) > typ.Size {
+			return reflect.Value{}, fmt.Errorf("longer than %d bytes", typ.Size)
+		}
+		value := reflect.New(goType).Elem()
+		reflect.Copy(value, reflect.ValueOf(b))
+		return value, nil
+
+	case abi.SliceTy, abi.ArrayTy:
+		if !strings.HasPrefix(text, "[") || !strings.HasSuffix(text, "]") {
+			return reflect.Value{}, errors.New("arrays are written between brackets")
+		}
+		var items []string
+		if inner := strings.TrimSpace(text[1 : len(text)-1]); inner != "" {
+			items = splitList(inner)
+		}
+		var value reflect.Value
+		if typ.T == abi.SliceTy {
+			value = reflect.MakeSlice(goType, len(items), len(items))
+		} else {
+			if len(items) != typ.Size {
+				return reflect.Value{}, fmt.Errorf("expected %d items", typ.Size)
+			}
+			value = reflect.New(goType).Elem()
+		}
+		for i, item := range items {
+			elem, err := parseValue(*typ.Elem, strings.TrimSpace(item))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			value.Index(i).Set(elem)
+		}
+		return value, nil
+
+	case abi.TupleTy:
+		if !strings.HasPrefix(text, "(") || !strings.HasSuffix(text, ")") {
+			return reflect.Value{}, errors.New("tuples are written between parentheses")
+		}
+		items := splitList(text[1 : len(text)-1])
+		if len(items) != len(typ.TupleElems) {
+			return reflect.Value{}, fmt.Errorf("expected %d fields", len(typ.TupleElems))
+		}
+		value := reflect.New(goType).Elem()
+		for i, item := range items {
+			field, err := parseValue(*typ.TupleElems[i], strings.TrimSpace(item))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			value.Field(i).Set(field)
+		}
+		return value, nil
+	}
+	return reflect.Value{}, errors.New("unsupported type")
+}
+
+// parseInteger parses a decimal or 0x prefixed hex integer, optionally negative
+func parseInteger(text string) (*big.Int, error) {
+	digits, base := strings.TrimPrefix(text, "-"), 10
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		digits, base = digits[2:], 16
+	}
+	n, ok := new(big.Int).SetString(digits, base)
+	if !ok || strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		return nil, errors.New("not an integer")
+	}
+	if strings.HasPrefix(text, "-") {
+		n.Neg(n)
+	}
+	return n, nil
+}
+
+// integerFits reports whether n is in the range of the integer type typ
+func integerFits(n *big.Int, typ abi.Type) bool {
+	if typ.T == abi.UintTy {
+		return n.Sign() >= 0 && n.BitLen() <= typ.Size
+	}
+	// Two's complement: -2^(size-1) <= n < 2^(size-1)
+	magnitude := n
+	if n.Sign() < 0 {
+		magnitude = new(big.Int).Add(n, big.NewInt(1))
+	}
+	return magnitude.BitLen() < typ.Size
+}
+
+// splitList splits a list on its top level commas, ignoring those inside nested
+// brackets, parentheses and quoted strings
+func splitList(list string) []string {
+	var items []string
+	depth, start, quoted := 0, 0, false
+	for i, c := range list {
+		switch {
+		case c == '"' && (i == 0 || list[i-1] != '\\'):
+			quoted = !quoted
+		case quoted:
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, list[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, list[start:])
+}
+
+// DecodedArg is a named value decoded from ABI encoded data
+type DecodedArg struct {
+	Name  string
+	Value interface{}
+}
+
+// String returns the argument as "name: value"
+func (arg DecodedArg) String() string {
+	if arg.Name == "" {
+		return FormatValue(arg.Value)
+	}
+	return arg.Name + ": " + FormatValue(arg.Value)
+}
+
+// DecodeOutputs decodes the return data of a method
+func DecodeOutputs(method abi.Method, data []byte) ([]DecodedArg, error) {
+	values, err := method.Outputs.Unpack(data)
+	if err != nil {
+		return nil, err
+	}
+	return namedValues(method.Outputs, values), nil
+}
+
+// namedValues pairs decoded values with the names of their arguments
+func namedValues(arguments abi.Arguments, values []interface{}) []DecodedArg {
+	decoded := make([]DecodedArg, len(values))
+	for i, value := range values {
+		decoded[i] = DecodedArg{Name: arguments[i].Name, Value: value}
+	}
+	return decoded
+}
+
+// DecodedEvent is a log decoded with the ABI of its event
+type DecodedEvent struct {
+	Address   common.Address
+	Name      string
+	Signature string
+	Args      []DecodedArg // In the order of the declaration of the event
+}
+
+// String returns the event with its arguments, e.g. "Transfer(from: 0x.., value: 1)".
+// Indexed arguments of dynamic types are the hashes of their values.
+func (event *DecodedEvent) String() string {
+	args := make([]string, len(event.Args))
+	for i, arg := range event.Args {
+		args[i] = arg.String()
+	}
+	return fmt.Sprintf("%s(%s)", event.Name, strings.Join(args, ", "))
+}
+
+// DecodeLog decodes a log emitted by one of the known events, nil if its event is
+// unknown or the log does not match it
+func (r *ABIRegistry) DecodeLog(log *t.Log) *DecodedEvent {
+	if len(log.Topics) == 0 {
+		return nil
+	}
+	event, ok := r.events[log.Topics[0]]
+	if !ok {
+		return nil
+	}
+
+	// The topics are decoded into a map, keyed by position as arguments may be unnamed
+	var indexed abi.Arguments
+	for i, input := range event.Inputs {
+		if input.Indexed {
+			input.Name = topicKey(i)
+			indexed = append(indexed, input)
+		}
+	}
+	if len(indexed) != len(log.Topics)-1 {
+		return nil
+	}
+	topics := make(map[string]interface{})
+	if err := abi.ParseTopicsIntoMap(topics, indexed, log.Topics[1:]); err != nil {
+		return nil
+	}
+	data, err := event.Inputs.NonIndexed().Unpack(log.Data)
+	if err != nil {
+		return nil
+	}
+
+	decoded := &DecodedEvent{Address: log.Address, Name: event.RawName, Signature: event.Sig}
+	for i, input := range event.Inputs {
+		arg := DecodedArg{Name: input.Name}
+		if input.Indexed {
+			arg.Value = topics[topicKey(i)]
+		} else {
+			arg.Value, data = data[0], data[1:]
+		}
+		decoded.Args = append(decoded.Args, arg)
+	}
+	return decoded
+}
+
+// topicKey is the key of the indexed argument at position i in the decoded topics
+func topicKey(i int) string {
+	return fmt.Sprintf("topic%d", i)
+}
@@ -0,0 +1,264 @@
+package evm
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/Manuelshub/go-EVM/assembler"
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTokenABI = `[
+	{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"balanceOf","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"balance","type":"uint256"}]},
+	{"type":"function","name":"mint","inputs":[{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"mint","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+func newTestRegistry(test *testing.T) *ABIRegistry {
+	contract, err := abi.JSON(strings.NewReader(testTokenABI))
+	require.NoError(test, err)
+	registry := NewABIRegistry()
+	registry.Add(&contract)
+	return registry
+}
+
+func TestParseArgument(test *testing.T) {
+	tests := []struct {
+		typ   string
+		text  string
+		value interface{}
+		err   bool
+	}{
+		{typ: "address", text: "0x00000000000000000000000000000000000000aa", value: common.HexToAddress("0xaa")},
+		{typ: "address", text: "0xaa", err: true},
+		{typ: "uint256", text: "100", value: big.NewInt(100)},
+		{typ: "uint256", text: "0xff", value: big.NewInt(255)},
+		{typ: "uint256", text: "-1", err: true},
+		{typ: "uint8", text: "255", value: uint8(255)},
+		{typ: "uint8", text: "256", err: true},
+		{typ: "int64", text: "-5", value: int64(-5)},
+		{typ: "int128", text: "-170141183460469231731687303715884105728", value: new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127))},
+		{typ: "int128", text: "170141183460469231731687303715884105728", err: true},
+		{typ: "uint256", text: "ten", err: true},
+		{typ: "bool", text: "true", value: true},
+		{typ: "string", text: `"hello, world"`, value: "hello, world"},
+		{typ: "string", text: "plain", value: "plain"},
+		{typ: "bytes", text: "0x0102", value: []byte{1, 2}},
+		{typ: "bytes4", text: "0xa9059cbb", value: [4]byte{0xa9, 0x05, 0x9c, 0xbb}},
+		{typ: "bytes2", text: "0x010203", err: true},
+		{typ: "uint256[]", text: "[1, 2, 3]", value: []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}},
+		{typ: "uint256[]", text: "[]", value: []*big.Int{}},
+		{typ: "uint8[2]", text: "[1,2]", value: [2]uint8{1, 2}},
+		{typ: "uint8[2]", text: "[1]", err: true},
+		{typ: "string[]", text: `["a,b", "c"]`, value: []string{"a,b", "c"}},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.typ+" "+tt.text, func(test *testing.T) {
+			typ, err := abi.NewType(tt.typ, "", nil)
+			require.NoError(test, err)
+			value, err := ParseArgument(typ, tt.text)
+			if tt.err {
+				assert.ErrorIs(test, err, ErrInvalidArgument)
+				return
+			}
+			require.NoError(test, err)
+			assert.Equal(test, tt.value, value)
+		})
+	}
+}
+
+func TestParseTupleArgument(test *testing.T) {
+	_, inputs, err := ParseSignature("f((uint256,address[]) item)")
+	require.NoError(test, err)
+	value, err := ParseArgument(inputs[0].Type, "(7, [0x00000000000000000000000000000000000000aa])")
+	require.NoError(test, err)
+
+	// The value is packed like a struct of the tuple type
+	packed, err := inputs.Pack(value)
+	require.NoError(test, err)
+	unpacked, err := inputs.Unpack(packed)
+	require.NoError(test, err)
+	assert.Equal(test, "(7, ["+common.HexToAddress("0xaa").Hex()+"])", FormatValue(unpacked[0]))
+}
+
+func TestEncodeCall(test *testing.T) {
+	registry := newTestRegistry(test)
+	to := "0x00000000000000000000000000000000000000aa"
+	expected := common.FromHex("0xa9059cbb" +
+		"00000000000000000000000000000000000000000000000000000000000000aa" +
+		"0000000000000000000000000000000000000000000000000000000000000064")
+
+	for _, signature := range []string{"transfer(address,uint256)", "transfer"} {
+		method, err := registry.Method(signature)
+		require.NoError(test, err)
+		data, err := EncodeCall(method, []string{to, "100"})
+		require.NoError(test, err)
+		assert.Equal(test, expected, data)
+		// The outputs come from the ABI
+		require.Len(test, method.Outputs, 1)
+		assert.Equal(test, "bool", method.Outputs[0].Type.String())
+	}
+
+	method, err := registry.Method("transfer(address,uint256)")
+	require.NoError(test, err)
+	_, err = EncodeCall(method, []string{to})
+	assert.ErrorIs(test, err, ErrArgumentCount)
+	_, err = EncodeCall(method, []string{to, "lots"})
+	assert.ErrorIs(test, err, ErrInvalidArgument)
+}
+
+func TestResolveMethod(test *testing.T) {
+	registry := newTestRegistry(test)
+
+	method, err := registry.Method("totalSupply()(uint256)")
+	require.NoError(test, err)
+	assert.Equal(test, "totalSupply()", method.Sig)
+	require.Len(test, method.Outputs, 1)
+
+	method, err = registry.Method("totalSupply()")
+	require.NoError(test, err)
+	assert.Nil(test, method.Outputs)
+
+	method, err = registry.Method("mint(uint256)")
+	require.NoError(test, err)
+	assert.Equal(test, "mint(uint256)", method.Sig)
+
+	_, err = registry.Method("mint")
+	assert.ErrorIs(test, err, ErrAmbiguousMethod)
+	_, err = registry.Method("burn")
+	assert.ErrorIs(test, err, ErrUnknownMethod)
+	_, err = registry.Method("burn(uint256")
+	assert.ErrorIs(test, err, ErrInvalidSignature)
+}
+
+func TestDecodeLog(test *testing.T) {
+	registry := newTestRegistry(test)
+	from := common.HexToAddress("0x01")
+	to := common.HexToAddress("0x02")
+	log := &t.Log{
+		Address: testReceiver,
+		Topics: []common.Hash{
+			crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")),
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data: common.LeftPadBytes([]byte{100}, 32),
+	}
+
+	event := registry.DecodeLog(log)
+	require.NotNil(test, event)
+	assert.Equal(test, "Transfer", event.Name)
+	assert.Equal(test, testReceiver, event.Address)
+	assert.Equal(test, "Transfer(from: "+from.Hex()+", to: "+to.Hex()+", value: 100)", event.String())
+
+	// Unknown events and logs not matching the event are not decoded
+	assert.Nil(test, registry.DecodeLog(&t.Log{Topics: []common.Hash{{0x01}}}))
+	assert.Nil(test, registry.DecodeLog(&t.Log{Topics: log.Topics[:2], Data: log.Data}))
+	assert.Nil(test, registry.DecodeLog(&t.Log{}))
+}
+
+func TestCallWithEncodedArguments(test *testing.T) {
+	// Returns the first argument plus one and logs Transfer(address(1), address(0), arg)
+	code, err := assembler.Assemble(`
+		PUSH 4; CALLDATALOAD; DUP1; PUSH 0; MSTORE
+		PUSH 0; PUSH 1
+		PUSH 0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef
+		PUSH 32; PUSH 0; LOG3
+		PUSH 1; ADD; PUSH 0; MSTORE
+		PUSH 32; PUSH 0; RETURN`)
+	require.NoError(test, err)
+
+	evm := newTestEVM()
+	evm.State.SetCode(testReceiver, code)
+	registry := newTestRegistry(test)
+	method, err := registry.Method("next(uint256)(uint256 value)")
+	require.NoError(test, err)
+	data, err := EncodeCall(method, []string{"41"})
+	require.NoError(test, err)
+
+	result, err := ApplyMessage(evm, &Message{
+		From:     testSender,
+		To:       &testReceiver,
+		GasLimit: 100_000,
+		GasPrice: uint256.NewInt(10),
+		Data:     data,
+	})
+	require.NoError(test, err)
+	require.NoError(test, result.Err)
+
+	values, err := DecodeOutputs(method, result.ReturnData)
+	require.NoError(test, err)
+	assert.Equal(test, []DecodedArg{{Name: "value", Value: big.NewInt(42)}}, values)
+	assert.Equal(test, "value: 42", values[0].String())
+
+	require.Len(test, result.Logs, 1)
+	event := registry.DecodeLog(result.Logs[0])
+	require.NotNil(test, event)
+	assert.Equal(test, "Transfer(from: "+common.HexToAddress("0x01").Hex()+", to: "+common.Address{}.Hex()+", value: 41)", event.String())
+}
+
+func TestCallCompiledContract(test *testing.T) {
+	// call() returns the block number, estimate() reverts with "block <number>",
+	// see testdata/README.md
+	registry := NewABIRegistry()
+	_, err := registry.Load("testdata/BlockOverridesTest.json")
+	require.NoError(test, err)
+	artifact, err := LoadArtifact("testdata/BlockOverridesTest.json", "")
+	require.NoError(test, err)
+	code, err := artifact.DeployedBytecode.Link(nil)
+	require.NoError(test, err)
+
+	evm := newTestEVM()
+	evm.Block.Number = 11
+	evm.State.SetCode(testReceiver, code)
+	send := func(nonce uint64, signature string) (abi.Method, *ExecutionResult) {
+		method, err := registry.Method(signature)
+		require.NoError(test, err)
+		data, err := EncodeCall(method, nil)
+		require.NoError(test, err)
+		result, err := ApplyMessage(evm, &Message{
+			From:     testSender,
+			To:       &testReceiver,
+			Nonce:    nonce,
+			GasLimit: 100_000,
+			GasPrice: uint256.NewInt(10),
+			Data:     data,
+		})
+		require.NoError(test, err)
+		return method, result
+	}
+
+	method, result := send(0, "call")
+	require.NoError(test, result.Err)
+	values, err := DecodeOutputs(method, result.ReturnData)
+	require.NoError(test, err)
+	assert.Equal(test, []DecodedArg{{Value: big.NewInt(11)}}, values)
+
+	_, result = send(1, "estimate()")
+	assert.ErrorIs(test, result.Err, ErrExecutionReverted)
+	require.NotNil(test, result.RevertReason)
+	assert.Equal(test, "block 11", result.RevertReason.String())
+
+	// An unknown selector falls through to the revert of the dispatcher
+	result, err = ApplyMessage(evm, &Message{
+		From:     testSender,
+		To:       &testReceiver,
+		Nonce:    2,
+		GasLimit: 100_000,
+		GasPrice: uint256.NewInt(10),
+		Data:     common.FromHex("0xdeadbeef"),
+	})
+	require.NoError(test, err)
+	assert.ErrorIs(test, result.Err, ErrExecutionReverted)
+	assert.Empty(test, result.ReturnData)
+}
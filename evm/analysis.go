@@ -0,0 +1,71 @@
+package evm
+
+import (
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+// JumpDests is a bitmap of the valid jump destinations of a code, one bit per byte
+type JumpDests []byte
+
+// AnalyzeJumpDests marks the JUMPDEST opcodes of code. Bytes of PUSH immediate
+// data equal to JUMPDEST are not opcodes, so they are skipped.
+func AnalyzeJumpDests(code []byte) JumpDests {
+	bitmap := make(JumpDests, (len(code)+7)/8)
+	for pc := 0; pc < len(code); pc++ {
+		op := t.Opcode(code[pc])
+		// The PUSH range is checked directly, JUMP uses this analysis so it cannot
+		// refer to InstructionTable
+		if op >= t.PUSH1 && op <= t.PUSH32 {
+			pc += int(op-t.PUSH1) + 1
+		} else if op == t.JUMPDEST {
+			bitmap[pc/8] |= 1 << (pc % 8)
+		}
+	}
+	return bitmap
+}
+
+// Has reports whether pc is a valid jump destination
+func (bitmap JumpDests) Has(pc uint64) bool {
+	if pc/8 >= uint64(len(bitmap)) {
+		return false
+	}
+	return bitmap[pc/8]&(1<<(pc%8)) != 0
+}
+
+// jumpDestAnalysis returns the analysis of code from the cache of the EVM,
+// analyzing it on first use. Code is immutable, so entries never go stale.
+func (evm *EVM) jumpDestAnalysis(code []byte) JumpDests {
+	hash := crypto.Keccak256Hash(code)
+	if bitmap, ok := evm.jumpDests[hash]; ok {
+		return bitmap
+	}
+	if evm.jumpDests == nil {
+		evm.jumpDests = make(map[common.Hash]JumpDests)
+	}
+	bitmap := AnalyzeJumpDests(code)
+	evm.jumpDests[hash] = bitmap
+	return bitmap
+}
+
+// validJumpDest reports whether dest is a JUMPDEST opcode of the code of the frame
+func (ctx *ExecutionContext) validJumpDest(dest *uint256.Int) bool {
+	if !dest.IsUint64() || dest.Uint64() >= uint64(len(ctx.ByteCode)) {
+		return false
+	}
+
+	// The frame may be reused with other code, the analysis is redone when the
+	// code changes
+	code := ctx.ByteCode
+	if len(code) != len(ctx.analyzedCode) || &code[0] != &ctx.analyzedCode[0] {
+		if ctx.EVM != nil {
+			ctx.jumpDests = ctx.EVM.jumpDestAnalysis(code)
+		} else {
+			ctx.jumpDests = AnalyzeJumpDests(code)
+		}
+		ctx.analyzedCode = code
+	}
+	return ctx.jumpDests.Has(dest.Uint64())
+}
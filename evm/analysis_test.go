@@ -0,0 +1,75 @@
+package evm
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeJumpDests(test *testing.T) {
+	tests := []struct {
+		name      string
+		code      string
+		jumpDests []uint64
+	}{
+		{"Jump destinations", "0x5b005b5b", []uint64{0, 2, 3}},
+		{"Push data is skipped", "0x615b5b5b", []uint64{3}},
+		{"Truncated push data", "0x5b7f5b5b", []uint64{0}},
+		{"Beyond the first byte of the bitmap", "0x00000000000000005b", []uint64{8}},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			code := common.FromHex(tt.code)
+			bitmap := AnalyzeJumpDests(code)
+
+			var jumpDests []uint64
+			for pc := uint64(0); pc < uint64(len(code))+8; pc++ {
+				if bitmap.Has(pc) {
+					jumpDests = append(jumpDests, pc)
+				}
+			}
+			assert.Equal(test, tt.jumpDests, jumpDests)
+		})
+	}
+}
+
+func TestJumpIntoPushData(test *testing.T) {
+	// PUSH1 3 JUMP PUSH1 0x5b: the destination holds 0x5b but is PUSH data
+	ctx := NewExecutionContext()
+	_, err := ctx.Run(common.FromHex("0x600356605b"))
+	assert.ErrorIs(test, err, ErrInvalidJump)
+
+	// PUSH1 1 PUSH1 4 JUMPI PUSH1 0x5b
+	ctx = NewExecutionContext()
+	_, err = ctx.Run(common.FromHex("0x6001600557605b"))
+	assert.ErrorIs(test, err, ErrInvalidJump)
+
+	// JUMPDEST PUSH9 2^64 JUMP: the destination must not wrap around to 0
+	ctx = NewExecutionContext()
+	_, err = ctx.Run(common.FromHex("0x5b6801000000000000000056"))
+	assert.ErrorIs(test, err, ErrInvalidJump)
+}
+
+func TestJumpDestCache(test *testing.T) {
+	evm := newTestEVM()
+	// PUSH1 4 JUMP INVALID JUMPDEST STOP
+	code := common.FromHex("0x600456fe5b00")
+	evm.State.SetCode(testReceiver, code)
+
+	for range 2 {
+		_, err := ApplyMessage(evm, &Message{
+			From:     testSender,
+			Nonce:    evm.State.GetNonce(testSender),
+			To:       &testReceiver,
+			GasLimit: 50_000,
+			GasPrice: evm.Block.BaseFee,
+		})
+		require.NoError(test, err)
+	}
+	assert.Len(test, evm.jumpDests, 1)
+	assert.True(test, evm.jumpDests[crypto.Keccak256Hash(code)].Has(4))
+}
@@ -0,0 +1,267 @@
+package evm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Errors of the loading and linking of compiler artifacts
+var (
+	ErrInvalidArtifact  = errors.New("invalid artifact")
+	ErrUnknownContract  = errors.New("unknown contract")
+	ErrUnlinkedLibrary  = errors.New("unlinked library")
+	ErrInvalidReference = errors.New("invalid link reference")
+)
+
+// LinkReference is the position of a library address placeholder in a bytecode,
+// in bytes
+type LinkReference struct {
+	Start  int `json:"start"`
+	Length int `json:"length"`
+}
+
+// LinkReferences locates the placeholders of a bytecode, by source file and library
+type LinkReferences map[string]map[string][]LinkReference
+
+// Bytecode is a code emitted by the compiler. Its hex may hold placeholders for
+// the addresses of libraries, which must be linked before it can be decoded.
+type Bytecode struct {
+	Object         string         `json:"object"` // Hex of the code, 0x prefixed or not
+	SourceMap      string         `json:"sourceMap"`
+	LinkReferences LinkReferences `json:"linkReferences"`
+}
+
+// Artifact is a compiled contract, as read from the output of solc, Foundry or Hardhat
+type Artifact struct {
+	ContractName     string
+	SourceName       string
+	ABI              *abi.ABI
+	Bytecode         Bytecode // Initcode, deploying the contract
+	DeployedBytecode Bytecode // Runtime code
+}
+
+// foundryArtifact is the layout of the artifacts written by Foundry in out/, and of
+// a contract in the output of solc --standard-json, under "evm"
+type foundryArtifact struct {
+	ABI              json.RawMessage `json:"abi"`
+	Bytecode         Bytecode        `json:"bytecode"`
+	DeployedBytecode Bytecode        `json:"deployedBytecode"`
+}
+
+// hardhatArtifact is the layout of the artifacts written by Hardhat in artifacts/
+type hardhatArtifact struct {
+	ContractName           string          `json:"contractName"`
+	SourceName             string          `json:"sourceName"`
+	ABI                    json.RawMessage `json:"abi"`
+	Bytecode               string          `json:"bytecode"`
+	DeployedBytecode       string          `json:"deployedBytecode"`
+	LinkReferences         LinkReferences  `json:"linkReferences"`
+	DeployedLinkReferences LinkReferences  `json:"deployedLinkReferences"`
+}
+
+// standardJSONOutput is the layout of the output of solc --standard-json
+type standardJSONOutput struct {
+	Contracts map[string]map[string]struct {
+		ABI json.RawMessage `json:"abi"`
+		EVM foundryArtifact `json:"evm"`
+	} `json:"contracts"`
+}
+
+// LoadArtifact reads a compiler artifact from a file, see ReadArtifact
+func LoadArtifact(path, contract string) (*Artifact, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	artifact, err := ReadArtifact(data, contract)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// Foundry names the artifacts after their contract, e.g. out/Token.sol/Token.json
+	if artifact.ContractName == "" {
+		artifact.ContractName = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return artifact, nil
+}
+
+// ReadArtifact parses a Foundry or Hardhat artifact, or the output of solc
+// --standard-json. The output of solc may hold several contracts, contract selects
+// one by name or as "file.sol:Name" and may be empty if there is a single one.
+func ReadArtifact(data []byte, contract string) (*Artifact, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArtifact, err)
+	}
+
+	var artifact *Artifact
+	var rawABI json.RawMessage
+	switch bytecode := bytes.TrimSpace(fields["bytecode"]); {
+	case fields["contracts"] != nil:
+		return readStandardJSON(data, contract)
+
+	case len(bytecode) > 0 && bytecode[0] == '"':
+		var hardhat hardhatArtifact
+		if err := json.Unmarshal(data, &hardhat); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArtifact, err)
+		}
+		artifact = &Artifact{
+			ContractName:     hardhat.ContractName,
+			SourceName:       hardhat.SourceName,
+			Bytecode:         Bytecode{Object: hardhat.Bytecode, LinkReferences: hardhat.LinkReferences},
+			DeployedBytecode: Bytecode{Object: hardhat.DeployedBytecode, LinkReferences: hardhat.DeployedLinkReferences},
+		}
+		rawABI = hardhat.ABI
+
+	case len(bytecode) > 0:
+		var foundry foundryArtifact
+		if err := json.Unmarshal(data, &foundry); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArtifact, err)
+		}
+		artifact = &Artifact{Bytecode: foundry.Bytecode, DeployedBytecode: foundry.DeployedBytecode}
+		rawABI = foundry.ABI
+
+	default:
+		return nil, fmt.Errorf("%w: no bytecode", ErrInvalidArtifact)
+	}
+
+	if err := artifact.setABI(rawABI); err != nil {
+		return nil, err
+	}
+	if artifact.ContractName == "" {
+		artifact.ContractName = contract
+	}
+	return artifact, nil
+}
+
+// readStandardJSON selects a contract of the output of solc --standard-json
+func readStandardJSON(data []byte, contract string) (*Artifact, error) {
+	var output standardJSONOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArtifact, err)
+	}
+
+	sourceName, contractName := "", contract
+	if i := strings.LastIndex(contract, ":"); i >= 0 {
+		sourceName, contractName = contract[:i], contract[i+1:]
+	}
+	var (
+		matches []*Artifact
+		names   []string
+	)
+	for source, contracts := range output.Contracts {
+		for name, compiled := range contracts {
+			names = append(names, source+":"+name)
+			if (contractName != "" && name != contractName) || (sourceName != "" && source != sourceName) {
+				continue
+			}
+			artifact := &Artifact{
+				ContractName:     name,
+				SourceName:       source,
+				Bytecode:         compiled.EVM.Bytecode,
+				DeployedBytecode: compiled.EVM.DeployedBytecode,
+			}
+			if err := artifact.setABI(compiled.ABI); err != nil {
+				return nil, err
+			}
+			matches = append(matches, artifact)
+		}
+	}
+
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+	sort.Strings(names)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w %q, the output holds %s", ErrUnknownContract, contract, strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("%w: select one of %s", ErrUnknownContract, strings.Join(names, ", "))
+}
+
+// setABI parses the ABI of the artifact, an artifact without one has an empty ABI
+func (artifact *Artifact) setABI(data json.RawMessage) error {
+	artifact.ABI = new(abi.ABI)
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	contract, err := ReadABI(data)
+	if err != nil {
+		return fmt.Errorf("%w: abi: %v", ErrInvalidArtifact, err)
+	}
+	artifact.ABI = contract
+	return nil
+}
+
+// Link replaces the placeholders of the libraries with their addresses and decodes
+// the code. Libraries are given by name or as "file.sol:Name".
+func (bytecode *Bytecode) Link(libraries map[string]common.Address) ([]byte, error) {
+	code := []byte(strings.TrimPrefix(bytecode.Object, "0x"))
+	var missing []string
+	for source, references := range bytecode.LinkReferences {
+		for library, positions := range references {
+			address, ok := libraries[source+":"+library]
+			if !ok {
+				address, ok = libraries[library]
+			}
+			if !ok {
+				missing = append(missing, source+":"+library)
+				continue
+			}
+			encoded := hex.EncodeToString(address.Bytes())
+			for _, position := range positions {
+				start, end := position.Start*2, (position.Start+position.Length)*2
+				if position.Length != common.AddressLength || start < 0 || end > len(code) {
+					return nil, fmt.Errorf("%w: %s at %d", ErrInvalidReference, library, position.Start)
+				}
+				copy(code[start:end], encoded)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%w: %s", ErrUnlinkedLibrary, strings.Join(missing, ", "))
+	}
+
+	decoded, err := hex.DecodeString(string(code))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArtifact, err)
+	}
+	return decoded, nil
+}
+
+// DeployData returns the data of the transaction deploying the contract: its linked
+// initcode followed by the ABI encoded arguments of its constructor, given as text
+func (artifact *Artifact) DeployData(libraries map[string]common.Address, args []string) ([]byte, error) {
+	code, err := artifact.Bytecode.Link(libraries)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) == 0 {
+		return nil, fmt.Errorf("%w: %s has no bytecode, it may be abstract or an interface", ErrInvalidArtifact, artifact.ContractName)
+	}
+
+	inputs := artifact.ABI.Constructor.Inputs
+	if len(args) != len(inputs) {
+		return nil, fmt.Errorf("%w: the constructor takes %d, got %d", ErrArgumentCount, len(inputs), len(args))
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		if values[i], err = ParseArgument(inputs[i].Type, arg); err != nil {
+			return nil, fmt.Errorf("argument %d of the constructor: %w", i, err)
+		}
+	}
+	encoded, err := inputs.Pack(values...)
+	if err != nil {
+		return nil, err
+	}
+	return append(code, encoded...), nil
+}
@@ -0,0 +1,309 @@
+package evm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Manuelshub/go-EVM/assembler"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testArtifactABI = `[
+	{"type":"constructor","inputs":[{"name":"value","type":"uint256"}]},
+	{"type":"function","name":"get","inputs":[],"outputs":[{"name":"lib","type":"address"},{"name":"value","type":"uint256"}]}
+]`
+
+// testLibraryPlaceholder stands for the address of the library in the test code
+var testLibraryPlaceholder = bytes.Repeat([]byte{0x11}, 20)
+
+// testArtifactCode returns the initcode and runtime code of a contract storing its
+// constructor argument, whose get() returns the address of a library and the
+// argument. The library address is a placeholder, at the returned offsets.
+func testArtifactCode(test *testing.T) (string, string, int, int) {
+	runtime, err := assembler.Assemble(fmt.Sprintf(`
+		PUSH20 0x%x; PUSH 0; MSTORE
+		PUSH 0; SLOAD; PUSH 32; MSTORE
+		PUSH 64; PUSH 0; RETURN`, testLibraryPlaceholder))
+	require.NoError(test, err)
+	initcode, err := assembler.Assemble(fmt.Sprintf(`
+		PUSH 32; PUSH 32; CODESIZE; SUB; PUSH 0; CODECOPY
+		PUSH 0; MLOAD; PUSH 0; SSTORE
+		PUSH %[1]d; PUSH @runtime; PUSH 0; CODECOPY
+		PUSH %[1]d; PUSH 0; RETURN
+		runtime: .data 0x%[2]x`, len(runtime), runtime))
+	require.NoError(test, err)
+
+	// solc writes placeholders in the hex of the code
+	placeholder := "__$" + strings.Repeat("ab", 17) + "$__"
+	link := func(code []byte) (string, int) {
+		offset := bytes.Index(code, testLibraryPlaceholder)
+		encoded := hex.EncodeToString(code)
+		return encoded[:offset*2] + placeholder + encoded[(offset+20)*2:], offset
+	}
+	initHex, initOffset := link(initcode)
+	runtimeHex, runtimeOffset := link(runtime)
+	return initHex, runtimeHex, initOffset, runtimeOffset
+}
+
+// testArtifacts returns the test contract in the formats of Foundry, Hardhat and solc
+func testArtifacts(test *testing.T) map[string]string {
+	initHex, runtimeHex, initOffset, runtimeOffset := testArtifactCode(test)
+	references := func(offset int) LinkReferences {
+		return LinkReferences{"src/Lib.sol": {"Lib": {{Start: offset, Length: 20}}}}
+	}
+	encode := func(value interface{}) string {
+		encoded, err := json.Marshal(value)
+		require.NoError(test, err)
+		return string(encoded)
+	}
+	abi := json.RawMessage(testArtifactABI)
+
+	foundry := encode(map[string]interface{}{
+		"abi":              abi,
+		"bytecode":         Bytecode{Object: "0x" + initHex, SourceMap: "0:10:0:-;", LinkReferences: references(initOffset)},
+		"deployedBytecode": Bytecode{Object: "0x" + runtimeHex, SourceMap: "0:10:0:-;", LinkReferences: references(runtimeOffset)},
+	})
+	hardhat := encode(map[string]interface{}{
+		"_format":                "hh-sol-artifact-1",
+		"contractName":           "Store",
+		"sourceName":             "src/Store.sol",
+		"abi":                    abi,
+		"bytecode":               "0x" + initHex,
+		"deployedBytecode":       "0x" + runtimeHex,
+		"linkReferences":         references(initOffset),
+		"deployedLinkReferences": references(runtimeOffset),
+	})
+	solc := encode(map[string]interface{}{
+		"contracts": map[string]interface{}{
+			"src/Store.sol": map[string]interface{}{
+				"Store": map[string]interface{}{
+					"abi": abi,
+					"evm": map[string]interface{}{
+						"bytecode":         Bytecode{Object: initHex, SourceMap: "0:10:0:-;", LinkReferences: references(initOffset)},
+						"deployedBytecode": Bytecode{Object: runtimeHex, LinkReferences: references(runtimeOffset)},
+					},
+				},
+			},
+			"src/Lib.sol": map[string]interface{}{
+				"Lib": map[string]interface{}{"abi": []interface{}{}, "evm": map[string]interface{}{"bytecode": Bytecode{Object: "00"}}},
+			},
+		},
+	})
+	return map[string]string{"Foundry": foundry, "Hardhat": hardhat, "solc": solc}
+}
+
+func TestReadArtifact(test *testing.T) {
+	artifacts := testArtifacts(test)
+	for _, format := range []string{"Foundry", "Hardhat", "solc"} {
+		test.Run(format, func(test *testing.T) {
+			contract := ""
+			if format == "solc" {
+				contract = "Store"
+			}
+			artifact, err := ReadArtifact([]byte(artifacts[format]), contract)
+			require.NoError(test, err)
+
+			assert.Contains(test, artifact.ABI.Methods, "get")
+			require.Len(test, artifact.ABI.Constructor.Inputs, 1)
+			assert.Contains(test, artifact.Bytecode.Object, "__$")
+			assert.Contains(test, artifact.DeployedBytecode.LinkReferences["src/Lib.sol"], "Lib")
+			if format != "Foundry" {
+				assert.Equal(test, "Store", artifact.ContractName)
+				assert.Equal(test, "src/Store.sol", artifact.SourceName)
+			}
+			if format != "Hardhat" {
+				assert.Equal(test, "0:10:0:-;", artifact.Bytecode.SourceMap)
+			}
+		})
+	}
+
+	test.Run("Contract selection", func(test *testing.T) {
+		solc := []byte(artifacts["solc"])
+		_, err := ReadArtifact(solc, "")
+		assert.ErrorIs(test, err, ErrUnknownContract)
+		_, err = ReadArtifact(solc, "Token")
+		assert.ErrorIs(test, err, ErrUnknownContract)
+		artifact, err := ReadArtifact(solc, "src/Lib.sol:Lib")
+		require.NoError(test, err)
+		assert.Equal(test, "Lib", artifact.ContractName)
+	})
+
+	test.Run("Invalid artifacts", func(test *testing.T) {
+		_, err := ReadArtifact([]byte(`{"abi": []}`), "")
+		assert.ErrorIs(test, err, ErrInvalidArtifact)
+		_, err = ReadArtifact([]byte(`[]`), "")
+		assert.ErrorIs(test, err, ErrInvalidArtifact)
+	})
+}
+
+func TestLinkBytecode(test *testing.T) {
+	_, runtimeHex, _, offset := testArtifactCode(test)
+	bytecode := Bytecode{Object: runtimeHex, LinkReferences: LinkReferences{"src/Lib.sol": {"Lib": {{Start: offset, Length: 20}}}}}
+	library := common.HexToAddress("0x00000000000000000000000000000000000001b5")
+
+	for _, name := range []string{"Lib", "src/Lib.sol:Lib"} {
+		code, err := bytecode.Link(map[string]common.Address{name: library})
+		require.NoError(test, err)
+		assert.Equal(test, library.Bytes(), code[offset:offset+20])
+	}
+
+	_, err := bytecode.Link(nil)
+	assert.ErrorIs(test, err, ErrUnlinkedLibrary)
+	assert.ErrorContains(test, err, "src/Lib.sol:Lib")
+
+	bytecode.LinkReferences["src/Lib.sol"]["Lib"][0].Start = len(runtimeHex)
+	_, err = bytecode.Link(map[string]common.Address{"Lib": library})
+	assert.ErrorIs(test, err, ErrInvalidReference)
+}
+
+func TestDeployArtifact(test *testing.T) {
+	path := filepath.Join(test.TempDir(), "Store.json")
+	require.NoError(test, os.WriteFile(path, []byte(testArtifacts(test)["Foundry"]), 0o644))
+	artifact, err := LoadArtifact(path, "")
+	require.NoError(test, err)
+	assert.Equal(test, "Store", artifact.ContractName)
+
+	library := common.HexToAddress("0x00000000000000000000000000000000000001b5")
+	libraries := map[string]common.Address{"Lib": library}
+	_, err = artifact.DeployData(libraries, nil)
+	assert.ErrorIs(test, err, ErrArgumentCount)
+	data, err := artifact.DeployData(libraries, []string{"1234"})
+	require.NoError(test, err)
+
+	evm := newTestEVM()
+	result, err := ApplyMessage(evm, &Message{
+		From:     testSender,
+		GasLimit: 200_000,
+		GasPrice: uint256.NewInt(10),
+		Data:     data,
+	})
+	require.NoError(test, err)
+	require.NoError(test, result.Err)
+
+	// The deployed code is the linked runtime code of the artifact
+	runtime, err := artifact.DeployedBytecode.Link(libraries)
+	require.NoError(test, err)
+	assert.Equal(test, runtime, evm.State.GetCode(result.ContractAddress))
+
+	registry := NewABIRegistry()
+	registry.Add(artifact.ABI)
+	method, err := registry.Method("get")
+	require.NoError(test, err)
+	input, err := EncodeCall(method, nil)
+	require.NoError(test, err)
+	result, err = ApplyMessage(evm, &Message{
+		From:     testSender,
+		To:       &result.ContractAddress,
+		Nonce:    1,
+		GasLimit: 100_000,
+		GasPrice: uint256.NewInt(10),
+		Data:     input,
+	})
+	require.NoError(test, err)
+	require.NoError(test, result.Err)
+	values, err := DecodeOutputs(method, result.ReturnData)
+	require.NoError(test, err)
+	assert.Equal(test, []DecodedArg{{Name: "lib", Value: library}, {Name: "value", Value: big.NewInt(1234)}}, values)
+}
+
+func TestDeployCompiledArtifact(test *testing.T) {
+	// Factory deploys a code with CREATE2 and a zero salt, C stores 100 in its
+	// constructor and self-destructs in destruct(), see testdata/README.md
+	factory, err := LoadArtifact("testdata/Factory.json", "")
+	require.NoError(test, err)
+	contract, err := LoadArtifact("testdata/C.json", "")
+	require.NoError(test, err)
+
+	data, err := factory.DeployData(nil, nil)
+	require.NoError(test, err)
+	evm := newTestEVM()
+	result, err := ApplyMessage(evm, &Message{
+		From:     testSender,
+		GasLimit: 500_000,
+		GasPrice: uint256.NewInt(10),
+		Data:     data,
+	})
+	require.NoError(test, err)
+	require.NoError(test, result.Err)
+	factoryAddress := result.ContractAddress
+	runtime, err := factory.DeployedBytecode.Link(nil)
+	require.NoError(test, err)
+	assert.Equal(test, runtime, evm.State.GetCode(factoryAddress))
+
+	registry := NewABIRegistry()
+	registry.Add(factory.ABI)
+	registry.Add(contract.ABI)
+	send := func(nonce uint64, to common.Address, signature string, args []string) *ExecutionResult {
+		method, err := registry.Method(signature)
+		require.NoError(test, err)
+		input, err := EncodeCall(method, args)
+		require.NoError(test, err)
+		result, err := ApplyMessage(evm, &Message{
+			From:     testSender,
+			To:       &to,
+			Nonce:    nonce,
+			Value:    uint256.NewInt(3),
+			GasLimit: 500_000,
+			GasPrice: uint256.NewInt(10),
+			Data:     input,
+		})
+		require.NoError(test, err)
+		return result
+	}
+
+	// The factory is not payable
+	result = send(1, factoryAddress, "deploy", []string{"0x"})
+	assert.ErrorIs(test, result.Err, ErrExecutionReverted)
+
+	initcode, err := contract.DeployData(nil, nil)
+	require.NoError(test, err)
+	method, err := registry.Method("deploy")
+	require.NoError(test, err)
+	input, err := EncodeCall(method, []string{"0x" + hex.EncodeToString(initcode)})
+	require.NoError(test, err)
+	result, err = ApplyMessage(evm, &Message{
+		From:     testSender,
+		To:       &factoryAddress,
+		Nonce:    2,
+		GasLimit: 500_000,
+		GasPrice: uint256.NewInt(10),
+		Data:     input,
+	})
+	require.NoError(test, err)
+	require.NoError(test, result.Err)
+
+	address := crypto.CreateAddress2(factoryAddress, common.Hash{}, crypto.Keccak256(initcode))
+	runtime, err = contract.DeployedBytecode.Link(nil)
+	require.NoError(test, err)
+	assert.Equal(test, runtime, evm.State.GetCode(address))
+	assert.Equal(test, common.BigToHash(big.NewInt(100)), evm.State.GetState(address, common.Hash{}))
+
+	// Deploying the same code again collides with the contract, and the factory reverts
+	result, err = ApplyMessage(evm, &Message{
+		From:     testSender,
+		To:       &factoryAddress,
+		Nonce:    3,
+		GasLimit: 500_000,
+		GasPrice: uint256.NewInt(10),
+		Data:     input,
+	})
+	require.NoError(test, err)
+	assert.ErrorIs(test, result.Err, ErrExecutionReverted)
+
+	// The contract was created by an earlier transaction, it only sends its balance
+	result = send(4, address, "destruct", nil)
+	require.NoError(test, result.Err)
+	assert.True(test, evm.State.GetBalance(address).IsZero())
+	assert.Equal(test, runtime, evm.State.GetCode(address))
+}
@@ -0,0 +1,118 @@
+package evm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// Errors of EIP-4844 blob transactions
+var (
+	ErrBlobTxCreate            = errors.New("blob transaction of type create")
+	ErrMissingBlobHashes       = errors.New("blob transaction missing blob hashes")
+	ErrTooManyBlobs            = errors.New("blob transaction has too many blobs")
+	ErrInvalidBlobHashVersion  = errors.New("blob transaction has invalid hash version")
+	ErrBlobFeeCapTooLow        = errors.New("max fee per blob gas less than block blob gas fee")
+	ErrBlobHashesOutsideBlobTx = errors.New("blob hashes set on a non-blob transaction")
+)
+
+// maxBlobFee is the highest blob fee, 2^256-1
+var maxBlobFee = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// CalcBlobFee computes the price of a unit of blob gas from the excess blob gas of
+// the block: MIN_BASE_FEE_PER_BLOB_GAS * e**(excessBlobGas / UPDATE_FRACTION).
+// The fee saturates at 2^256-1, which no transaction can pay, when the excess blob
+// gas is so large that it does not fit in 256 bits.
+func CalcBlobFee(excessBlobGas uint64) *uint256.Int {
+	fee := fakeExponential(
+		new(big.Int).SetUint64(t.BlobTxMinBlobGasprice),
+		new(big.Int).SetUint64(excessBlobGas),
+		new(big.Int).SetUint64(t.BlobTxBlobGaspriceUpdateFraction),
+		maxBlobFee,
+	)
+	return uint256.MustFromBig(fee)
+}
+
+// CalcExcessBlobGas computes the excess blob gas of a block from its parent
+func CalcExcessBlobGas(parentExcessBlobGas, parentBlobGasUsed uint64) uint64 {
+	excess := parentExcessBlobGas + parentBlobGasUsed
+	if excess < t.BlobTxTargetBlobGasPerBlock {
+		return 0
+	}
+	return excess - t.BlobTxTargetBlobGasPerBlock
+}
+
+// fakeExponential approximates factor * e ** (numerator / denominator) using a
+// Taylor expansion, as specified by EIP-4844. The result is capped at limit: the
+// terms are all positive, so the expansion stops as soon as the sum exceeds it
+// instead of running for as many terms as a huge exponent needs.
+func fakeExponential(factor, numerator, denominator, limit *big.Int) *big.Int {
+	var (
+		output = new(big.Int)
+		accum  = new(big.Int).Mul(factor, denominator)
+		bound  = new(big.Int).Mul(limit, denominator)
+	)
+	for i := 1; accum.Sign() > 0; i++ {
+		output.Add(output, accum)
+		if output.Cmp(bound) > 0 {
+			return new(big.Int).Set(limit)
+		}
+
+		accum.Mul(accum, numerator)
+		accum.Div(accum, denominator)
+		accum.Div(accum, big.NewInt(int64(i)))
+	}
+	return output.Div(output, denominator)
+}
+
+// BlobBaseFee returns the price of a unit of blob gas in this block
+func (block *BlockContext) BlobBaseFee() *uint256.Int {
+	return CalcBlobFee(block.ExcessBlobGas)
+}
+
+// blobGas returns the blob gas consumed by the message
+func (msg *Message) blobGas() uint64 {
+	return uint64(len(msg.BlobHashes)) * t.GasPerBlob
+}
+
+// checkBlobs validates the blob fields of a message against the block
+func (st *stateTransition) checkBlobs() error {
+	msg := st.msg
+	if msg.BlobGasFeeCap == nil {
+		if len(msg.BlobHashes) > 0 {
+			return fmt.Errorf("%w: address %v", ErrBlobHashesOutsideBlobTx, msg.From)
+		}
+		return nil
+	}
+
+	if msg.To == nil {
+		return fmt.Errorf("%w: address %v", ErrBlobTxCreate, msg.From)
+	}
+	if len(msg.BlobHashes) == 0 {
+		return fmt.Errorf("%w: address %v", ErrMissingBlobHashes, msg.From)
+	}
+	if uint64(len(msg.BlobHashes)) > t.BlobTxMaxBlobsPerBlock {
+		return fmt.Errorf("%w: have %d, max %d", ErrTooManyBlobs, len(msg.BlobHashes), t.BlobTxMaxBlobsPerBlock)
+	}
+	for i, hash := range msg.BlobHashes {
+		if hash[0] != t.BlobTxHashVersion {
+			return fmt.Errorf("%w: blob %d has version %#x", ErrInvalidBlobHashVersion, i, hash[0])
+		}
+	}
+	if blobFee := st.evm.Block.BlobBaseFee(); msg.BlobGasFeeCap.Lt(blobFee) {
+		return fmt.Errorf("%w: address %v blob fee cap %v, blob base fee %v", ErrBlobFeeCapTooLow, msg.From, msg.BlobGasFeeCap, blobFee)
+	}
+	return nil
+}
+
+// blobHash returns the versioned hash at index, or false if it is out of range
+func (tx *TxContext) blobHash(index *uint256.Int) (common.Hash, bool) {
+	if !index.IsUint64() || index.Uint64() >= uint64(len(tx.BlobHashes)) {
+		return common.Hash{}, false
+	}
+	return tx.BlobHashes[index.Uint64()], true
+}
@@ -0,0 +1,162 @@
+package evm
+
+import (
+	"errors"
+	"fmt"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+)
+
+// Receipt statuses
+const (
+	ReceiptStatusFailed     uint64 = 0 // The transaction execution failed
+	ReceiptStatusSuccessful uint64 = 1 // The transaction execution succeeded
+)
+
+// SystemCallGas is the gas available to system calls made by the block processor
+const SystemCallGas uint64 = 30000000
+
+var (
+	// SystemAddress is the sender of system calls
+	SystemAddress = common.HexToAddress("0xfffffffffffffffffffffffffffffffffffffffe")
+	// BeaconRootsAddress is the address of the beacon roots contract (EIP-4788)
+	BeaconRootsAddress = common.HexToAddress("0x000F3df6D732807Ef1319fB7B8bB8522d0Beac02")
+
+	// ErrBlobGasLimitReached is returned when the blobs of a block exceed the blob gas limit
+	ErrBlobGasLimitReached = errors.New("blob gas limit reached")
+)
+
+// Block is an ordered list of transactions to execute in the context of a block header
+type Block struct {
+	Context          BlockContext
+	Transactions     []*gethtypes.Transaction
+	Withdrawals      []*gethtypes.Withdrawal // EIP-4895 withdrawals, credited after the transactions
+	ParentBeaconRoot *common.Hash            // EIP-4788 root stored before the transactions, nil to skip
+}
+
+// Receipt is the outcome of a transaction included in a block
+type Receipt struct {
+	Type              uint8
+	Status            uint64
+	CumulativeGasUsed uint64
+	GasUsed           uint64
+	BlobGasUsed       uint64
+	EffectiveGasPrice *uint256.Int
+	Logs              []*t.Log
+	Bloom             gethtypes.Bloom
+	TxHash            common.Hash
+	TransactionIndex  uint
+	ContractAddress   common.Address // Address of the created contract, zero for calls
+}
+
+// BlockResult is the outcome of processing a block
+type BlockResult struct {
+	Receipts    []*Receipt
+	Logs        []*t.Log
+	Bloom       gethtypes.Bloom // Union of the blooms of all receipts
+	GasUsed     uint64
+	BlobGasUsed uint64
+	StateRoot   common.Hash // Root of the world state after the block
+}
+
+// CreateBloom computes the 2048-bit bloom filter of a list of logs, indexing the
+// address and the topics of every log
+func CreateBloom(logs []*t.Log) gethtypes.Bloom {
+	var bloom gethtypes.Bloom
+	for _, log := range logs {
+		bloom.Add(log.Address.Bytes())
+		for _, topic := range log.Topics {
+			bloom.Add(topic.Bytes())
+		}
+	}
+	return bloom
+}
+
+// ProcessBlock executes the transactions of a block in order against the state.
+// It stores the parent beacon root, runs every transaction while enforcing the
+// block gas and blob gas limits, and credits the withdrawals. An error is
+// returned if any transaction is invalid, in which case the block is invalid.
+func ProcessBlock(state *t.StateDB, block *Block) (*BlockResult, error) {
+	evm := NewEVM(block.Context, state)
+	result := &BlockResult{
+		Receipts: make([]*Receipt, 0, len(block.Transactions)),
+	}
+
+	if block.ParentBeaconRoot != nil {
+		ProcessBeaconBlockRoot(evm, *block.ParentBeaconRoot)
+	}
+
+	signer := block.Context.Signer()
+	for i, tx := range block.Transactions {
+		if gasLimit := block.Context.GasLimit; gasLimit != 0 && tx.Gas() > gasLimit-result.GasUsed {
+			return nil, fmt.Errorf("could not apply tx %d [%v]: %w: have %d, want %d", i, tx.Hash().Hex(), ErrGasLimitReached, gasLimit-result.GasUsed, tx.Gas())
+		}
+		if blobGas := tx.BlobGas(); result.BlobGasUsed+blobGas > t.BlobTxMaxBlobGasPerBlock {
+			return nil, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), ErrBlobGasLimitReached)
+		}
+
+		msg, err := TransactionToMessage(tx, signer)
+		if err != nil {
+			return nil, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+		}
+		execution, err := ApplyMessage(evm, msg)
+		if err != nil {
+			return nil, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+		}
+
+		result.GasUsed += execution.UsedGas
+		result.BlobGasUsed += execution.BlobGasUsed
+		receipt := &Receipt{
+			Type:              tx.Type(),
+			Status:            ReceiptStatusSuccessful,
+			CumulativeGasUsed: result.GasUsed,
+			GasUsed:           execution.UsedGas,
+			BlobGasUsed:       execution.BlobGasUsed,
+			EffectiveGasPrice: execution.EffectiveGasPrice,
+			Logs:              execution.Logs,
+			Bloom:             CreateBloom(execution.Logs),
+			TxHash:            tx.Hash(),
+			TransactionIndex:  uint(i),
+		}
+		if execution.Failed() {
+			receipt.Status = ReceiptStatusFailed
+		}
+		if tx.To() == nil {
+			receipt.ContractAddress = execution.ContractAddress
+		}
+
+		result.Receipts = append(result.Receipts, receipt)
+		result.Logs = append(result.Logs, execution.Logs...)
+	}
+	result.Bloom = CreateBloom(result.Logs)
+
+	ProcessWithdrawals(state, block.Withdrawals)
+	result.StateRoot = state.IntermediateRoot()
+	return result, nil
+}
+
+// ProcessBeaconBlockRoot stores the parent beacon block root in the beacon roots
+// contract with a system call (EIP-4788). Nothing happens if the contract is not deployed.
+func ProcessBeaconBlockRoot(evm *EVM, beaconRoot common.Hash) {
+	evm.Tx = TxContext{Origin: SystemAddress, GasPrice: uint256.NewInt(0)}
+	evm.State.ResetAccessList()
+	evm.State.AddAddressToAccessList(BeaconRootsAddress)
+
+	evm.Call(SystemAddress, BeaconRootsAddress, beaconRoot.Bytes(), SystemCallGas, uint256.NewInt(0))
+}
+
+// ProcessWithdrawals credits the withdrawn amounts, given in gwei, to their recipients (EIP-4895)
+func ProcessWithdrawals(state *t.StateDB, withdrawals []*gethtypes.Withdrawal) {
+	gwei := uint256.NewInt(1_000_000_000)
+	for _, withdrawal := range withdrawals {
+		// Crediting nothing would only create an empty account
+		if withdrawal.Amount == 0 {
+			continue
+		}
+		amount := new(uint256.Int).Mul(uint256.NewInt(withdrawal.Amount), gwei)
+		state.AddBalance(withdrawal.Address, amount)
+	}
+}
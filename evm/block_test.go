@@ -0,0 +1,78 @@
+package evm
+
+import (
+	"math/big"
+	"testing"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessBlock(test *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	signer := gethtypes.LatestSignerForChainID(big.NewInt(1))
+
+	newState := func() *t.StateDB {
+		state := t.NewStateDB()
+		state.SetBalance(sender, uint256.NewInt(1_000_000_000))
+		// LOG1(0, 0, topic 0x2a) then STOP
+		state.SetCode(testReceiver, common.FromHex("0x602a60006000a100"))
+		// The beacon roots stub stores 1 in slot 0 when called
+		state.SetCode(BeaconRootsAddress, common.FromHex("0x600160005500"))
+		return state
+	}
+	signTx := func(nonce uint64, gas uint64, to common.Address) *gethtypes.Transaction {
+		return gethtypes.MustSignNewTx(key, signer, &gethtypes.DynamicFeeTx{
+			ChainID:   big.NewInt(1),
+			Nonce:     nonce,
+			Gas:       gas,
+			GasFeeCap: big.NewInt(10),
+			GasTipCap: big.NewInt(1),
+			To:        &to,
+		})
+	}
+	context := BlockContext{ChainID: 1, Coinbase: testCoinbase, GasLimit: 100_000, BaseFee: uint256.NewInt(7)}
+
+	test.Run("Receipts, logs and withdrawals", func(test *testing.T) {
+		state := newState()
+		beaconRoot := common.HexToHash("0xbeac")
+		result, err := ProcessBlock(state, &Block{
+			Context:          context,
+			Transactions:     []*gethtypes.Transaction{signTx(0, 50_000, testReceiver), signTx(1, 21_000, testCoinbase)},
+			Withdrawals:      []*gethtypes.Withdrawal{{Address: testReceiver, Amount: 2}},
+			ParentBeaconRoot: &beaconRoot,
+		})
+		require.NoError(test, err)
+		require.Len(test, result.Receipts, 2)
+
+		first, second := result.Receipts[0], result.Receipts[1]
+		assert.Equal(test, ReceiptStatusSuccessful, first.Status)
+		assert.Equal(test, t.TxGas+3+3+3+t.GasLog+t.GasLogTopic, first.GasUsed)
+		assert.Equal(test, first.GasUsed+t.TxGas, second.CumulativeGasUsed)
+		assert.Equal(test, second.CumulativeGasUsed, result.GasUsed)
+		assert.Equal(test, state.IntermediateRoot(), result.StateRoot)
+
+		require.Len(test, first.Logs, 1)
+		assert.Equal(test, testReceiver, first.Logs[0].Address)
+		assert.True(test, first.Bloom.Test(testReceiver.Bytes()))
+		assert.True(test, result.Bloom.Test(common.BigToHash(big.NewInt(0x2a)).Bytes()))
+		assert.Empty(test, second.Logs)
+
+		assert.Equal(test, uint64(2_000_000_000), state.GetBalance(testReceiver).Uint64())
+		assert.Equal(test, common.BigToHash(common.Big1), state.GetState(BeaconRootsAddress, common.Hash{}))
+	})
+
+	test.Run("Block gas limit is enforced", func(test *testing.T) {
+		_, err := ProcessBlock(newState(), &Block{
+			Context:      context,
+			Transactions: []*gethtypes.Transaction{signTx(0, 90_000, testReceiver), signTx(1, 90_000, testReceiver)},
+		})
+		assert.ErrorIs(test, err, ErrGasLimitReached)
+	})
+}
@@ -0,0 +1,157 @@
+package evm
+
+import (
+	"errors"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/holiman/uint256"
+)
+
+// CallLog is a log emitted by a call frame. Position is the number of sub-calls
+// made by the frame before the log was emitted.
+type CallLog struct {
+	Address  common.Address `json:"address"`
+	Topics   []common.Hash  `json:"topics"`
+	Data     hexutil.Bytes  `json:"data"`
+	Position hexutil.Uint   `json:"position"`
+}
+
+// CallFrame is a node of the call tree built by the CallTracer, in the shape
+// of geth's callTracer
+type CallFrame struct {
+	Type         string          `json:"type"`
+	From         common.Address  `json:"from"`
+	Gas          hexutil.Uint64  `json:"gas"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	To           *common.Address `json:"to,omitempty"`
+	Input        hexutil.Bytes   `json:"input"`
+	Output       hexutil.Bytes   `json:"output,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	RevertReason string          `json:"revertReason,omitempty"`
+	Calls        []*CallFrame    `json:"calls,omitempty"`
+	Logs         []*CallLog      `json:"logs,omitempty"`
+	Value        *hexutil.Big    `json:"value,omitempty"`
+}
+
+// CallTracer records the frames of a transaction as a nested call tree
+type CallTracer struct {
+	withLogs bool
+	stack    []*CallFrame // Frames currently executing, the transaction frame first
+	root     *CallFrame
+	gasLimit uint64
+	errors   *ErrorDecoder // Decoder of the EVM running the transaction
+}
+
+// NewCallTracer creates a CallTracer. Logs are recorded in their frames if withLogs is set.
+func NewCallTracer(withLogs bool) *CallTracer {
+	return &CallTracer{withLogs: withLogs}
+}
+
+// Hooks returns the tracer hooks building the call tree
+func (ct *CallTracer) Hooks() *Hooks {
+	return &Hooks{
+		OnTxStart: ct.OnTxStart,
+		OnTxEnd:   ct.OnTxEnd,
+		OnEnter:   ct.OnEnter,
+		OnExit:    ct.OnExit,
+		OnLog:     ct.OnLog,
+	}
+}
+
+// Result returns the root frame of the last traced transaction, nil if nothing was traced
+func (ct *CallTracer) Result() *CallFrame {
+	return ct.root
+}
+
+// OnTxStart resets the tracer for a new transaction
+func (ct *CallTracer) OnTxStart(evm *EVM, msg *Message) {
+	ct.stack = nil
+	ct.root = nil
+	ct.gasLimit = msg.GasLimit
+	ct.errors = evm.Errors
+}
+
+// OnTxEnd reports the gas of the whole transaction in the root frame, including
+// the intrinsic gas and the refunds
+func (ct *CallTracer) OnTxEnd(result *ExecutionResult, err error) {
+	if ct.root == nil || result == nil {
+		return
+	}
+	ct.root.Gas = hexutil.Uint64(ct.gasLimit)
+	ct.root.GasUsed = hexutil.Uint64(result.UsedGas)
+	if ct.withLogs {
+		clearFailedLogs(ct.root, false)
+	}
+}
+
+// OnEnter opens a new frame as the last sub-call of the current frame
+func (ct *CallTracer) OnEnter(depth int, typ t.Opcode, from, to common.Address, input []byte, gas uint64, value *uint256.Int) {
+	frame := &CallFrame{
+		Type:  t.OpcodeName(typ),
+		From:  from,
+		To:    &to,
+		Input: common.CopyBytes(input),
+		Gas:   hexutil.Uint64(gas),
+	}
+	if typ != t.STATICCALL && value != nil {
+		frame.Value = (*hexutil.Big)(value.ToBig())
+	}
+
+	if len(ct.stack) == 0 {
+		ct.root = frame
+	} else {
+		parent := ct.stack[len(ct.stack)-1]
+		parent.Calls = append(parent.Calls, frame)
+	}
+	ct.stack = append(ct.stack, frame)
+}
+
+// OnExit closes the current frame with its result
+func (ct *CallTracer) OnExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	if len(ct.stack) == 0 {
+		return
+	}
+	frame := ct.stack[len(ct.stack)-1]
+	ct.stack = ct.stack[:len(ct.stack)-1]
+
+	frame.GasUsed = hexutil.Uint64(gasUsed)
+	if err == nil {
+		frame.Output = common.CopyBytes(output)
+		return
+	}
+	frame.Error = errorCause(err).Error()
+	if errors.Is(err, ErrExecutionReverted) {
+		frame.Output = common.CopyBytes(output)
+		if reason := ct.errors.Decode(output); reason != nil {
+			frame.RevertReason = reason.String()
+		}
+	}
+}
+
+// OnLog records a log in the current frame
+func (ct *CallTracer) OnLog(log *t.Log) {
+	if !ct.withLogs || len(ct.stack) == 0 {
+		return
+	}
+	frame := ct.stack[len(ct.stack)-1]
+	frame.Logs = append(frame.Logs, &CallLog{
+		Address:  log.Address,
+		Topics:   log.Topics,
+		Data:     log.Data,
+		Position: hexutil.Uint(len(frame.Calls)),
+	})
+}
+
+// clearFailedLogs removes the logs of failed frames and of all their sub-calls,
+// since their state changes were reverted
+func clearFailedLogs(frame *CallFrame, parentFailed bool) {
+	failed := parentFailed || frame.Error != ""
+	if failed {
+		frame.Logs = nil
+	}
+	for _, call := range frame.Calls {
+		clearFailedLogs(call, failed)
+	}
+}
@@ -0,0 +1,82 @@
+package evm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallTracer(test *testing.T) {
+	evm := newTestEVM()
+	callee := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	// LOG0(0, 0) then REVERT with Error("nope")
+	evm.State.SetCode(callee, common.FromHex("0x60006000a0"+"6308c379a0600052"+"6020602052"+"6004604052"+
+		"7f6e6f7065"+common.Bytes2Hex(make([]byte, 28))+"606052"+"6064601cfd"))
+	// LOG0(0, 0) then CALL(0xffff, callee, 0, 0, 0, 0, 0) then STOP
+	evm.State.SetCode(testReceiver, common.FromHex("0x60006000a0"+"60006000600060006000"+"73"+common.Bytes2Hex(callee.Bytes())+"61fffff100"))
+
+	tracer := NewCallTracer(true)
+	evm.Tracer = tracer.Hooks()
+	result, err := ApplyMessage(evm, &Message{
+		From:     testSender,
+		To:       &testReceiver,
+		Value:    uint256.NewInt(5),
+		Data:     []byte{0x01, 0x02},
+		GasLimit: 200_000,
+		GasPrice: uint256.NewInt(10),
+	})
+	require.NoError(test, err)
+	require.NoError(test, result.Err)
+
+	root := tracer.Result()
+	require.NotNil(test, root)
+	assert.Equal(test, "CALL", root.Type)
+	assert.Equal(test, testSender, root.From)
+	assert.Equal(test, testReceiver, *root.To)
+	assert.Equal(test, uint64(200_000), uint64(root.Gas))
+	assert.Equal(test, result.UsedGas, uint64(root.GasUsed))
+	assert.Equal(test, []byte{0x01, 0x02}, []byte(root.Input))
+	assert.Equal(test, int64(5), root.Value.ToInt().Int64())
+	assert.Empty(test, root.Error)
+	require.Len(test, root.Logs, 1)
+	assert.Equal(test, uint(0), uint(root.Logs[0].Position))
+
+	require.Len(test, root.Calls, 1)
+	call := root.Calls[0]
+	assert.Equal(test, callee, *call.To)
+	assert.Equal(test, testReceiver, call.From)
+	assert.Equal(test, ErrExecutionReverted.Error(), call.Error)
+	assert.Equal(test, "nope", call.RevertReason)
+	assert.Len(test, call.Output, 100)
+	// The logs of the reverted frame are dropped
+	assert.Empty(test, call.Logs)
+
+	encoded, err := json.Marshal(root)
+	require.NoError(test, err)
+	assert.Contains(test, string(encoded), `"revertReason":"nope"`)
+	assert.Contains(test, string(encoded), `"calls":[`)
+}
+
+func TestCallTracerCreate(test *testing.T) {
+	evm := newTestEVM()
+	tracer := NewCallTracer(false)
+	evm.Tracer = tracer.Hooks()
+	// MSTORE8(0, 1) then RETURN(0, 1) deploys the runtime code 0x01
+	result, err := ApplyMessage(evm, &Message{
+		From:     testSender,
+		Data:     common.FromHex("0x600160005360016000f3"),
+		GasLimit: 100_000,
+		GasPrice: uint256.NewInt(10),
+	})
+	require.NoError(test, err)
+	require.NoError(test, result.Err)
+
+	root := tracer.Result()
+	require.NotNil(test, root)
+	assert.Equal(test, "CREATE", root.Type)
+	assert.Equal(test, result.ContractAddress, *root.To)
+}
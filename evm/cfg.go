@@ -0,0 +1,312 @@
+package evm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/holiman/uint256"
+)
+
+// maxBlockVisits bounds the number of entry stacks simulated for each block,
+// so that loops pushing new values on every iteration end
+const maxBlockVisits = 64
+
+// Kinds of control flow edges
+const (
+	EdgeFallthrough = "fallthrough" // Execution continues with the next block
+	EdgeJump        = "jump"        // Target of a JUMP
+	EdgeBranch      = "branch"      // Target of a taken JUMPI
+)
+
+// BasicBlock is a sequence of instructions always executed from its first one
+// to its last one
+type BasicBlock struct {
+	Start        uint64 // Program counter of the first instruction
+	End          uint64 // Program counter of the last instruction
+	Instructions []DisassembledInstruction
+	Reachable    bool // The block is reached from the entry of the code
+	Unresolved   bool // The block ends with a jump whose target is not statically known
+}
+
+// CFGEdge is a transition between two blocks
+type CFGEdge struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// CFG is the control flow graph of a code
+type CFG struct {
+	Blocks []*BasicBlock // Sorted by program counter
+	Edges  []CFGEdge
+
+	jumpDests JumpDests
+	blocks    map[uint64]*BasicBlock
+	edges     map[CFGEdge]bool
+}
+
+// abstractValue is a stack item of the simulation, nil when not statically known
+type abstractValue = *uint256.Int
+
+// abstractStack is the stack of the simulation. Items below its bottom are unknown.
+type abstractStack []abstractValue
+
+// BuildCFG splits the code into basic blocks and connects them, resolving the
+// jump targets pushed on the stack by simulating the execution of the blocks
+func BuildCFG(bytecode []byte) *CFG {
+	cfg := &CFG{blocks: make(map[uint64]*BasicBlock), edges: make(map[CFGEdge]bool)}
+
+	program := Disassemble(bytecode)
+	cfg.jumpDests = program.JumpDests
+
+	var block *BasicBlock
+	for _, instruction := range program.Instructions {
+		if block == nil || instruction.Opcode == t.JUMPDEST {
+			block = &BasicBlock{Start: instruction.PC}
+			cfg.Blocks = append(cfg.Blocks, block)
+			cfg.blocks[block.Start] = block
+		}
+		block.Instructions = append(block.Instructions, instruction)
+		block.End = instruction.PC
+		if endsBlock(instruction.Opcode) {
+			block = nil
+		}
+	}
+
+	if len(cfg.Blocks) > 0 {
+		cfg.simulate()
+	}
+	sort.Slice(cfg.Edges, func(i, j int) bool {
+		if cfg.Edges[i].From != cfg.Edges[j].From {
+			return cfg.Edges[i].From < cfg.Edges[j].From
+		}
+		return cfg.Edges[i].To < cfg.Edges[j].To
+	})
+	return cfg
+}
+
+// endsBlock reports whether the control flow may leave the block after op
+func endsBlock(op t.Opcode) bool {
+	switch op {
+	case t.JUMP, t.JUMPI, t.STOP, t.RETURN, t.REVERT, t.SELFDESTRUCT, t.INVALID:
+		return true
+	}
+	// Undefined opcodes halt the execution. Whether an opcode is defined is a
+	// property of the instruction set, not of what the interpreter implements.
+	return t.OpcodeName(op) == ""
+}
+
+// Block returns the block starting at pc, nil if there is none
+func (cfg *CFG) Block(pc uint64) *BasicBlock {
+	return cfg.blocks[pc]
+}
+
+// addEdge records an edge once
+func (cfg *CFG) addEdge(from, to uint64, kind string) {
+	edge := CFGEdge{From: from, To: to, Kind: kind}
+	if !cfg.edges[edge] {
+		cfg.edges[edge] = true
+		cfg.Edges = append(cfg.Edges, edge)
+	}
+}
+
+// isJumpDest reports whether pc is a valid jump destination
+func (cfg *CFG) isJumpDest(pc uint64) bool {
+	return cfg.jumpDests.Has(pc)
+}
+
+// simulate walks the blocks from the entry of the code, once for every
+// different entry stack, and records the edges taken
+func (cfg *CFG) simulate() {
+	type state struct {
+		block *BasicBlock
+		stack abstractStack
+	}
+	visits := make(map[uint64]map[string]bool)
+	work := []state{{block: cfg.Blocks[0]}}
+
+	for len(work) > 0 {
+		current := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		block := current.block
+		key := current.stack.key()
+		if visits[block.Start] == nil {
+			visits[block.Start] = make(map[string]bool)
+		}
+		if visits[block.Start][key] || len(visits[block.Start]) >= maxBlockVisits {
+			continue
+		}
+		visits[block.Start][key] = true
+		block.Reachable = true
+
+		stack := current.stack.execute(block.Instructions[:len(block.Instructions)-1])
+		last := block.Instructions[len(block.Instructions)-1]
+		next := last.PC + 1 + uint64(len(last.Immediate))
+
+		follow := func(to uint64, kind string, stack abstractStack) {
+			cfg.addEdge(block.Start, to, kind)
+			work = append(work, state{block: cfg.blocks[to], stack: stack})
+		}
+		jump := func(target abstractValue, kind string, stack abstractStack) {
+			if target == nil || !target.IsUint64() {
+				block.Unresolved = true
+			} else if cfg.isJumpDest(target.Uint64()) {
+				follow(target.Uint64(), kind, stack)
+			}
+		}
+
+		switch {
+		case last.Opcode == t.JUMP:
+			target, stack := stack.pop()
+			jump(target, EdgeJump, stack)
+		case last.Opcode == t.JUMPI:
+			target, stack := stack.pop()
+			_, stack = stack.pop()
+			jump(target, EdgeBranch, stack)
+			if cfg.blocks[next] != nil {
+				follow(next, EdgeFallthrough, stack)
+			}
+		case !endsBlock(last.Opcode):
+			// The block was split before a JUMPDEST
+			if cfg.blocks[next] != nil {
+				follow(next, EdgeFallthrough, stack.execute([]DisassembledInstruction{last}))
+			}
+		}
+	}
+}
+
+// execute returns the stack after running the instructions. Only PUSH, DUP and
+// SWAP are simulated, the results of other operations are unknown.
+func (stack abstractStack) execute(instructions []DisassembledInstruction) abstractStack {
+	stack = append(abstractStack(nil), stack...)
+	for _, instruction := range instructions {
+		op := instruction.Opcode
+		switch {
+		case op >= t.PUSH1 && op <= t.PUSH32:
+			stack = append(stack, new(uint256.Int).SetBytes(instruction.Immediate))
+		case op >= t.DUP1 && op <= t.DUP16:
+			n := int(op-t.DUP1) + 1
+			stack = stack.grow(n)
+			stack = append(stack, stack[len(stack)-n])
+		case op >= t.SWAP1 && op <= t.SWAP16:
+			n := int(op-t.SWAP1) + 1
+			stack = stack.grow(n + 1)
+			top := len(stack) - 1
+			stack[top], stack[top-n] = stack[top-n], stack[top]
+		default:
+			info := InstructionTable[op]
+			for range info.StackPops {
+				_, stack = stack.pop()
+			}
+			for range info.StackPushs {
+				stack = append(stack, nil)
+			}
+		}
+		if len(stack) > t.MAX_STACK_SIZE {
+			stack = stack[len(stack)-t.MAX_STACK_SIZE:]
+		}
+	}
+	return stack
+}
+
+// grow adds unknown items at the bottom so that the stack holds at least n items
+func (stack abstractStack) grow(n int) abstractStack {
+	if len(stack) >= n {
+		return stack
+	}
+	return append(make(abstractStack, n-len(stack)), stack...)
+}
+
+// pop removes the top item, which is unknown if the stack is empty
+func (stack abstractStack) pop() (abstractValue, abstractStack) {
+	if len(stack) == 0 {
+		return nil, stack
+	}
+	return stack[len(stack)-1], stack[:len(stack)-1]
+}
+
+// key identifies the stack for the detection of already simulated states
+func (stack abstractStack) key() string {
+	var b strings.Builder
+	for _, value := range stack {
+		if value == nil {
+			b.WriteString("?,")
+		} else {
+			b.WriteString(value.Hex() + ",")
+		}
+	}
+	return b.String()
+}
+
+// WriteDot writes the graph in the Graphviz DOT format. Unreachable blocks are
+// grey and blocks ending with an unresolved jump are red.
+func (cfg *CFG) WriteDot(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph cfg {\n\tnode [shape=box fontname=\"monospace\"];\n")
+	for _, block := range cfg.Blocks {
+		var label strings.Builder
+		for _, instruction := range block.Instructions {
+			fmt.Fprintf(&label, "%04x: %s\\l", instruction.PC, instruction)
+		}
+		attributes := ""
+		if !block.Reachable {
+			attributes = " color=grey fontcolor=grey"
+		} else if block.Unresolved {
+			attributes = " color=red"
+		}
+		fmt.Fprintf(&b, "\tb%d [label=\"%s\"%s];\n", block.Start, label.String(), attributes)
+	}
+	for _, edge := range cfg.Edges {
+		style := ""
+		if edge.Kind == EdgeFallthrough {
+			style = " style=dashed"
+		}
+		fmt.Fprintf(&b, "\tb%d -> b%d [label=\"%s\"%s];\n", edge.From, edge.To, edge.Kind, style)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cfgBlockJSON is the JSON representation of a basic block
+type cfgBlockJSON struct {
+	Start        uint64   `json:"start"`
+	End          uint64   `json:"end"`
+	Instructions []string `json:"instructions"`
+	Reachable    bool     `json:"reachable"`
+	Unresolved   bool     `json:"unresolved,omitempty"`
+}
+
+// WriteJSON writes the blocks and the edges of the graph as JSON
+func (cfg *CFG) WriteJSON(w io.Writer) error {
+	output := struct {
+		Blocks []cfgBlockJSON `json:"blocks"`
+		Edges  []CFGEdge      `json:"edges"`
+	}{Blocks: []cfgBlockJSON{}, Edges: cfg.Edges}
+	if output.Edges == nil {
+		output.Edges = []CFGEdge{}
+	}
+
+	for _, block := range cfg.Blocks {
+		instructions := make([]string, 0, len(block.Instructions))
+		for _, instruction := range block.Instructions {
+			instructions = append(instructions, fmt.Sprintf("%04x: %s", instruction.PC, instruction))
+		}
+		output.Blocks = append(output.Blocks, cfgBlockJSON{
+			Start:        block.Start,
+			End:          block.End,
+			Instructions: instructions,
+			Reachable:    block.Reachable,
+			Unresolved:   block.Unresolved,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
@@ -0,0 +1,134 @@
+package evm
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/Manuelshub/go-EVM/assembler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildCFG(test *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		blocks      []uint64
+		edges       []CFGEdge
+		unresolved  []uint64
+		unreachable []uint64
+	}{
+		{
+			name:   "Conditional branch",
+			source: "PUSH 1; PUSH @a; JUMPI; STOP; a: JUMPDEST; STOP",
+			blocks: []uint64{0, 5, 6},
+			edges: []CFGEdge{
+				{From: 0, To: 5, Kind: EdgeFallthrough},
+				{From: 0, To: 6, Kind: EdgeBranch},
+			},
+		},
+		{
+			name: "Return addresses are tracked through the stack",
+			source: `PUSH @ret1; PUSH @fn; JUMP
+				ret1: JUMPDEST; PUSH @ret2; PUSH @fn; JUMP
+				ret2: JUMPDEST; STOP
+				fn: JUMPDEST; DUP1; SWAP1; JUMP`,
+			blocks: []uint64{0, 5, 11, 13},
+			edges: []CFGEdge{
+				{From: 0, To: 13, Kind: EdgeJump},
+				{From: 5, To: 13, Kind: EdgeJump},
+				{From: 13, To: 5, Kind: EdgeJump},
+				{From: 13, To: 11, Kind: EdgeJump},
+			},
+		},
+		{
+			name:   "Falls through into a jump destination",
+			source: "PUSH 1; a: JUMPDEST; PUSH @a; JUMP",
+			blocks: []uint64{0, 2},
+			edges: []CFGEdge{
+				{From: 0, To: 2, Kind: EdgeFallthrough},
+				{From: 2, To: 2, Kind: EdgeJump},
+			},
+		},
+		{
+			name:        "Jump target from the environment",
+			source:      "CALLVALUE; JUMP; JUMPDEST; STOP",
+			blocks:      []uint64{0, 2},
+			unresolved:  []uint64{0},
+			unreachable: []uint64{2},
+		},
+		{
+			name:   "Solidity constructor prologue",
+			source: "PUSH 0x80; PUSH 0x40; MSTORE; CALLVALUE; DUP1; ISZERO; PUSH @a; JUMPI; PUSH0; PUSH0; REVERT; a: JUMPDEST; POP; STOP",
+			blocks: []uint64{0, 11, 14},
+			edges: []CFGEdge{
+				{From: 0, To: 11, Kind: EdgeFallthrough},
+				{From: 0, To: 14, Kind: EdgeBranch},
+			},
+		},
+		{
+			name:        "INVALID halts",
+			source:      "INVALID; JUMPDEST; STOP",
+			blocks:      []uint64{0, 1},
+			unreachable: []uint64{1},
+		},
+		{
+			name:        "Jump into push data is not followed",
+			source:      "PUSH 4; JUMP; PUSH1 0x5b; STOP",
+			blocks:      []uint64{0, 3},
+			unreachable: []uint64{3},
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			code, err := assembler.Assemble(tt.source)
+			require.NoError(test, err)
+			cfg := BuildCFG(code)
+
+			var starts, unresolved, unreachable []uint64
+			for _, block := range cfg.Blocks {
+				starts = append(starts, block.Start)
+				if block.Unresolved {
+					unresolved = append(unresolved, block.Start)
+				}
+				if !block.Reachable {
+					unreachable = append(unreachable, block.Start)
+				}
+			}
+			assert.Equal(test, tt.blocks, starts)
+			assert.Equal(test, tt.edges, cfg.Edges)
+			assert.Equal(test, tt.unresolved, unresolved)
+			assert.Equal(test, tt.unreachable, unreachable)
+		})
+	}
+}
+
+func TestCFGExport(test *testing.T) {
+	code, err := assembler.Assemble("PUSH 1; PUSH @a; JUMPI; STOP; a: JUMPDEST; STOP")
+	require.NoError(test, err)
+	cfg := BuildCFG(code)
+
+	var dot bytes.Buffer
+	require.NoError(test, cfg.WriteDot(&dot))
+	assert.Contains(test, dot.String(), "\tb0 [label=\"0000: PUSH1 0x01\\l0002: PUSH1 0x06\\l0004: JUMPI\\l\"];\n")
+	assert.Contains(test, dot.String(), "\tb0 -> b5 [label=\"fallthrough\" style=dashed];\n")
+	assert.Contains(test, dot.String(), "\tb0 -> b6 [label=\"branch\"];\n")
+
+	var out bytes.Buffer
+	require.NoError(test, cfg.WriteJSON(&out))
+	var decoded struct {
+		Blocks []struct {
+			Start        uint64   `json:"start"`
+			End          uint64   `json:"end"`
+			Instructions []string `json:"instructions"`
+		} `json:"blocks"`
+		Edges []CFGEdge `json:"edges"`
+	}
+	require.NoError(test, json.Unmarshal(out.Bytes(), &decoded))
+	require.Len(test, decoded.Blocks, 3)
+	assert.Equal(test, uint64(4), decoded.Blocks[0].End)
+	assert.Equal(test, []string{"0006: JUMPDEST", "0007: STOP"}, decoded.Blocks[2].Instructions)
+	assert.Equal(test, cfg.Edges, decoded.Edges)
+}
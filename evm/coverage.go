@@ -0,0 +1,258 @@
+package evm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// CodeCoverage is the coverage of a single contract code
+type CodeCoverage struct {
+	Code     []byte
+	Hash     common.Hash
+	Hits     map[uint64]uint64 // Executions of each program counter
+	Taken    map[uint64]uint64 // JUMPI executions that jumped
+	NotTaken map[uint64]uint64 // JUMPI executions that fell through
+}
+
+// CoverageSummary counts the covered instructions and JUMPI branches of a code
+type CoverageSummary struct {
+	Instructions        int
+	CoveredInstructions int
+	Branches            int // Two branches per JUMPI
+	CoveredBranches     int
+}
+
+// InstructionPercent returns the share of executed instructions
+func (s CoverageSummary) InstructionPercent() float64 {
+	return percentOf(s.CoveredInstructions, s.Instructions)
+}
+
+// BranchPercent returns the share of JUMPI branches taken at least once
+func (s CoverageSummary) BranchPercent() float64 {
+	return percentOf(s.CoveredBranches, s.Branches)
+}
+
+func percentOf(part, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(part) * 100 / float64(total)
+}
+
+// CoverageCollector records the executed instructions and JUMPI branches of every
+// code it sees, keyed by code hash, so coverage can accumulate across many runs
+type CoverageCollector struct {
+	codes map[common.Hash]*CodeCoverage
+
+	// The coverage of the last code run, to avoid hashing the code on every opcode
+	lastCode []byte
+	last     *CodeCoverage
+}
+
+// NewCoverageCollector creates an empty CoverageCollector
+func NewCoverageCollector() *CoverageCollector {
+	return &CoverageCollector{codes: make(map[common.Hash]*CodeCoverage)}
+}
+
+// Hooks returns the tracer hooks feeding the collector
+func (c *CoverageCollector) Hooks() *Hooks {
+	return &Hooks{OnOpcode: c.OnOpcode}
+}
+
+// coverage returns the coverage of the code running in scope
+func (c *CoverageCollector) coverage(scope *ExecutionContext) *CodeCoverage {
+	code := scope.ByteCode
+	if len(code) > 0 && len(code) == len(c.lastCode) && &code[0] == &c.lastCode[0] {
+		return c.last
+	}
+	hash := crypto.Keccak256Hash(code)
+	coverage := c.codes[hash]
+	if coverage == nil {
+		coverage = &CodeCoverage{
+			Code:     common.CopyBytes(code),
+			Hash:     hash,
+			Hits:     make(map[uint64]uint64),
+			Taken:    make(map[uint64]uint64),
+			NotTaken: make(map[uint64]uint64),
+		}
+		c.codes[hash] = coverage
+	}
+	c.lastCode, c.last = code, coverage
+	return coverage
+}
+
+// OnOpcode records the execution of an opcode and the direction of JUMPI branches
+func (c *CoverageCollector) OnOpcode(pc uint64, op t.Opcode, gas, cost uint64, scope *ExecutionContext, depth int, err error) {
+	coverage := c.coverage(scope)
+	coverage.Hits[pc]++
+
+	if op == t.JUMPI && err == nil {
+		condition, stackErr := scope.Stack.GetItem(1)
+		if stackErr != nil {
+			return
+		}
+		if condition.IsZero() {
+			coverage.NotTaken[pc]++
+		} else {
+			coverage.Taken[pc]++
+		}
+	}
+}
+
+// Codes returns the coverage of every code seen, sorted by hash
+func (c *CoverageCollector) Codes() []*CodeCoverage {
+	codes := make([]*CodeCoverage, 0, len(c.codes))
+	for _, coverage := range c.codes {
+		codes = append(codes, coverage)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].Hash.Cmp(codes[j].Hash) < 0
+	})
+	return codes
+}
+
+// Code returns the coverage of the code with the given hash, nil if it never ran
+func (c *CoverageCollector) Code(hash common.Hash) *CodeCoverage {
+	return c.codes[hash]
+}
+
+// Summary counts the covered instructions and branches
+func (cc *CodeCoverage) Summary() CoverageSummary {
+	var summary CoverageSummary
+	for _, instruction := range Disassemble(cc.Code).Instructions {
+		pc := instruction.PC
+		summary.Instructions++
+		if cc.Hits[pc] > 0 {
+			summary.CoveredInstructions++
+		}
+		if instruction.Opcode == t.JUMPI {
+			summary.Branches += 2
+			if cc.Taken[pc] > 0 {
+				summary.CoveredBranches++
+			}
+			if cc.NotTaken[pc] > 0 {
+				summary.CoveredBranches++
+			}
+		}
+	}
+	return summary
+}
+
+// WriteReport writes the coverage percentages of every code seen
+func (c *CoverageCollector) WriteReport(w io.Writer) error {
+	for _, coverage := range c.Codes() {
+		summary := coverage.Summary()
+		_, err := fmt.Fprintf(w, "%s: %d/%d instructions (%.2f%%), %d/%d branches (%.2f%%)\n",
+			coverage.Hash.Hex(),
+			summary.CoveredInstructions, summary.Instructions, summary.InstructionPercent(),
+			summary.CoveredBranches, summary.Branches, summary.BranchPercent())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteAnnotated writes the disassembly of the code with the number of executions
+// of every instruction. Instructions never executed are marked with "-".
+func (cc *CodeCoverage) WriteAnnotated(w io.Writer) error {
+	for _, instruction := range Disassemble(cc.Code).Instructions {
+		pc := instruction.PC
+
+		hits := "-"
+		if count := cc.Hits[pc]; count > 0 {
+			hits = fmt.Sprintf("%d", count)
+		}
+		line := fmt.Sprintf("%8s  %04x: %s", hits, pc, instruction)
+		if instruction.Opcode == t.JUMPI {
+			line += fmt.Sprintf("  [taken %d, not taken %d]", cc.Taken[pc], cc.NotTaken[pc])
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lcovFile accumulates the line coverage of a source file
+type lcovFile struct {
+	lines    map[int]uint64      // Hits of each line holding an instruction
+	branches map[int][][2]uint64 // Taken and not taken counts of the JUMPIs of each line
+}
+
+// WriteLcov writes the coverage in the lcov format, mapping instructions to source
+// lines with a solc source map. sources are indexed by the file index of the map.
+func (cc *CodeCoverage) WriteLcov(w io.Writer, sourceMap []SourceMapEntry, sources []SourceFile) error {
+	files := make(map[int]*lcovFile)
+	for i, instruction := range Disassemble(cc.Code).Instructions {
+		pc := instruction.PC
+		if i >= len(sourceMap) {
+			break
+		}
+		entry := sourceMap[i]
+		if entry.File < 0 || entry.File >= len(sources) {
+			continue
+		}
+		file := files[entry.File]
+		if file == nil {
+			file = &lcovFile{lines: make(map[int]uint64), branches: make(map[int][][2]uint64)}
+			files[entry.File] = file
+		}
+
+		line := sources[entry.File].lineOf(entry.Start)
+		file.lines[line] = max(file.lines[line], cc.Hits[pc])
+		if instruction.Opcode == t.JUMPI {
+			file.branches[line] = append(file.branches[line], [2]uint64{cc.Taken[pc], cc.NotTaken[pc]})
+		}
+	}
+
+	indices := make([]int, 0, len(files))
+	for index := range files {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	for _, index := range indices {
+		file := files[index]
+		fmt.Fprintf(w, "TN:\nSF:%s\n", sources[index].Path)
+
+		lines := make([]int, 0, len(file.lines))
+		for line := range file.lines {
+			lines = append(lines, line)
+		}
+		sort.Ints(lines)
+
+		var linesHit, branchesFound, branchesHit int
+		for _, line := range lines {
+			for block, branch := range file.branches[line] {
+				for direction, count := range branch {
+					taken := "-"
+					if file.lines[line] > 0 {
+						taken = fmt.Sprintf("%d", count)
+					}
+					fmt.Fprintf(w, "BRDA:%d,%d,%d,%s\n", line, block, direction, taken)
+					branchesFound++
+					if count > 0 {
+						branchesHit++
+					}
+				}
+			}
+		}
+		for _, line := range lines {
+			fmt.Fprintf(w, "DA:%d,%d\n", line, file.lines[line])
+			if file.lines[line] > 0 {
+				linesHit++
+			}
+		}
+		fmt.Fprintf(w, "BRF:%d\nBRH:%d\nLF:%d\nLH:%d\n", branchesFound, branchesHit, len(lines), linesHit)
+		if _, err := fmt.Fprintln(w, "end_of_record"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
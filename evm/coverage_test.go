@@ -0,0 +1,56 @@
+package evm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverageCollector(test *testing.T) {
+	// CALLVALUE PUSH1 7 JUMPI PUSH1 1 STOP JUMPDEST PUSH1 2 STOP
+	code := common.FromHex("0x346007576001005b600200")
+	hash := crypto.Keccak256Hash(code)
+	collector := NewCoverageCollector()
+
+	run := func(value uint64) {
+		ctx := NewExecutionContext()
+		ctx.CallValue = uint256.NewInt(value)
+		ctx.Tracer = collector.Hooks()
+		_, err := ctx.Run(code)
+		require.NoError(test, err)
+	}
+
+	run(0)
+	coverage := collector.Code(hash)
+	require.NotNil(test, coverage)
+	assert.Equal(test, CoverageSummary{Instructions: 8, CoveredInstructions: 5, Branches: 2, CoveredBranches: 1}, coverage.Summary())
+
+	var annotated bytes.Buffer
+	require.NoError(test, coverage.WriteAnnotated(&annotated))
+	assert.Contains(test, annotated.String(), "       1  0003: JUMPI  [taken 0, not taken 1]\n")
+	assert.Contains(test, annotated.String(), "       -  0007: JUMPDEST\n")
+	assert.Contains(test, annotated.String(), "       -  0008: PUSH1 0x02\n")
+
+	sourceMap, err := ParseSourceMap("0:1:0:-;;;2:1;;4:1;;")
+	require.NoError(test, err)
+	require.Len(test, sourceMap, 8)
+	var lcov bytes.Buffer
+	require.NoError(test, coverage.WriteLcov(&lcov, sourceMap, []SourceFile{{Path: "Test.sol", Content: []byte("a\nb\nc\n")}}))
+	assert.Equal(test, "TN:\nSF:Test.sol\n"+
+		"BRDA:1,0,0,0\nBRDA:1,0,1,1\n"+
+		"DA:1,1\nDA:2,1\nDA:3,0\n"+
+		"BRF:2\nBRH:1\nLF:3\nLH:2\nend_of_record\n", lcov.String())
+
+	// Coverage accumulates across runs
+	run(1)
+	assert.Equal(test, CoverageSummary{Instructions: 8, CoveredInstructions: 8, Branches: 2, CoveredBranches: 2}, coverage.Summary())
+
+	var report bytes.Buffer
+	require.NoError(test, collector.WriteReport(&report))
+	assert.Equal(test, hash.Hex()+": 8/8 instructions (100.00%), 2/2 branches (100.00%)\n", report.String())
+}
@@ -0,0 +1,111 @@
+package evm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	t "github.com/Manuelshub/go-EVM/types"
+)
+
+// metadataKeys are the CBOR keys solc writes in the metadata trailer of the code
+var metadataKeys = [][]byte{[]byte("ipfs"), []byte("bzzr0"), []byte("bzzr1"), []byte("solc"), []byte("experimental")}
+
+// DisassembledInstruction is a single instruction of a program
+type DisassembledInstruction struct {
+	PC        uint64
+	Opcode    t.Opcode
+	Name      string
+	Immediate []byte // Immediate data of PUSH operations
+	Truncated bool   // The code ends before the end of the immediate data
+}
+
+// Program is the disassembly of a bytecode
+type Program struct {
+	Instructions []DisassembledInstruction
+	Metadata     []byte    // Solidity CBOR metadata trailer, nil if the code has none
+	JumpDests    JumpDests // Valid jump destinations of the code
+}
+
+// ImmediateSize returns the number of bytes of immediate data following an opcode
+func ImmediateSize(op t.Opcode) int {
+	return InstructionTable[op].Immediate
+}
+
+// Disassemble decodes bytecode into its instructions. The Solidity metadata
+// trailer is not code and is returned apart instead of being decoded.
+func Disassemble(bytecode []byte) *Program {
+	code, metadata := splitMetadata(bytecode)
+	program := &Program{Metadata: metadata, JumpDests: AnalyzeJumpDests(code)}
+
+	for pc := uint64(0); pc < uint64(len(code)); pc++ {
+		op := t.Opcode(code[pc])
+		instruction := DisassembledInstruction{
+			PC:     pc,
+			Opcode: op,
+			Name:   GetOpcodeName(byte(op)),
+		}
+		if size := uint64(ImmediateSize(op)); size > 0 {
+			end := min(pc+1+size, uint64(len(code)))
+			instruction.Immediate = code[pc+1 : end]
+			instruction.Truncated = end-pc-1 < size
+			pc += size
+		}
+		program.Instructions = append(program.Instructions, instruction)
+	}
+	return program
+}
+
+// splitMetadata separates the Solidity metadata trailer from the code. solc
+// appends a CBOR map followed by its length as a 2 bytes big endian integer.
+func splitMetadata(bytecode []byte) ([]byte, []byte) {
+	if len(bytecode) < 2 {
+		return bytecode, nil
+	}
+	length := int(binary.BigEndian.Uint16(bytecode[len(bytecode)-2:]))
+	start := len(bytecode) - 2 - length
+	if length == 0 || start < 0 {
+		return bytecode, nil
+	}
+
+	// A CBOR map with less than 24 entries starts with 0xa0 + its size
+	metadata := bytecode[start:]
+	if metadata[0] < 0xa1 || metadata[0] > 0xb7 {
+		return bytecode, nil
+	}
+	for _, key := range metadataKeys {
+		if bytes.Contains(metadata[:length], key) {
+			return bytecode[:start], metadata
+		}
+	}
+	return bytecode, nil
+}
+
+// String returns the assembly of the instruction, e.g. "PUSH2 0x0102"
+func (instruction DisassembledInstruction) String() string {
+	if instruction.Immediate == nil && !instruction.Truncated {
+		return instruction.Name
+	}
+	s := fmt.Sprintf("%s 0x%x", instruction.Name, instruction.Immediate)
+	if instruction.Truncated {
+		s += " (truncated)"
+	}
+	return s
+}
+
+// Write writes one instruction per line prefixed with its program counter,
+// followed by the metadata trailer if any
+func (program *Program) Write(w io.Writer) error {
+	for _, instruction := range program.Instructions {
+		if _, err := fmt.Fprintf(w, "%04x: %s\n", instruction.PC, instruction); err != nil {
+			return err
+		}
+	}
+	if program.Metadata != nil {
+		if _, err := fmt.Fprintf(w, "metadata: 0x%x\n", program.Metadata); err != nil {
+			return err
+		}
+	}
+	return nil
+}
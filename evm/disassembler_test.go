@@ -0,0 +1,109 @@
+package evm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisassemble(test *testing.T) {
+	// a2 "ipfs" bytes34 "solc" bytes3 0.8.20, followed by its length 0x33
+	metadata := "a2646970667358221220" + strings.Repeat("ab", 32) + "64736f6c63430008140033"
+
+	tests := []struct {
+		name     string
+		code     string
+		expected []DisassembledInstruction
+		metadata []byte
+	}{
+		{
+			name: "Push immediates are decoded",
+			code: "0x6001610203015b00",
+			expected: []DisassembledInstruction{
+				{PC: 0, Opcode: t.PUSH1, Name: "PUSH1", Immediate: []byte{0x01}},
+				{PC: 2, Opcode: t.PUSH2, Name: "PUSH2", Immediate: []byte{0x02, 0x03}},
+				{PC: 5, Opcode: t.ADD, Name: "ADD"},
+				{PC: 6, Opcode: t.JUMPDEST, Name: "JUMPDEST"},
+				{PC: 7, Opcode: t.STOP, Name: "STOP"},
+			},
+		},
+		{
+			name: "Truncated push data",
+			code: "0x0063aabb",
+			expected: []DisassembledInstruction{
+				{PC: 0, Opcode: t.STOP, Name: "STOP"},
+				{PC: 1, Opcode: t.PUSH4, Name: "PUSH4", Immediate: []byte{0xaa, 0xbb}, Truncated: true},
+			},
+		},
+		{
+			name: "Solidity metadata trailer is split off",
+			code: "0x6001fe" + metadata,
+			expected: []DisassembledInstruction{
+				{PC: 0, Opcode: t.PUSH1, Name: "PUSH1", Immediate: []byte{0x01}},
+				{PC: 2, Opcode: t.INVALID, Name: "INVALID"},
+			},
+			metadata: common.FromHex(metadata),
+		},
+		{
+			name: "Solidity prologue",
+			code: "0x6080604052348015600e575f5ffd5b5060043610",
+			expected: []DisassembledInstruction{
+				{PC: 0, Opcode: t.PUSH1, Name: "PUSH1", Immediate: []byte{0x80}},
+				{PC: 2, Opcode: t.PUSH1, Name: "PUSH1", Immediate: []byte{0x40}},
+				{PC: 4, Opcode: t.MSTORE, Name: "MSTORE"},
+				{PC: 5, Opcode: t.CALLVALUE, Name: "CALLVALUE"},
+				{PC: 6, Opcode: t.DUP1, Name: "DUP1"},
+				{PC: 7, Opcode: t.ISZERO, Name: "ISZERO"},
+				{PC: 8, Opcode: t.PUSH1, Name: "PUSH1", Immediate: []byte{0x0e}},
+				{PC: 10, Opcode: t.JUMPI, Name: "JUMPI"},
+				{PC: 11, Opcode: t.PUSH0, Name: "PUSH0"},
+				{PC: 12, Opcode: t.PUSH0, Name: "PUSH0"},
+				{PC: 13, Opcode: t.REVERT, Name: "REVERT"},
+				{PC: 14, Opcode: t.JUMPDEST, Name: "JUMPDEST"},
+				{PC: 15, Opcode: t.POP, Name: "POP"},
+				{PC: 16, Opcode: t.PUSH1, Name: "PUSH1", Immediate: []byte{0x04}},
+				{PC: 18, Opcode: t.CALLDATASIZE, Name: "CALLDATASIZE"},
+				{PC: 19, Opcode: t.LT, Name: "LT"},
+			},
+		},
+		{
+			name: "Undefined opcodes",
+			code: "0x0c21ef",
+			expected: []DisassembledInstruction{
+				{PC: 0, Opcode: 0x0c, Name: "UNKNOWN (0xc)"},
+				{PC: 1, Opcode: 0x21, Name: "UNKNOWN (0x21)"},
+				{PC: 2, Opcode: 0xef, Name: "UNKNOWN (0xef)"},
+			},
+		},
+		{
+			name: "Trailing length without CBOR map is code",
+			code: "0x60010002",
+			expected: []DisassembledInstruction{
+				{PC: 0, Opcode: t.PUSH1, Name: "PUSH1", Immediate: []byte{0x01}},
+				{PC: 2, Opcode: t.STOP, Name: "STOP"},
+				{PC: 3, Opcode: 0x02, Name: "MUL"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			program := Disassemble(common.FromHex(tt.code))
+			assert.Equal(test, tt.expected, program.Instructions)
+			assert.Equal(test, tt.metadata, program.Metadata)
+		})
+	}
+}
+
+func TestProgramWrite(test *testing.T) {
+	var out bytes.Buffer
+	require.NoError(test, Disassemble(common.FromHex("0x61010200610a")).Write(&out))
+	assert.Equal(test, "0000: PUSH2 0x0102\n0003: STOP\n0004: PUSH2 0x0a (truncated)\n", out.String())
+	assert.Equal(test, 32, ImmediateSize(t.PUSH32))
+	assert.Equal(test, 0, ImmediateSize(t.JUMP))
+}
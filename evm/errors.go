@@ -0,0 +1,83 @@
+package evm
+
+import (
+	"errors"
+	"fmt"
+
+	t "github.com/Manuelshub/go-EVM/types"
+)
+
+// Errors of the execution of an instruction. The interpreter returns them wrapped
+// in an ExecutionError, use errors.Is to check for them. The errors of message
+// calls and contract creation are declared in evm.go.
+var (
+	ErrStackUnderflow = t.ErrStackUnderflow
+	ErrStackOverflow  = t.ErrStackOverflow
+	ErrOutOfGas       = t.ErrOutOfGas
+	ErrInvalidOpcode  = errors.New("invalid opcode")
+	ErrInvalidJump    = errors.New("invalid jump destination")
+	ErrTruncatedPush  = errors.New("push: insufficient data in bytecode")
+
+	ErrWriteProtection       = errors.New("write protection")
+	ErrReturnDataOutOfBounds = errors.New("return data out of bounds")
+	ErrNoEnvironment         = errors.New("no execution environment available")
+	ErrNoCallValue           = errors.New("call value not set")
+
+	// ErrEndOfCode is returned when stepping past the end of the code
+	ErrEndOfCode = errors.New("end of code")
+)
+
+// StackUnderflowError is returned when an instruction needs more items than the stack holds
+type StackUnderflowError struct {
+	StackLen int // Number of items on the stack
+	Required int // Number of items the instruction pops
+}
+
+func (e *StackUnderflowError) Error() string {
+	return fmt.Sprintf("stack underflow (%d <=> %d)", e.StackLen, e.Required)
+}
+
+func (e *StackUnderflowError) Unwrap() error {
+	return ErrStackUnderflow
+}
+
+// StackOverflowError is returned when an instruction would grow the stack past its limit
+type StackOverflowError struct {
+	StackLen int // Number of items on the stack
+	Limit    int // Maximum number of items the stack may hold before the instruction
+}
+
+func (e *StackOverflowError) Error() string {
+	return fmt.Sprintf("stack limit reached %d (%d)", e.StackLen, e.Limit)
+}
+
+func (e *StackOverflowError) Unwrap() error {
+	return ErrStackOverflow
+}
+
+// ExecutionError is the failure of an instruction, with the frame state it failed in
+type ExecutionError struct {
+	Err    error    // Cause of the failure
+	PC     uint64   // Program counter of the instruction
+	Opcode t.Opcode // Opcode of the instruction
+	Depth  int      // Call depth of the frame
+	Gas    uint64   // Gas available before the instruction
+}
+
+func (e *ExecutionError) Error() string {
+	return fmt.Sprintf("%v (pc %d, op %s, depth %d, gas %d)", e.Err, e.PC, GetOpcodeName(byte(e.Opcode)), e.Depth, e.Gas)
+}
+
+func (e *ExecutionError) Unwrap() error {
+	return e.Err
+}
+
+// errorCause returns the cause of an ExecutionError, or err itself. Trace formats
+// shared with other clients report the bare cause.
+func errorCause(err error) error {
+	var executionErr *ExecutionError
+	if errors.As(err, &executionErr) {
+		return executionErr.Err
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package evm
+
+import (
+	"errors"
+	"testing"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutionError(test *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		gas    uint64
+		err    error
+		pc     uint64
+		opcode t.Opcode
+		gasAt  uint64
+	}{
+		{
+			name:   "Invalid jump destination",
+			code:   "0x600356",
+			gas:    100,
+			err:    ErrInvalidJump,
+			pc:     2,
+			opcode: t.JUMP,
+			gasAt:  97,
+		},
+		{
+			name:   "Out of gas",
+			code:   "0x6001600201",
+			gas:    5,
+			err:    t.ErrOutOfGas,
+			pc:     2,
+			opcode: t.PUSH1,
+			gasAt:  2,
+		},
+		{
+			name:   "Invalid opcode",
+			code:   "0x6001fe",
+			gas:    100,
+			err:    ErrInvalidOpcode,
+			pc:     2,
+			opcode: t.INVALID,
+			gasAt:  97,
+		},
+		{
+			name:   "Stack underflow",
+			code:   "0x01",
+			gas:    100,
+			err:    ErrStackUnderflow,
+			pc:     0,
+			opcode: t.ADD,
+			gasAt:  100,
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			ctx := NewExecutionContext()
+			ctx.GasMeter = t.NewGasMeter(tt.gas)
+			_, err := ctx.Run(common.FromHex(tt.code))
+
+			assert.ErrorIs(test, err, tt.err)
+			var executionErr *ExecutionError
+			require.True(test, errors.As(err, &executionErr))
+			assert.Equal(test, tt.pc, executionErr.PC)
+			assert.Equal(test, tt.opcode, executionErr.Opcode)
+			assert.Equal(test, 0, executionErr.Depth)
+			assert.Equal(test, tt.gasAt, executionErr.Gas)
+			assert.ErrorIs(test, errorCause(err), tt.err)
+			assert.Equal(test, err, ctx.Error)
+		})
+	}
+}
+
+func TestExecutionErrorKeepsTypedCause(test *testing.T) {
+	ctx := NewExecutionContext()
+	_, err := ctx.Run(common.FromHex("0x600101"))
+
+	var underflow *StackUnderflowError
+	require.True(test, errors.As(err, &underflow))
+	assert.Equal(test, &StackUnderflowError{StackLen: 1, Required: 2}, underflow)
+	assert.EqualError(test, err, "stack underflow (1 <=> 2) (pc 2, op ADD, depth 0, gas 9999997)")
+}
+
+func TestRevertIsNotWrapped(test *testing.T) {
+	// PUSH1 0 PUSH1 0 REVERT
+	ctx := NewExecutionContext()
+	_, err := ctx.Run(common.FromHex("0x60006000fd"))
+
+	assert.Equal(test, ErrExecutionReverted, err)
+	var executionErr *ExecutionError
+	assert.False(test, errors.As(err, &executionErr))
+}
@@ -0,0 +1,318 @@
+package evm
+
+import (
+	"errors"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+// Errors returned by message calls and contract creation
+var (
+	ErrExecutionReverted        = errors.New("execution reverted")
+	ErrDepth                    = errors.New("max call depth exceeded")
+	ErrInsufficientBalance      = errors.New("insufficient balance for transfer")
+	ErrContractAddressCollision = errors.New("contract address collision")
+	ErrMaxCodeSizeExceeded      = errors.New("max code size exceeded")
+	ErrInvalidCode              = errors.New("invalid code: must not begin with 0xef")
+	ErrNonceUintOverflow        = errors.New("nonce uint64 overflow")
+)
+
+// BlockContext provides the EVM with information about the block being executed
+type BlockContext struct {
+	ChainID    uint64         // Chain ID used for replay protection
+	Coinbase   common.Address // Beneficiary of the transaction fees
+	Number     uint64         // Block number
+	Time       uint64         // Block timestamp
+	GasLimit   uint64         // Block gas limit, 0 means unlimited
+	BaseFee    *uint256.Int   // EIP-1559 base fee, nil before London
+	PrevRandao common.Hash    // Beacon chain randomness
+
+	ExcessBlobGas uint64 // EIP-4844 excess blob gas, determines the blob base fee
+
+	// GetHash returns the hash of an earlier block by number, nil makes BLOCKHASH
+	// return zero for all blocks
+	GetHash func(number uint64) common.Hash
+}
+
+// TxContext provides the EVM with information about the transaction being executed
+type TxContext struct {
+	Origin     common.Address // Sender of the transaction
+	GasPrice   *uint256.Int   // Effective gas price paid by the sender
+	BlobHashes []common.Hash  // EIP-4844 versioned hashes of the blobs of the transaction
+}
+
+// EVM holds the environment shared by all call frames of a transaction
+// and runs message calls and contract creations against the world state
+type EVM struct {
+	Block BlockContext
+	Tx    TxContext
+	State *t.StateDB
+
+	// Tracer receives the execution events of all frames, nil disables tracing
+	Tracer *Hooks
+
+	// Errors decodes the revert data of results and traces, nil decodes only
+	// Error(string) and Panic(uint256)
+	Errors *ErrorDecoder
+
+	depth     int                       // Number of frames currently executing
+	readOnly  bool                      // Set while inside a STATICCALL, state modifications are forbidden
+	jumpDests map[common.Hash]JumpDests // Jump destination analyses of the codes run, by code hash
+}
+
+// NewEVM creates a new EVM operating on the given state
+func NewEVM(block BlockContext, state *t.StateDB) *EVM {
+	return &EVM{
+		Block: block,
+		Tx:    TxContext{GasPrice: uint256.NewInt(0)},
+		State: state,
+	}
+}
+
+// newFrame creates the ExecutionContext of a new call frame. The frame runs the code
+// of codeAddress on behalf of address, which owns the storage and balance. The
+// storage is attached by run, so that frames running no code do not create the
+// account of address.
+func (evm *EVM) newFrame(caller, address, codeAddress common.Address, value *uint256.Int, input []byte, gas *t.GasMeter) *ExecutionContext {
+	return &ExecutionContext{
+		CallerAddress:   caller,
+		CallValue:       value,
+		CalleeAddress:   codeAddress,
+		ContractAddress: address,
+		Input:           input,
+		Stack:           t.NewStack(),
+		Memory:          t.NewMemory(),
+		GasMeter:        gas,
+		EVM:             evm,
+		Depth:           evm.depth + 1,
+		ReadOnly:        evm.readOnly,
+		Tracer:          evm.Tracer,
+	}
+}
+
+// run executes code inside the given frame
+func (evm *EVM) run(frame *ExecutionContext, code []byte) ([]byte, error) {
+	frame.Storage = evm.State.GetStorage(frame.ContractAddress)
+	evm.depth++
+	defer func() { evm.depth-- }()
+
+	return frame.Run(code)
+}
+
+// resolveCode returns the code executed when address is called. Accounts delegated
+// with an EIP-7702 designator run the code of their delegation target.
+func (evm *EVM) resolveCode(address common.Address) []byte {
+	code := evm.State.GetCode(address)
+	if target, ok := gethtypes.ParseDelegation(code); ok {
+		return evm.State.GetCode(target)
+	}
+	return code
+}
+
+// transfer moves value from one account to another
+func (evm *EVM) transfer(from, to common.Address, value *uint256.Int) {
+	if value.IsZero() {
+		return
+	}
+	evm.State.SubBalance(from, value)
+	evm.State.AddBalance(to, value)
+}
+
+// canTransfer checks whether the account has enough balance to transfer value
+func (evm *EVM) canTransfer(from common.Address, value *uint256.Int) bool {
+	return !evm.State.GetBalance(from).Lt(value)
+}
+
+// Call executes the code at address with the given input, as a message from caller.
+// It returns the output of the call and the gas meter of the frame, from which the
+// remaining gas and the collected refunds can be read. If the call fails all state
+// changes are reverted, and unless it was reverted by REVERT all gas is consumed.
+func (evm *EVM) Call(caller, address common.Address, input []byte, gas uint64, value *uint256.Int) (ret []byte, meter *t.GasMeter, err error) {
+	meter = t.NewGasMeter(gas)
+	evm.captureBegin(t.CALL, caller, address, input, gas, value)
+	defer func() { evm.captureEnd(gas, meter, ret, err) }()
+
+	if evm.depth > t.CallCreateDepth {
+		return nil, meter, ErrDepth
+	}
+	if !evm.canTransfer(caller, value) {
+		return nil, meter, ErrInsufficientBalance
+	}
+
+	snapshot := evm.State.Snapshot()
+	if !evm.State.Exist(address) {
+		// Calling a non-existent account without value is a no-op (EIP-161),
+		// unless it is a precompiled contract
+		if value.IsZero() {
+			if _, ok := precompiles[address]; !ok {
+				return nil, meter, nil
+			}
+		} else {
+			evm.State.CreateAccount(address)
+		}
+	}
+	evm.transfer(caller, address, value)
+
+	return evm.runCode(snapshot, evm.newFrame(caller, address, address, value, input, meter))
+}
+
+// CallCode executes the code at address in the context of the caller: the storage
+// and balance of the caller are used, and value is sent from the caller to itself.
+func (evm *EVM) CallCode(caller, address common.Address, input []byte, gas uint64, value *uint256.Int) (ret []byte, meter *t.GasMeter, err error) {
+	meter = t.NewGasMeter(gas)
+	evm.captureBegin(t.CALLCODE, caller, address, input, gas, value)
+	defer func() { evm.captureEnd(gas, meter, ret, err) }()
+
+	if evm.depth > t.CallCreateDepth {
+		return nil, meter, ErrDepth
+	}
+	if !evm.canTransfer(caller, value) {
+		return nil, meter, ErrInsufficientBalance
+	}
+	return evm.runCode(evm.State.Snapshot(), evm.newFrame(caller, caller, address, value, input, meter))
+}
+
+// DelegateCall executes the code at address in the context of the caller, keeping
+// the caller and value of the current frame (originCaller and value)
+func (evm *EVM) DelegateCall(originCaller, caller, address common.Address, input []byte, gas uint64, value *uint256.Int) (ret []byte, meter *t.GasMeter, err error) {
+	meter = t.NewGasMeter(gas)
+	evm.captureBegin(t.DELEGATECALL, caller, address, input, gas, value)
+	defer func() { evm.captureEnd(gas, meter, ret, err) }()
+
+	if evm.depth > t.CallCreateDepth {
+		return nil, meter, ErrDepth
+	}
+	return evm.runCode(evm.State.Snapshot(), evm.newFrame(originCaller, caller, address, value, input, meter))
+}
+
+// StaticCall executes the code at address like Call without value, but forbids any
+// state modification for the duration of the call and its sub-calls
+func (evm *EVM) StaticCall(caller, address common.Address, input []byte, gas uint64) (ret []byte, meter *t.GasMeter, err error) {
+	meter = t.NewGasMeter(gas)
+	evm.captureBegin(t.STATICCALL, caller, address, input, gas, uint256.NewInt(0))
+	defer func() { evm.captureEnd(gas, meter, ret, err) }()
+
+	if evm.depth > t.CallCreateDepth {
+		return nil, meter, ErrDepth
+	}
+	if !evm.readOnly {
+		evm.readOnly = true
+		defer func() { evm.readOnly = false }()
+	}
+	return evm.runCode(evm.State.Snapshot(), evm.newFrame(caller, address, address, uint256.NewInt(0), input, meter))
+}
+
+// runCode runs the code of the frame's code address, or the precompiled contract at
+// it, reverting to snapshot on failure
+func (evm *EVM) runCode(snapshot int, frame *ExecutionContext) ([]byte, *t.GasMeter, error) {
+	if contract, ok := precompiles[frame.CalleeAddress]; ok {
+		return evm.runPrecompile(snapshot, frame, contract)
+	}
+	code := evm.resolveCode(frame.CalleeAddress)
+	if len(code) == 0 {
+		return nil, frame.GasMeter, nil
+	}
+
+	ret, err := evm.run(frame, code)
+	if err != nil {
+		evm.failFrame(snapshot, frame.GasMeter, err)
+		if !errors.Is(err, ErrExecutionReverted) {
+			ret = nil
+		}
+	}
+	return ret, frame.GasMeter, err
+}
+
+// Create deploys a new contract by running code as initcode. The address of the
+// new contract is derived from the caller's address and nonce.
+func (evm *EVM) Create(caller common.Address, code []byte, gas uint64, value *uint256.Int) ([]byte, common.Address, *t.GasMeter, error) {
+	address := crypto.CreateAddress(caller, evm.State.GetNonce(caller))
+	return evm.create(t.CREATE, caller, code, gas, value, address)
+}
+
+// Create2 deploys a new contract like Create, at an address derived from the
+// caller's address, the salt and the hash of the initcode (EIP-1014)
+func (evm *EVM) Create2(caller common.Address, code []byte, gas uint64, value *uint256.Int, salt *uint256.Int) ([]byte, common.Address, *t.GasMeter, error) {
+	address := crypto.CreateAddress2(caller, salt.Bytes32(), crypto.Keccak256(code))
+	return evm.create(t.CREATE2, caller, code, gas, value, address)
+}
+
+// create runs initcode on behalf of the new contract at address and stores the
+// returned code. typ is the opcode reported to the tracer, CREATE or CREATE2.
+func (evm *EVM) create(typ t.Opcode, caller common.Address, code []byte, gas uint64, value *uint256.Int, address common.Address) (ret []byte, _ common.Address, meter *t.GasMeter, err error) {
+	meter = t.NewGasMeter(gas)
+	evm.captureBegin(typ, caller, address, code, gas, value)
+	defer func() { evm.captureEnd(gas, meter, ret, err) }()
+
+	if evm.depth > t.CallCreateDepth {
+		return nil, common.Address{}, meter, ErrDepth
+	}
+	if !evm.canTransfer(caller, value) {
+		return nil, common.Address{}, meter, ErrInsufficientBalance
+	}
+	nonce := evm.State.GetNonce(caller)
+	if nonce+1 < nonce {
+		return nil, common.Address{}, meter, ErrNonceUintOverflow
+	}
+	evm.State.SetNonce(caller, nonce+1)
+
+	// The new contract address is warm even if the creation fails (EIP-2929)
+	evm.State.AddAddressToAccessList(address)
+
+	// The address must not already be in use
+	if evm.State.GetNonce(address) != 0 || evm.State.GetCodeSize(address) != 0 {
+		evm.Tracer.captureGasChange(meter.GasRemaining(), 0, GasChangeCallFailedExecution)
+		meter.UseGas(meter.GasRemaining())
+		return nil, common.Address{}, meter, ErrContractAddressCollision
+	}
+
+	snapshot := evm.State.Snapshot()
+	evm.State.CreateAccount(address)
+	evm.State.CreateContract(address)
+	evm.State.SetNonce(address, 1) // Contracts start with nonce 1 (EIP-161)
+	evm.transfer(caller, address, value)
+
+	ret, err = evm.run(evm.newFrame(caller, address, address, value, nil, meter), code)
+	if err == nil {
+		err = evm.deployCode(address, ret, meter)
+	}
+	if err != nil {
+		evm.failFrame(snapshot, meter, err)
+		if !errors.Is(err, ErrExecutionReverted) {
+			ret = nil
+		}
+	}
+	return ret, address, meter, err
+}
+
+// deployCode validates the code returned by initcode, charges for storing it and
+// sets it as the code of the new contract
+func (evm *EVM) deployCode(address common.Address, code []byte, meter *t.GasMeter) error {
+	if len(code) > t.MaxCodeSize {
+		return ErrMaxCodeSizeExceeded
+	}
+	// Code starting with 0xEF is reserved for EOF (EIP-3541)
+	if len(code) > 0 && code[0] == 0xEF {
+		return ErrInvalidCode
+	}
+	if err := meter.UseGas(uint64(len(code)) * t.GasCreateByte); err != nil {
+		return err
+	}
+	evm.State.SetCode(address, code)
+	return nil
+}
+
+// failFrame reverts the state changes of a failed frame. Refunds are always
+// dropped, and all remaining gas is consumed unless the frame ended in REVERT.
+func (evm *EVM) failFrame(snapshot int, meter *t.GasMeter, err error) {
+	evm.State.RevertToSnapshot(snapshot)
+	meter.ResetRefund()
+	if !errors.Is(err, ErrExecutionReverted) {
+		evm.Tracer.captureGasChange(meter.GasRemaining(), 0, GasChangeCallFailedExecution)
+		meter.UseGas(meter.GasRemaining())
+	}
+}
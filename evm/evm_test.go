@@ -0,0 +1,180 @@
+package evm
+
+import (
+	"testing"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testLibrary = common.HexToAddress("0x3000000000000000000000000000000000000003")
+	testEmpty   = common.HexToAddress("0x4000000000000000000000000000000000000004")
+)
+
+// pushAddress returns the PUSH20 of an address as hex
+func pushAddress(address common.Address) string {
+	return "73" + common.Bytes2Hex(address.Bytes())
+}
+
+func TestMessageCalls(test *testing.T) {
+	// CALLVALUE PUSH1 0x00 SSTORE STOP
+	storeValue := common.FromHex("0x3460005500")
+
+	tests := []struct {
+		name     string
+		library  []byte
+		receiver string
+		value    uint64
+		err      error
+		check    func(test *testing.T, evm *EVM)
+	}{
+		{
+			name:    "DELEGATECALL runs in the context of the caller",
+			library: storeValue,
+			// DELEGATECALL(0xffff, library, 0, 0, 0, 0)
+			receiver: "0x60006000600060006000" + pushAddress(testLibrary) + "61fffff400",
+			value:    5,
+			check: func(test *testing.T, evm *EVM) {
+				// The value of the transaction is seen by the library, which writes to the receiver
+				assert.Equal(test, common.HexToHash("0x05"), evm.State.GetState(testReceiver, common.Hash{}))
+				assert.Equal(test, common.Hash{}, evm.State.GetState(testLibrary, common.Hash{}))
+				assert.Equal(test, uint64(10), evm.State.GetBalance(testReceiver).Uint64())
+				assert.True(test, evm.State.GetBalance(testLibrary).IsZero())
+			},
+		},
+		{
+			name:    "CALLCODE sends value to the caller itself",
+			library: storeValue,
+			// CALLCODE(0xffff, library, 3, 0, 0, 0, 0)
+			receiver: "0x60006000600060006003" + pushAddress(testLibrary) + "61fffff200",
+			check: func(test *testing.T, evm *EVM) {
+				assert.Equal(test, common.HexToHash("0x03"), evm.State.GetState(testReceiver, common.Hash{}))
+				assert.Equal(test, common.Hash{}, evm.State.GetState(testLibrary, common.Hash{}))
+				assert.Equal(test, uint64(10), evm.State.GetBalance(testReceiver).Uint64())
+				assert.True(test, evm.State.GetBalance(testLibrary).IsZero())
+			},
+		},
+		{
+			name: "STATICCALL to an empty account does not create it",
+			// STATICCALL(0xffff, empty, 0, 0, 0, 0)
+			receiver: "0x6000600060006000" + pushAddress(testEmpty) + "61fffffa00",
+			check: func(test *testing.T, evm *EVM) {
+				assert.False(test, evm.State.Exist(testEmpty))
+			},
+		},
+		{
+			name: "CALL sending value to an empty account creates it",
+			// CALL(0xffff, empty, 1, 0, 0, 0, 0)
+			receiver: "0x60006000600060006001" + pushAddress(testEmpty) + "61fffff100",
+			check: func(test *testing.T, evm *EVM) {
+				assert.True(test, evm.State.Exist(testEmpty))
+				assert.Equal(test, uint64(1), evm.State.GetBalance(testEmpty).Uint64())
+			},
+		},
+		{
+			name: "RETURNDATACOPY past the return data",
+			// RETURN one byte of memory
+			library: common.FromHex("0x60016000f3"),
+			// CALL(0xffff, library, 0, 0, 0, 0, 0) then RETURNDATACOPY(0, 0, 2)
+			receiver: "0x60006000600060006000" + pushAddress(testLibrary) + "61fffff1" + "6002600060003e00",
+			err:      ErrReturnDataOutOfBounds,
+		},
+		{
+			name: "RETURNDATACOPY within the return data",
+			// RETURN one byte of memory
+			library: common.FromHex("0x60016000f3"),
+			// CALL(0xffff, library, 0, 0, 0, 0, 0) then RETURNDATACOPY(0, 0, 1)
+			receiver: "0x60006000600060006000" + pushAddress(testLibrary) + "61fffff1" + "6001600060003e00",
+		},
+		{
+			name:    "EXTCODECOPY pads past the end of the code with zeros",
+			library: common.FromHex("0x6001"),
+			// MSTORE(0, not 0) then EXTCODECOPY(library, 0, 0, 4) and SSTORE(0, MLOAD(0))
+			receiver: "0x600019600052" + "600460006000" + pushAddress(testLibrary) + "3c" + "60005160005500",
+			check: func(test *testing.T, evm *EVM) {
+				expected := common.Hash{0x60, 0x01}
+				for i := 4; i < len(expected); i++ {
+					expected[i] = 0xff
+				}
+				assert.Equal(test, expected, evm.State.GetState(testReceiver, common.Hash{}))
+			},
+		},
+	}
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			evm := newTestEVM()
+			evm.State.SetCode(testLibrary, tt.library)
+			evm.State.SetCode(testReceiver, common.FromHex(tt.receiver))
+			evm.State.SetBalance(testReceiver, uint256.NewInt(10-tt.value))
+
+			result, err := ApplyMessage(evm, &Message{
+				From:     testSender,
+				To:       &testReceiver,
+				Value:    uint256.NewInt(tt.value),
+				GasLimit: 200_000,
+				GasPrice: uint256.NewInt(10),
+			})
+			require.NoError(test, err)
+			if tt.err != nil {
+				assert.ErrorIs(test, result.Err, tt.err)
+				return
+			}
+			require.NoError(test, result.Err)
+			if tt.check != nil {
+				tt.check(test, evm)
+			}
+		})
+	}
+}
+
+func TestCallDepthLimit(test *testing.T) {
+	// CALL(0xffff, library, 0, 0, 0, 0, 0) and store the success flag in slot 0
+	evm := newTestEVM()
+	evm.State.SetCode(testLibrary, common.FromHex("0x00"))
+	evm.State.SetCode(testReceiver, common.FromHex("0x60006000600060006000"+pushAddress(testLibrary)+"61fffff160005500"))
+
+	tests := []struct {
+		name    string
+		depth   int
+		success bool
+	}{
+		{"Nested call at the limit", t.CallCreateDepth - 1, true},
+		{"Nested call past the limit", t.CallCreateDepth, false},
+	}
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			evm.depth = tt.depth
+			defer func() { evm.depth = 0 }()
+			evm.State.SetState(testReceiver, common.Hash{}, common.HexToHash("0xff"))
+
+			_, _, err := evm.Call(testSender, testReceiver, nil, 100_000, uint256.NewInt(0))
+			require.NoError(test, err)
+			expected := common.Hash{}
+			if tt.success {
+				expected = common.BigToHash(common.Big1)
+			}
+			assert.Equal(test, expected, evm.State.GetState(testReceiver, common.Hash{}))
+		})
+	}
+
+	test.Run("Frames past the limit are rejected", func(test *testing.T) {
+		evm.depth = t.CallCreateDepth + 1
+		defer func() { evm.depth = 0 }()
+		zero := uint256.NewInt(0)
+
+		_, _, err := evm.Call(testSender, testReceiver, nil, 100_000, zero)
+		assert.ErrorIs(test, err, ErrDepth)
+		_, _, err = evm.CallCode(testSender, testReceiver, nil, 100_000, zero)
+		assert.ErrorIs(test, err, ErrDepth)
+		_, _, err = evm.DelegateCall(testSender, testSender, testReceiver, nil, 100_000, zero)
+		assert.ErrorIs(test, err, ErrDepth)
+		_, _, err = evm.StaticCall(testSender, testReceiver, nil, 100_000)
+		assert.ErrorIs(test, err, ErrDepth)
+		_, _, _, err = evm.Create(testSender, nil, 100_000, zero)
+		assert.ErrorIs(test, err, ErrDepth)
+	})
+}
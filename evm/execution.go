@@ -3,6 +3,7 @@ package evm
 import (
 	"errors"
 	"fmt"
+	"math"
 
 	t "github.com/Manuelshub/go-EVM/types"
 	"github.com/ethereum/go-ethereum/common"
@@ -22,9 +23,19 @@ type ExecutionContext struct {
 	Storage         *t.Storage
 	GasMeter        *t.GasMeter
 	ByteCode        []byte
+	Input           []byte // Call data of the current frame
+	EVM             *EVM   // Environment of the transaction, nil when running bare bytecode
+	Depth           int    // Call depth of the current frame
+	ReadOnly        bool   // Set inside STATICCALL, state modifications are forbidden
 	Stopped         bool   // Flag to indicate if execution should stop
 	ReturnData      []byte // Data returned by RETURN or REVERT
+	CallReturnData  []byte // Data returned by the last call made from this frame
 	Error           error  // Last execution error
+	Tracer          *Hooks // Receives the execution events, nil disables tracing
+
+	callGasTemp  uint64    // Gas forwarded to a sub-call or creation, computed by the gas function of the opcode
+	jumpDests    JumpDests // Jump destinations of analyzedCode
+	analyzedCode []byte    // Code the jumpDests were computed for
 }
 
 // NewExecutionContext creates a new ExecutionContext
@@ -48,27 +59,11 @@ func (ctx *ExecutionContext) Run(bytecode []byte) ([]byte, error) {
 
 	// Main execution loop
 	for !ctx.Stopped && ctx.ProgramCounter < uint64(len(ctx.ByteCode)) {
-		// Fetch the current opcode
-		op := t.Opcode(ctx.ByteCode[ctx.ProgramCounter])
-
-		// Look up the instruction
-		instruction, exists := InstructionTable[op]
-		if !exists {
-			ctx.Error = ErrInvalidOpcode
-			return nil, ctx.Error
-		}
-
-		// Consume gas
-		gasCost := instruction.GasCost(ctx)
-		if err := ctx.GasMeter.UseGas(gasCost); err != nil {
-			ctx.Error = ErrOutOfGas
-			return nil, ctx.Error
-		}
-
-		// Execute the instruction
-		ctx.ProgramCounter++
-		if err := instruction.Execute(ctx); err != nil {
-			ctx.Error = err
+		if err := ctx.step(); err != nil {
+			// REVERT hands its data back to the caller
+			if errors.Is(err, ErrExecutionReverted) {
+				return ctx.ReturnData, err
+			}
 			return nil, err
 		}
 	}
@@ -76,45 +71,85 @@ func (ctx *ExecutionContext) Run(bytecode []byte) ([]byte, error) {
 	return ctx.ReturnData, ctx.Error
 }
 
-// GetOpcodeName returns the name of an opcode
-func GetOpcodeName(opcode byte) string {
-	op := t.Opcode(opcode)
-	instruction, exists := InstructionTable[op]
-	if exists {
-		return instruction.Name
-	}
-	return fmt.Sprintf("UNKNOWN (0x%x)", opcode)
-}
+// step fetches, charges and executes the instruction at the program counter.
+// It is the single interpreter loop body shared by Run and ExecuteStep, and
+// reports every step to the tracer.
+func (ctx *ExecutionContext) step() error {
+	pc := ctx.ProgramCounter
+	op := t.Opcode(ctx.ByteCode[pc])
+	gas := ctx.GasMeter.GasRemaining()
 
-// ExecuteStep executes a single instruction and advances the program counter
-func ExecuteStep(ctx *ExecutionContext) error {
-	if ctx.ProgramCounter >= uint64(len(ctx.ByteCode)) {
-		return errors.New("end of code")
+	// Look up the instruction
+	instruction := jumpTable[op]
+	if instruction == nil {
+		ctx.captureOpcode(pc, op, gas, 0, ErrInvalidOpcode)
+		return ctx.fail(pc, op, gas, ErrInvalidOpcode)
 	}
 
-	// Fetch the current opcode
-	op := t.Opcode(ctx.ByteCode[ctx.ProgramCounter])
-
-	// Look up the instruction
-	instruction, exists := InstructionTable[op]
-	if !exists {
-		ctx.Error = ErrInvalidOpcode
-		return fmt.Errorf("invalid opcode: 0x%x", op)
+	// Validate the stack before the operands are read by the gas function
+	if err := instruction.validateStack(ctx.Stack); err != nil {
+		ctx.captureOpcode(pc, op, gas, 0, err)
+		return ctx.fail(pc, op, gas, err)
 	}
 
-	// Consume gas
-	gasCost := instruction.GasCost(ctx)
-	if err := ctx.GasMeter.UseGas(gasCost); err != nil {
-		ctx.Error = ErrOutOfGas
-		return err
+	// Consume gas. A cost that does not fit in 64 bits can never be paid and
+	// exhausts the gas like any other unaffordable cost.
+	gasCost, err := instruction.GasCost(ctx)
+	if err == nil {
+		err = ctx.GasMeter.UseGas(gasCost)
+	} else {
+		ctx.GasMeter.UseGas(math.MaxUint64)
+	}
+	if err != nil {
+		ctx.captureOpcode(pc, op, gas, gasCost, err)
+		return ctx.fail(pc, op, gas, err)
 	}
+	ctx.captureOpcode(pc, op, gas, gasCost, nil)
+	ctx.Tracer.captureGasChange(gas, gas-gasCost, GasChangeCallOpCode)
 
 	// Execute the instruction
 	ctx.ProgramCounter++
 	if err := instruction.Execute(ctx); err != nil {
+		if ctx.Tracer != nil && ctx.Tracer.OnFault != nil {
+			ctx.Tracer.OnFault(pc, op, gas, gasCost, ctx, ctx.Depth, err)
+		}
+		return ctx.fail(pc, op, gas, err)
+	}
+	return nil
+}
+
+// fail records the error of the instruction at pc as the error of the frame. It is
+// wrapped in an ExecutionError, except for REVERT which is a regular halt.
+func (ctx *ExecutionContext) fail(pc uint64, op t.Opcode, gas uint64, err error) error {
+	if errors.Is(err, ErrExecutionReverted) {
 		ctx.Error = err
-		return err
+	} else {
+		ctx.Error = &ExecutionError{Err: err, PC: pc, Opcode: op, Depth: ctx.Depth, Gas: gas}
 	}
+	return ctx.Error
+}
 
-	return nil
+// captureOpcode notifies the tracer that an opcode is about to be executed
+func (ctx *ExecutionContext) captureOpcode(pc uint64, op t.Opcode, gas, cost uint64, err error) {
+	if ctx.Tracer != nil && ctx.Tracer.OnOpcode != nil {
+		ctx.Tracer.OnOpcode(pc, op, gas, cost, ctx, ctx.Depth, err)
+	}
+}
+
+// GetOpcodeName returns the name of an opcode. Names come from the instruction
+// set, so opcodes the interpreter does not implement are still named, only
+// undefined bytes are UNKNOWN.
+func GetOpcodeName(opcode byte) string {
+	if name := t.OpcodeName(t.Opcode(opcode)); name != "" {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN (0x%x)", opcode)
+}
+
+// ExecuteStep executes a single instruction and advances the program counter
+func ExecuteStep(ctx *ExecutionContext) error {
+	if ctx.ProgramCounter >= uint64(len(ctx.ByteCode)) {
+		return ErrEndOfCode
+	}
+	return ctx.step()
 }
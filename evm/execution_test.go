@@ -0,0 +1,184 @@
+package evm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackValidation(test *testing.T) {
+	tests := []struct {
+		name      string
+		code      string
+		err       error
+		underflow *StackUnderflowError
+		overflow  *StackOverflowError
+		gasUsed   uint64
+	}{
+		{
+			name:      "Binary operation on a single item",
+			code:      "0x600101",
+			err:       ErrStackUnderflow,
+			underflow: &StackUnderflowError{StackLen: 1, Required: 2},
+			gasUsed:   3,
+		},
+		{
+			name:      "SWAP reaches below the stack",
+			code:      "0x6001600291",
+			err:       ErrStackUnderflow,
+			underflow: &StackUnderflowError{StackLen: 2, Required: 3},
+			gasUsed:   6,
+		},
+		{
+			name:      "DUP reaches below the stack",
+			code:      "0x600181",
+			err:       ErrStackUnderflow,
+			underflow: &StackUnderflowError{StackLen: 1, Required: 2},
+			gasUsed:   3,
+		},
+		{
+			name:     "PUSH on a full stack",
+			code:     "0x" + strings.Repeat("6001", t.MAX_STACK_SIZE+1),
+			err:      ErrStackOverflow,
+			overflow: &StackOverflowError{StackLen: t.MAX_STACK_SIZE, Limit: t.MAX_STACK_SIZE - 1},
+			gasUsed:  3 * t.MAX_STACK_SIZE,
+		},
+		{
+			name:     "DUP on a full stack",
+			code:     "0x" + strings.Repeat("6001", t.MAX_STACK_SIZE) + "80",
+			err:      ErrStackOverflow,
+			overflow: &StackOverflowError{StackLen: t.MAX_STACK_SIZE, Limit: t.MAX_STACK_SIZE - 1},
+			gasUsed:  3 * t.MAX_STACK_SIZE,
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			ctx := NewExecutionContext()
+			_, err := ctx.Run(common.FromHex(tt.code))
+			require.ErrorIs(test, err, tt.err)
+
+			// The failing instruction is not charged
+			assert.Equal(test, tt.gasUsed, ctx.GasMeter.GasConsumed())
+
+			var underflow *StackUnderflowError
+			if errors.As(err, &underflow) {
+				assert.Equal(test, tt.underflow, underflow)
+			}
+			var overflow *StackOverflowError
+			if errors.As(err, &overflow) {
+				assert.Equal(test, tt.overflow, overflow)
+			}
+			assert.Equal(test, tt.underflow != nil, underflow != nil)
+			assert.Equal(test, tt.overflow != nil, overflow != nil)
+		})
+	}
+}
+
+func TestInstructionStackBounds(test *testing.T) {
+	for op, instruction := range InstructionTable {
+		switch {
+		case op >= t.DUP1 && op <= t.DUP16:
+			assert.Equal(test, int(op-t.DUP1)+1, instruction.MinStack(), instruction.Name)
+			assert.Equal(test, t.MAX_STACK_SIZE-1, instruction.MaxStack(), instruction.Name)
+		case op >= t.SWAP1 && op <= t.SWAP16:
+			assert.Equal(test, int(op-t.SWAP1)+2, instruction.MinStack(), instruction.Name)
+			assert.Equal(test, t.MAX_STACK_SIZE, instruction.MaxStack(), instruction.Name)
+		}
+	}
+}
+
+func TestCallDataOperations(test *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		input   string
+		output  string
+		gasUsed uint64
+	}{
+		{
+			name:    "CALLDATASIZE",
+			code:    "0x3660005260206000f3",
+			input:   "0x010203",
+			output:  "0x0000000000000000000000000000000000000000000000000000000000000003",
+			gasUsed: 2 + 3 + 3 + 3 + 3 + 3,
+		},
+		{
+			name:    "CALLDATALOAD pads past the end",
+			code:    "0x60013560005260206000f3",
+			input:   "0x010203",
+			output:  "0x0203000000000000000000000000000000000000000000000000000000000000",
+			gasUsed: 3 + 3 + 3 + 3 + 3 + 3 + 3,
+		},
+		{
+			name:    "CALLDATALOAD beyond 2^64",
+			code:    "0x680100000000000000003560005260206000f3",
+			input:   "0x010203",
+			output:  "0x0000000000000000000000000000000000000000000000000000000000000000",
+			gasUsed: 3 + 3 + 3 + 3 + 3 + 3 + 3,
+		},
+		{
+			name:    "CALLDATACOPY",
+			code:    "0x6004600260003760206000f3",
+			input:   "0x0102030405",
+			output:  "0x0304050000000000000000000000000000000000000000000000000000000000",
+			gasUsed: 3 + 3 + 3 + 3 + 3 + 3 + 3 + 3, // The copy of one word costs 3
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			ctx := NewExecutionContext()
+			ctx.Input = common.FromHex(tt.input)
+			output, err := ctx.Run(common.FromHex(tt.code))
+			require.NoError(test, err)
+			assert.Equal(test, common.FromHex(tt.output), output)
+			assert.Equal(test, tt.gasUsed, ctx.GasMeter.GasConsumed())
+		})
+	}
+}
+
+func TestMemoryGasOverflow(test *testing.T) {
+	const (
+		maxUint64 = "67ffffffffffffffff"   // PUSH8 2^64-1
+		twoTo64   = "68010000000000000000" // PUSH9 2^64
+	)
+	tests := []struct {
+		name string
+		code string
+	}{
+		{name: "MLOAD beyond 2^64", code: twoTo64 + "51"},
+		{name: "MSTORE wrapping around", code: "6001" + maxUint64 + "52"},
+		{name: "MSTORE8 wrapping around", code: "6001" + maxUint64 + "53"},
+		{name: "RETURN wrapping around", code: "6002" + maxUint64 + "f3"},
+		{name: "RETURN of 2^256-1 bytes", code: "7f" + strings.Repeat("ff", 32) + "6000f3"},
+		{name: "REVERT beyond the memory limit", code: "64ffffffffff6000fd"},
+		{name: "RETURNDATACOPY wrapping around", code: "60026000" + maxUint64 + "3e"},
+		{name: "LOG0 data cost wrapping around", code: "672000000000000000" + "6000a0"},
+		{name: "LOG1 of 2^256-1 bytes", code: "602a" + "7f" + strings.Repeat("ff", 32) + "6000a1"},
+		{name: "CALLDATACOPY wrapping around", code: "60026000" + maxUint64 + "37"},
+		{name: "CALLDATACOPY of 2^64 bytes", code: twoTo64 + "60006000" + "37"},
+		{name: "CODECOPY wrapping around", code: "60026000" + maxUint64 + "39"},
+		{name: "CODECOPY of 2^256-1 bytes", code: "7f" + strings.Repeat("ff", 32) + "60006000" + "39"},
+		{name: "EXTCODECOPY of 2^64 bytes", code: twoTo64 + "600060006000" + "3c"},
+		{name: "CALL input wrapping around", code: "600060006001" + maxUint64 + "600060006000f1"},
+		{name: "STATICCALL output beyond 2^64", code: twoTo64 + "60006000600060006000fa"},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			evm := newTestEVM()
+			evm.State.SetCode(testReceiver, common.FromHex(tt.code))
+
+			_, meter, err := evm.Call(testSender, testReceiver, nil, 100_000, uint256.NewInt(0))
+			require.ErrorIs(test, err, ErrOutOfGas)
+			assert.Zero(test, meter.GasRemaining())
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package evm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// ProfileEntry aggregates the executions of an opcode or a program counter
+type ProfileEntry struct {
+	Count uint64 // Number of executions
+	Gas   uint64 // Gas spent by the executions, excluding the gas forwarded to sub-calls
+}
+
+// pcKey identifies an instruction of a contract
+type pcKey struct {
+	address common.Address
+	pc      uint64
+}
+
+// GasProfiler aggregates the gas spent by opcode, by program counter and by
+// call stack. The gas forwarded by call opcodes is attributed to the frames of
+// the sub-calls rather than to the call opcode itself.
+type GasProfiler struct {
+	opcodes map[t.Opcode]*ProfileEntry
+	pcs     map[pcKey]*ProfileEntry
+	stacks  map[string]uint64 // Folded call stacks ending with an opcode
+	frames  []string          // Labels of the frames currently executing
+	total   uint64
+}
+
+// NewGasProfiler creates an empty GasProfiler
+func NewGasProfiler() *GasProfiler {
+	return &GasProfiler{
+		opcodes: make(map[t.Opcode]*ProfileEntry),
+		pcs:     make(map[pcKey]*ProfileEntry),
+		stacks:  make(map[string]uint64),
+	}
+}
+
+// Hooks returns the tracer hooks feeding the profiler
+func (p *GasProfiler) Hooks() *Hooks {
+	return &Hooks{
+		OnEnter:  p.OnEnter,
+		OnExit:   p.OnExit,
+		OnOpcode: p.OnOpcode,
+	}
+}
+
+// OnEnter pushes the frame of a call on the profiled call stack
+func (p *GasProfiler) OnEnter(depth int, typ t.Opcode, from, to common.Address, input []byte, gas uint64, value *uint256.Int) {
+	p.frames = append(p.frames, fmt.Sprintf("%s %s", t.OpcodeName(typ), to.Hex()))
+}
+
+// OnExit pops the frame of a call from the profiled call stack
+func (p *GasProfiler) OnExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	if len(p.frames) > 0 {
+		p.frames = p.frames[:len(p.frames)-1]
+	}
+}
+
+// OnOpcode records the gas cost of an executed opcode
+func (p *GasProfiler) OnOpcode(pc uint64, op t.Opcode, gas, cost uint64, scope *ExecutionContext, depth int, err error) {
+	// Opcodes that could not be executed did not spend their cost
+	if err != nil {
+		return
+	}
+	// The gas forwarded to a new frame is spent by the opcodes of that frame
+	switch op {
+	case t.CALL, t.CALLCODE, t.DELEGATECALL, t.STATICCALL, t.CREATE, t.CREATE2:
+		cost -= scope.callGasTemp
+	}
+
+	entry := p.opcodes[op]
+	if entry == nil {
+		entry = &ProfileEntry{}
+		p.opcodes[op] = entry
+	}
+	entry.Count++
+	entry.Gas += cost
+
+	key := pcKey{address: scope.CalleeAddress, pc: pc}
+	entry = p.pcs[key]
+	if entry == nil {
+		entry = &ProfileEntry{}
+		p.pcs[key] = entry
+	}
+	entry.Count++
+	entry.Gas += cost
+
+	// Bare bytecode runs outside of any call frame
+	frames := p.frames
+	if len(frames) == 0 {
+		frames = []string{"main"}
+	}
+	p.stacks[strings.Join(append(frames[:len(frames):len(frames)], GetOpcodeName(byte(op))), ";")] += cost
+	p.total += cost
+}
+
+// TotalGas returns the gas spent by all profiled opcodes
+func (p *GasProfiler) TotalGas() uint64 {
+	return p.total
+}
+
+// Opcode returns the profile of an opcode
+func (p *GasProfiler) Opcode(op t.Opcode) ProfileEntry {
+	if entry := p.opcodes[op]; entry != nil {
+		return *entry
+	}
+	return ProfileEntry{}
+}
+
+// percent returns the share of the total gas spent by gas
+func (p *GasProfiler) percent(gas uint64) float64 {
+	if p.total == 0 {
+		return 0
+	}
+	return float64(gas) * 100 / float64(p.total)
+}
+
+// WriteOpcodeTable writes the profile by opcode, most expensive first
+func (p *GasProfiler) WriteOpcodeTable(w io.Writer) error {
+	ops := make([]t.Opcode, 0, len(p.opcodes))
+	for op := range p.opcodes {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		a, b := p.opcodes[ops[i]], p.opcodes[ops[j]]
+		if a.Gas != b.Gas {
+			return a.Gas > b.Gas
+		}
+		return ops[i] < ops[j]
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "OPCODE\tCOUNT\tGAS\tGAS %\t")
+	for _, op := range ops {
+		entry := p.opcodes[op]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\t\n", GetOpcodeName(byte(op)), entry.Count, entry.Gas, p.percent(entry.Gas))
+	}
+	return tw.Flush()
+}
+
+// WritePCTable writes the profile by contract and program counter, most expensive first
+func (p *GasProfiler) WritePCTable(w io.Writer) error {
+	keys := make([]pcKey, 0, len(p.pcs))
+	for key := range p.pcs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := p.pcs[keys[i]], p.pcs[keys[j]]
+		if a.Gas != b.Gas {
+			return a.Gas > b.Gas
+		}
+		if keys[i].address != keys[j].address {
+			return keys[i].address.Cmp(keys[j].address) < 0
+		}
+		return keys[i].pc < keys[j].pc
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "CONTRACT\tPC\tCOUNT\tGAS\tGAS %\t")
+	for _, key := range keys {
+		entry := p.pcs[key]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.2f\t\n", key.address.Hex(), key.pc, entry.Count, entry.Gas, p.percent(entry.Gas))
+	}
+	return tw.Flush()
+}
+
+// WriteFoldedStacks writes the gas spent by call stack in the folded format read
+// by flamegraph tools: one "frame;frame;OPCODE gas" line per distinct stack
+func (p *GasProfiler) WriteFoldedStacks(w io.Writer) error {
+	stacks := make([]string, 0, len(p.stacks))
+	for stack := range p.stacks {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+
+	for _, stack := range stacks {
+		if _, err := fmt.Fprintf(w, "%s %d\n", stack, p.stacks[stack]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package evm
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGasProfiler(test *testing.T) {
+	test.Run("Bare bytecode", func(test *testing.T) {
+		profiler := NewGasProfiler()
+		ctx := NewExecutionContext()
+		ctx.Tracer = profiler.Hooks()
+		// PUSH1 1 PUSH1 2 ADD PUSH1 3 ADD
+		_, err := ctx.Run(common.FromHex("0x6001600201600301"))
+		require.NoError(test, err)
+
+		assert.Equal(test, ProfileEntry{Count: 3, Gas: 9}, profiler.Opcode(t.PUSH1))
+		assert.Equal(test, ProfileEntry{Count: 2, Gas: 6}, profiler.Opcode(t.ADD))
+		assert.Equal(test, ctx.GasMeter.GasConsumed(), profiler.TotalGas())
+
+		var folded bytes.Buffer
+		require.NoError(test, profiler.WriteFoldedStacks(&folded))
+		assert.Equal(test, "main;ADD 6\nmain;PUSH1 9\n", folded.String())
+
+		var table bytes.Buffer
+		require.NoError(test, profiler.WriteOpcodeTable(&table))
+		assert.Regexp(test, `(?s)PUSH1\s+3\s+9\s+60.00.*ADD\s+2\s+6\s+40.00`, table.String())
+	})
+
+	test.Run("Gas forwarded to sub-calls is attributed to their frames", func(test *testing.T) {
+		evm := newTestEVM()
+		callee := common.HexToAddress("0x3000000000000000000000000000000000000003")
+		evm.State.SetCode(callee, common.FromHex("0x600160005500"))
+		evm.State.SetCode(testReceiver, common.FromHex("0x60006000600060006000"+"73"+common.Bytes2Hex(callee.Bytes())+"61fffff100"))
+
+		profiler := NewGasProfiler()
+		evm.Tracer = profiler.Hooks()
+		result, err := ApplyMessage(evm, &Message{
+			From:     testSender,
+			To:       &testReceiver,
+			GasLimit: 200_000,
+			GasPrice: uint256.NewInt(10),
+		})
+		require.NoError(test, err)
+		require.NoError(test, result.Err)
+
+		// Calling a cold account costs the cold access, the forwarded gas is not counted
+		assert.Equal(test, ProfileEntry{Count: 1, Gas: t.GasColdAccountAccess}, profiler.Opcode(t.CALL))
+		sstore := profiler.Opcode(t.SSTORE)
+		assert.Equal(test, uint64(1), sstore.Count)
+		// Everything but the intrinsic gas is spent by profiled opcodes
+		assert.Equal(test, result.UsedGas-t.TxGas, profiler.TotalGas())
+
+		var folded bytes.Buffer
+		require.NoError(test, profiler.WriteFoldedStacks(&folded))
+		assert.Contains(test, folded.String(), fmt.Sprintf("CALL %s;CALL %s;SSTORE %d\n", testReceiver.Hex(), callee.Hex(), sstore.Gas))
+
+		var table bytes.Buffer
+		require.NoError(test, profiler.WritePCTable(&table))
+		assert.Contains(test, table.String(), callee.Hex())
+	})
+
+	// Initcode running MSTORE8(0, 1) and deploying no code, stored at offset 27
+	initcode := "PUSH 0x6001600053; PUSH 0; MSTORE; "
+	for _, tt := range []struct {
+		op     t.Opcode
+		source string
+		cost   uint64
+	}{
+		{op: t.CREATE, source: initcode + "PUSH 5; PUSH 27; PUSH 0; CREATE", cost: t.GasCreate + t.InitCodeWordGas},
+		{op: t.CREATE2, source: initcode + "PUSH 0; PUSH 5; PUSH 27; PUSH 0; CREATE2", cost: t.GasCreate + t.InitCodeWordGas + t.GasKeccak256Word},
+	} {
+		test.Run(fmt.Sprintf("Gas forwarded to %s is attributed to the new frame", t.OpcodeName(tt.op)), func(test *testing.T) {
+			evm := newTestEVM()
+			evm.State.SetCode(testReceiver, assemble(test, tt.source))
+
+			profiler := NewGasProfiler()
+			evm.Tracer = profiler.Hooks()
+			result, err := ApplyMessage(evm, &Message{
+				From:     testSender,
+				To:       &testReceiver,
+				GasLimit: 200_000,
+				GasPrice: uint256.NewInt(10),
+			})
+			require.NoError(test, err)
+			require.NoError(test, result.Err)
+
+			assert.Equal(test, ProfileEntry{Count: 1, Gas: tt.cost}, profiler.Opcode(tt.op))
+			assert.Equal(test, uint64(1), profiler.Opcode(t.MSTORE8).Count)
+			assert.Equal(test, result.UsedGas-t.TxGas, profiler.TotalGas())
+		})
+	}
+
+	test.Run("Contract creation frames", func(test *testing.T) {
+		evm := newTestEVM()
+		profiler := NewGasProfiler()
+		evm.Tracer = profiler.Hooks()
+		// MSTORE8(0, 1) then RETURN(0, 1)
+		result, err := ApplyMessage(evm, &Message{
+			From:     testSender,
+			Data:     common.FromHex("0x600160005360016000f3"),
+			GasLimit: 100_000,
+			GasPrice: uint256.NewInt(10),
+		})
+		require.NoError(test, err)
+		require.NoError(test, result.Err)
+
+		var folded bytes.Buffer
+		require.NoError(test, profiler.WriteFoldedStacks(&folded))
+		assert.Contains(test, folded.String(), fmt.Sprintf("CREATE %s;RETURN ", result.ContractAddress.Hex()))
+	})
+}
@@ -3,27 +3,61 @@ package evm
 import (
 	"errors"
 	"fmt"
+	"math/bits"
 
 	t "github.com/Manuelshub/go-EVM/types"
 	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/holiman/uint256"
 )
 
-// Various execution errors
-var (
-	ErrStackUnderflow = t.ErrStackUnderflow
-	ErrStackOverflow  = t.ErrStackOverflow
-	ErrInvalidOpcode  = errors.New("invalid opcode")
-	ErrOutOfGas       = errors.New("out of gas")
-)
-
-// Instruction represents a single EVM instruction
+// Instruction represents a single EVM instruction. StackPops is the number of
+// items the instruction takes from the top of the stack and StackPushs the number
+// it puts back, so DUPn pops n items and pushes n+1, and SWAPn pops and pushes n+1.
 type Instruction struct {
-	Execute    func(ctx *ExecutionContext) error
-	GasCost    func(ctx *ExecutionContext) uint64
-	Name       string
-	StackPops  int
-	StackPushs int
+	Execute     func(ctx *ExecutionContext) error
+	ConstantGas uint64                                      // Gas charged on every execution
+	DynamicGas  func(ctx *ExecutionContext) (uint64, error) // Gas depending on the operands, added to ConstantGas, nil if none
+	Name        string
+	StackPops   int
+	StackPushs  int
+	Immediate   int // Number of bytes of immediate data following the opcode in the code
+}
+
+// MinStack returns the number of items the stack must hold to execute the instruction
+func (instruction *Instruction) MinStack() int {
+	return instruction.StackPops
+}
+
+// MaxStack returns the number of items the stack may hold at most to execute the
+// instruction without overflowing
+func (instruction *Instruction) MaxStack() int {
+	return t.MAX_STACK_SIZE + instruction.StackPops - instruction.StackPushs
+}
+
+// validateStack checks the stack height against the declared stack effect of the instruction
+func (instruction *Instruction) validateStack(stack *t.Stack) error {
+	if size := stack.Size(); size < instruction.MinStack() {
+		return &StackUnderflowError{StackLen: size, Required: instruction.MinStack()}
+	} else if size > instruction.MaxStack() {
+		return &StackOverflowError{StackLen: size, Limit: instruction.MaxStack()}
+	}
+	return nil
+}
+
+// GasCost returns the gas charged for executing the instruction in ctx. It fails
+// with ErrOutOfGas when the cost does not fit in 64 bits, e.g. for a memory
+// access at a huge offset.
+func (instruction *Instruction) GasCost(ctx *ExecutionContext) (uint64, error) {
+	if instruction.DynamicGas == nil {
+		return instruction.ConstantGas, nil
+	}
+	dynamic, err := instruction.DynamicGas(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return safeAdd(instruction.ConstantGas, dynamic)
 }
 
 // InstructionTable maps opcodes to their implementations
@@ -31,166 +65,549 @@ var InstructionTable = map[t.Opcode]Instruction{
 	// t.STOP is the executes the stop operation and halts the execution of the program
 	// And it is the only operation that does not consume any gas.
 	t.STOP: {
-		Execute:    opStop,
-		GasCost:    constGasFunc(t.GasTierZero),
-		Name:       "STOP",
-		StackPops:  0,
-		StackPushs: 0,
+		Execute:     opStop,
+		ConstantGas: t.GasTierZero,
+		Name:        "STOP",
+		StackPops:   0,
+		StackPushs:  0,
 	},
 	// t.ADD is the addition operation. It operates on the last two elements of the stack
 	t.ADD: {
-		Execute:    opAdd,
-		GasCost:    constGasFunc(t.GasTierVeryLow),
-		Name:       "ADD",
-		StackPops:  2,
-		StackPushs: 1,
+		Execute:     opAdd,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "ADD",
+		StackPops:   2,
+		StackPushs:  1,
 	},
 	t.MUL: {
-		Execute:    opMul,
-		GasCost:    constGasFunc(t.GasTierLow),
-		Name:       "MUL",
-		StackPops:  2,
-		StackPushs: 1,
+		Execute:     opMul,
+		ConstantGas: t.GasTierLow,
+		Name:        "MUL",
+		StackPops:   2,
+		StackPushs:  1,
 	},
 	t.SUB: {
-		Execute:    opSub,
-		GasCost:    constGasFunc(t.GasTierVeryLow),
-		Name:       "SUB",
-		StackPops:  2,
-		StackPushs: 1,
+		Execute:     opSub,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "SUB",
+		StackPops:   2,
+		StackPushs:  1,
 	},
 	t.DIV: {
-		Execute:    opDiv,
-		GasCost:    constGasFunc(t.GasTierLow),
-		Name:       "DIV",
-		StackPops:  2,
-		StackPushs: 1,
+		Execute:     opDiv,
+		ConstantGas: t.GasTierLow,
+		Name:        "DIV",
+		StackPops:   2,
+		StackPushs:  1,
 	},
 	t.MOD: {
-		Execute:    opMod,
-		GasCost:    constGasFunc(t.GasTierLow),
-		Name:       "MOD",
-		StackPops:  2,
-		StackPushs: 1,
+		Execute:     opMod,
+		ConstantGas: t.GasTierLow,
+		Name:        "MOD",
+		StackPops:   2,
+		StackPushs:  1,
 	},
 	t.EXP: {
-		Execute:    opExp,
-		GasCost:    gasExp,
-		Name:       "EXP",
-		StackPops:  2,
-		StackPushs: 1,
+		Execute:     opExp,
+		ConstantGas: t.GasTierLow,
+		DynamicGas:  gasExp,
+		Name:        "EXP",
+		StackPops:   2,
+		StackPushs:  1,
 	},
 	t.AND: {
-		Execute:    opAnd,
-		GasCost:    constGasFunc(t.GasTierVeryLow),
-		Name:       "AND",
-		StackPops:  2,
-		StackPushs: 1,
+		Execute:     opAnd,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "AND",
+		StackPops:   2,
+		StackPushs:  1,
 	},
 	t.OR: {
-		Execute:    opOr,
-		GasCost:    constGasFunc(t.GasTierVeryLow),
-		Name:       "OR",
-		StackPops:  2,
-		StackPushs: 1,
+		Execute:     opOr,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "OR",
+		StackPops:   2,
+		StackPushs:  1,
 	},
 	t.XOR: {
-		Execute:    opXor,
-		GasCost:    constGasFunc(t.GasTierVeryLow),
-		Name:       "XOR",
-		StackPops:  2,
-		StackPushs: 1,
+		Execute:     opXor,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "XOR",
+		StackPops:   2,
+		StackPushs:  1,
 	},
 	t.NOT: {
-		Execute:    opNot,
-		GasCost:    constGasFunc(t.GasTierVeryLow),
-		Name:       "NOT",
-		StackPops:  1,
-		StackPushs: 1,
+		Execute:     opNot,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "NOT",
+		StackPops:   1,
+		StackPushs:  1,
+	},
+	t.SDIV: {
+		Execute:     opSdiv,
+		ConstantGas: t.GasTierLow,
+		Name:        "SDIV",
+		StackPops:   2,
+		StackPushs:  1,
+	},
+	t.SMOD: {
+		Execute:     opSmod,
+		ConstantGas: t.GasTierLow,
+		Name:        "SMOD",
+		StackPops:   2,
+		StackPushs:  1,
+	},
+	t.ADDMOD: {
+		Execute:     opAddmod,
+		ConstantGas: t.GasTierMid,
+		Name:        "ADDMOD",
+		StackPops:   3,
+		StackPushs:  1,
+	},
+	t.MULMOD: {
+		Execute:     opMulmod,
+		ConstantGas: t.GasTierMid,
+		Name:        "MULMOD",
+		StackPops:   3,
+		StackPushs:  1,
+	},
+	t.SIGNEXTEND: {
+		Execute:     opSignExtend,
+		ConstantGas: t.GasTierLow,
+		Name:        "SIGNEXTEND",
+		StackPops:   2,
+		StackPushs:  1,
+	},
+	t.LT: {
+		Execute:     opLt,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "LT",
+		StackPops:   2,
+		StackPushs:  1,
+	},
+	t.GT: {
+		Execute:     opGt,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "GT",
+		StackPops:   2,
+		StackPushs:  1,
+	},
+	t.SLT: {
+		Execute:     opSlt,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "SLT",
+		StackPops:   2,
+		StackPushs:  1,
+	},
+	t.SGT: {
+		Execute:     opSgt,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "SGT",
+		StackPops:   2,
+		StackPushs:  1,
+	},
+	t.EQ: {
+		Execute:     opEq,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "EQ",
+		StackPops:   2,
+		StackPushs:  1,
+	},
+	t.ISZERO: {
+		Execute:     opIszero,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "ISZERO",
+		StackPops:   1,
+		StackPushs:  1,
+	},
+	t.BYTE: {
+		Execute:     opByte,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "BYTE",
+		StackPops:   2,
+		StackPushs:  1,
+	},
+	t.SHL: {
+		Execute:     opShl,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "SHL",
+		StackPops:   2,
+		StackPushs:  1,
+	},
+	t.SHR: {
+		Execute:     opShr,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "SHR",
+		StackPops:   2,
+		StackPushs:  1,
+	},
+	t.SAR: {
+		Execute:     opSar,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "SAR",
+		StackPops:   2,
+		StackPushs:  1,
+	},
+	t.KECCAK256: {
+		Execute:     opKeccak256,
+		ConstantGas: t.GasKeccak256,
+		DynamicGas:  gasKeccak256,
+		Name:        "KECCAK256",
+		StackPops:   2,
+		StackPushs:  1,
 	},
 	t.MLOAD: {
-		Execute:    opMload,
-		GasCost:    gasMLoad,
-		Name:       "MLOAD",
-		StackPops:  1,
-		StackPushs: 1,
+		Execute:     opMload,
+		ConstantGas: t.GasTierVeryLow,
+		DynamicGas:  gasMLoad,
+		Name:        "MLOAD",
+		StackPops:   1,
+		StackPushs:  1,
 	},
 	t.MSTORE: {
-		Execute:    opMstore,
-		GasCost:    gasMStore,
-		Name:       "MSTORE",
-		StackPops:  2,
-		StackPushs: 0,
+		Execute:     opMstore,
+		ConstantGas: t.GasTierVeryLow,
+		DynamicGas:  gasMStore,
+		Name:        "MSTORE",
+		StackPops:   2,
+		StackPushs:  0,
 	},
 	t.MSTORE8: {
-		Execute:    opMstore8,
-		GasCost:    gasMStore8,
-		Name:       "MSTORE8",
-		StackPops:  2,
-		StackPushs: 0,
+		Execute:     opMstore8,
+		ConstantGas: t.GasTierVeryLow,
+		DynamicGas:  gasMStore8,
+		Name:        "MSTORE8",
+		StackPops:   2,
+		StackPushs:  0,
 	},
 	t.JUMP: {
-		Execute:    opJump,
-		GasCost:    constGasFunc(t.GasTierMid),
-		Name:       "JUMP",
-		StackPops:  1,
-		StackPushs: 0,
+		Execute:     opJump,
+		ConstantGas: t.GasTierMid,
+		Name:        "JUMP",
+		StackPops:   1,
+		StackPushs:  0,
 	},
 	t.JUMPI: {
-		Execute:    opJumpi,
-		GasCost:    constGasFunc(t.GasTierHigh),
-		Name:       "JUMPI",
-		StackPops:  2,
-		StackPushs: 0,
+		Execute:     opJumpi,
+		ConstantGas: t.GasTierHigh,
+		Name:        "JUMPI",
+		StackPops:   2,
+		StackPushs:  0,
 	},
 	t.JUMPDEST: {
-		Execute:    opJumpdest,
-		GasCost:    constGasFunc(t.GasTierBase),
-		Name:       "JUMPDEST",
-		StackPops:  0,
-		StackPushs: 0,
+		Execute:     opJumpdest,
+		ConstantGas: t.GasTierBase,
+		Name:        "JUMPDEST",
+		StackPops:   0,
+		StackPushs:  0,
+	},
+	t.POP: {
+		Execute:     opPop,
+		ConstantGas: t.GasTierBase,
+		Name:        "POP",
+		StackPops:   1,
+		StackPushs:  0,
+	},
+	t.PC: {
+		Execute:     opPc,
+		ConstantGas: t.GasTierBase,
+		Name:        "PC",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.MSIZE: {
+		Execute:     opMsize,
+		ConstantGas: t.GasTierBase,
+		Name:        "MSIZE",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.GAS: {
+		Execute:     opGas,
+		ConstantGas: t.GasTierBase,
+		Name:        "GAS",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.PUSH0: {
+		Execute:     opPush0,
+		ConstantGas: t.GasTierBase,
+		Name:        "PUSH0",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.MCOPY: {
+		Execute:     opMcopy,
+		ConstantGas: t.GasTierVeryLow,
+		DynamicGas:  gasMCopy,
+		Name:        "MCOPY",
+		StackPops:   3,
+		StackPushs:  0,
+	},
+	t.TLOAD: {
+		Execute:     opTload,
+		ConstantGas: t.GasWarmAccess,
+		Name:        "TLOAD",
+		StackPops:   1,
+		StackPushs:  1,
+	},
+	t.TSTORE: {
+		Execute:     opTstore,
+		ConstantGas: t.GasWarmAccess,
+		Name:        "TSTORE",
+		StackPops:   2,
+		StackPushs:  0,
 	},
 	t.RETURN: {
-		Execute:    opReturn,
-		GasCost:    gasReturn,
-		Name:       "RETURN",
-		StackPops:  2,
-		StackPushs: 0,
+		Execute:     opReturn,
+		ConstantGas: t.GasTierVeryLow,
+		DynamicGas:  gasReturn,
+		Name:        "RETURN",
+		StackPops:   2,
+		StackPushs:  0,
+	},
+	t.REVERT: {
+		Execute:     opRevert,
+		ConstantGas: t.GasTierVeryLow,
+		DynamicGas:  gasReturn,
+		Name:        "REVERT",
+		StackPops:   2,
+		StackPushs:  0,
+	},
+	t.SELFDESTRUCT: {
+		Execute:     opSelfdestruct,
+		ConstantGas: t.GasSelfdestruct,
+		DynamicGas:  gasSelfdestruct,
+		Name:        "SELFDESTRUCT",
+		StackPops:   1,
+		StackPushs:  0,
 	},
 	t.SLOAD: {
-		Execute:    opSload,
-		GasCost:    constGasFunc(t.GasTierSLoad),
-		Name:       "SLOAD",
-		StackPops:  1,
-		StackPushs: 1,
+		Execute:     opSload,
+		ConstantGas: t.GasWarmAccess,
+		DynamicGas:  gasSload,
+		Name:        "SLOAD",
+		StackPops:   1,
+		StackPushs:  1,
 	},
 	t.SSTORE: {
 		Execute:    opSstore,
-		GasCost:    gasSstore,
+		DynamicGas: gasSstore,
 		Name:       "SSTORE",
 		StackPops:  2,
 		StackPushs: 0,
 	},
+	t.EXTCODESIZE: {
+		Execute:    opExtCodeSize,
+		DynamicGas: gasAccountAccess,
+		Name:       "EXTCODESIZE",
+		StackPops:  1,
+		StackPushs: 1,
+	},
+	t.EXTCODECOPY: {
+		Execute:    opExtCodeCopy,
+		DynamicGas: gasExtCodeCopy,
+		Name:       "EXTCODECOPY",
+		StackPops:  4,
+		StackPushs: 0,
+	},
+	t.EXTCODEHASH: {
+		Execute:    opExtCodeHash,
+		DynamicGas: gasAccountAccess,
+		Name:       "EXTCODEHASH",
+		StackPops:  1,
+		StackPushs: 1,
+	},
+	t.RETURNDATASIZE: {
+		Execute:     opReturnDataSize,
+		ConstantGas: t.GasTierBase,
+		Name:        "RETURNDATASIZE",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.RETURNDATACOPY: {
+		Execute:     opReturnDataCopy,
+		ConstantGas: t.GasTierVeryLow,
+		DynamicGas:  gasReturnDataCopy,
+		Name:        "RETURNDATACOPY",
+		StackPops:   3,
+		StackPushs:  0,
+	},
+	t.BLOBHASH: {
+		Execute:     opBlobHash,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "BLOBHASH",
+		StackPops:   1,
+		StackPushs:  1,
+	},
+	t.BLOBBASEFEE: {
+		Execute:     opBlobBaseFee,
+		ConstantGas: t.GasTierBase,
+		Name:        "BLOBBASEFEE",
+		StackPops:   0,
+		StackPushs:  1,
+	},
 	t.CALLVALUE: {
-		Execute:    opCallValue,
-		GasCost:    constGasFunc(t.GasTierBase),
-		Name:       "CALLVALUE",
-		StackPops:  0,
+		Execute:     opCallValue,
+		ConstantGas: t.GasTierBase,
+		Name:        "CALLVALUE",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.CALLDATALOAD: {
+		Execute:     opCallDataLoad,
+		ConstantGas: t.GasTierVeryLow,
+		Name:        "CALLDATALOAD",
+		StackPops:   1,
+		StackPushs:  1,
+	},
+	t.CALLDATASIZE: {
+		Execute:     opCallDataSize,
+		ConstantGas: t.GasTierBase,
+		Name:        "CALLDATASIZE",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.CALLDATACOPY: {
+		Execute:     opCallDataCopy,
+		ConstantGas: t.GasTierVeryLow,
+		DynamicGas:  gasCallDataCopy,
+		Name:        "CALLDATACOPY",
+		StackPops:   3,
+		StackPushs:  0,
+	},
+	t.CODESIZE: {
+		Execute:     opCodeSize,
+		ConstantGas: t.GasTierBase,
+		Name:        "CODESIZE",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.CODECOPY: {
+		Execute:     opCodeCopy,
+		ConstantGas: t.GasTierVeryLow,
+		DynamicGas:  gasCodeCopy,
+		Name:        "CODECOPY",
+		StackPops:   3,
+		StackPushs:  0,
+	},
+	t.BALANCE: {
+		Execute:    opBalance,
+		DynamicGas: gasAccountAccess,
+		Name:       "BALANCE",
+		StackPops:  1,
 		StackPushs: 1,
 	},
+	t.ADDRESS: {
+		Execute:     opAddress,
+		ConstantGas: t.GasTierBase,
+		Name:        "ADDRESS",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.ORIGIN: {
+		Execute:     opOrigin,
+		ConstantGas: t.GasTierBase,
+		Name:        "ORIGIN",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.CALLER: {
+		Execute:     opCaller,
+		ConstantGas: t.GasTierBase,
+		Name:        "CALLER",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.GASPRICE: {
+		Execute:     opGasPrice,
+		ConstantGas: t.GasTierBase,
+		Name:        "GASPRICE",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.SELFBALANCE: {
+		Execute:     opSelfBalance,
+		ConstantGas: t.GasTierLow,
+		Name:        "SELFBALANCE",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.BLOCKHASH: {
+		Execute:     opBlockHash,
+		ConstantGas: t.GasBlockhash,
+		Name:        "BLOCKHASH",
+		StackPops:   1,
+		StackPushs:  1,
+	},
+	t.COINBASE: {
+		Execute:     opCoinbase,
+		ConstantGas: t.GasTierBase,
+		Name:        "COINBASE",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.TIMESTAMP: {
+		Execute:     opTimestamp,
+		ConstantGas: t.GasTierBase,
+		Name:        "TIMESTAMP",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.NUMBER: {
+		Execute:     opNumber,
+		ConstantGas: t.GasTierBase,
+		Name:        "NUMBER",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.PREVRANDAO: {
+		Execute:     opPrevRandao,
+		ConstantGas: t.GasTierBase,
+		Name:        "PREVRANDAO",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.GASLIMIT: {
+		Execute:     opGasLimit,
+		ConstantGas: t.GasTierBase,
+		Name:        "GASLIMIT",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.CHAINID: {
+		Execute:     opChainID,
+		ConstantGas: t.GasTierBase,
+		Name:        "CHAINID",
+		StackPops:   0,
+		StackPushs:  1,
+	},
+	t.BASEFEE: {
+		Execute:     opBaseFee,
+		ConstantGas: t.GasTierBase,
+		Name:        "BASEFEE",
+		StackPops:   0,
+		StackPushs:  1,
+	},
 }
 
+// jumpTable holds the instructions of InstructionTable indexed by opcode, nil for
+// undefined opcodes. The interpreter looks instructions up here, indexing an array
+// on every step is much cheaper than a map lookup.
+var jumpTable [256]*Instruction
+
 // Initialize PUSH operations (PUSH1-PUSH32)
 func init() {
 	// Add PUSH1 to PUSH32 to the instruction table
 	for i := 1; i <= 32; i++ {
 		pushOp := t.Opcode(int(t.PUSH1) + i - 1)
 		InstructionTable[pushOp] = Instruction{
-			Execute:    makePush(i),
-			GasCost:    constGasFunc(t.GasTierVeryLow),
-			Name:       fmt.Sprintf("PUSH%d", i),
-			StackPops:  0,
-			StackPushs: 1,
+			Execute:     makePush(i),
+			ConstantGas: t.GasTierVeryLow,
+			Name:        fmt.Sprintf("PUSH%d", i),
+			StackPops:   0,
+			StackPushs:  1,
+			Immediate:   i,
 		}
 	}
 
@@ -198,11 +615,24 @@ func init() {
 	for i := 1; i <= 16; i++ {
 		dupOp := t.Opcode(int(t.DUP1) + i - 1)
 		InstructionTable[dupOp] = Instruction{
-			Execute:    makeDup(i),
-			GasCost:    constGasFunc(t.GasTierVeryLow),
-			Name:       fmt.Sprintf("DUP%d", i),
-			StackPops:  i,
-			StackPushs: i + 1,
+			Execute:     makeDup(i),
+			ConstantGas: t.GasTierVeryLow,
+			Name:        fmt.Sprintf("DUP%d", i),
+			StackPops:   i,
+			StackPushs:  i + 1,
+		}
+	}
+
+	// Add LOG0 to LOG4 to the instruction table
+	for i := 0; i <= 4; i++ {
+		logOp := t.Opcode(int(t.LOG0) + i)
+		InstructionTable[logOp] = Instruction{
+			Execute:     makeLog(i),
+			ConstantGas: t.GasLog + uint64(i)*t.GasLogTopic,
+			DynamicGas:  gasLog,
+			Name:        fmt.Sprintf("LOG%d", i),
+			StackPops:   i + 2,
+			StackPushs:  0,
 		}
 	}
 
@@ -210,19 +640,64 @@ func init() {
 	for i := 1; i <= 16; i++ {
 		swapOp := t.Opcode(int(t.SWAP1) + i - 1)
 		InstructionTable[swapOp] = Instruction{
-			Execute:    makeSwap(i),
-			GasCost:    constGasFunc(t.GasTierVeryLow),
-			Name:       fmt.Sprintf("SWAP%d", i),
-			StackPops:  i + 1,
-			StackPushs: i + 1,
+			Execute:     makeSwap(i),
+			ConstantGas: t.GasTierVeryLow,
+			Name:        fmt.Sprintf("SWAP%d", i),
+			StackPops:   i + 1,
+			StackPushs:  i + 1,
 		}
 	}
-}
 
-// Helper function for fixed gas costs
-func constGasFunc(gas uint64) func(*ExecutionContext) uint64 {
-	return func(*ExecutionContext) uint64 {
-		return gas
+	// The call and create operations are registered here because they run the
+	// interpreter recursively, which would make the table literal refer to itself
+	InstructionTable[t.CALL] = Instruction{
+		Execute:    opCall,
+		DynamicGas: makeGasCall(t.CALL),
+		Name:       "CALL",
+		StackPops:  7,
+		StackPushs: 1,
+	}
+	InstructionTable[t.CALLCODE] = Instruction{
+		Execute:    opCallCode,
+		DynamicGas: makeGasCall(t.CALLCODE),
+		Name:       "CALLCODE",
+		StackPops:  7,
+		StackPushs: 1,
+	}
+	InstructionTable[t.DELEGATECALL] = Instruction{
+		Execute:    opDelegateCall,
+		DynamicGas: makeGasCall(t.DELEGATECALL),
+		Name:       "DELEGATECALL",
+		StackPops:  6,
+		StackPushs: 1,
+	}
+	InstructionTable[t.STATICCALL] = Instruction{
+		Execute:    opStaticCall,
+		DynamicGas: makeGasCall(t.STATICCALL),
+		Name:       "STATICCALL",
+		StackPops:  6,
+		StackPushs: 1,
+	}
+
+	InstructionTable[t.CREATE] = Instruction{
+		Execute:     opCreate,
+		ConstantGas: t.GasCreate,
+		DynamicGas:  makeGasCreate(t.CREATE),
+		Name:        "CREATE",
+		StackPops:   3,
+		StackPushs:  1,
+	}
+	InstructionTable[t.CREATE2] = Instruction{
+		Execute:     opCreate2,
+		ConstantGas: t.GasCreate,
+		DynamicGas:  makeGasCreate(t.CREATE2),
+		Name:        "CREATE2",
+		StackPops:   4,
+		StackPushs:  1,
+	}
+
+	for op, instruction := range InstructionTable {
+		jumpTable[op] = &instruction
 	}
 }
 
@@ -236,337 +711,614 @@ func opStop(ctx *ExecutionContext) error {
 
 // ADD implements x + y
 func opAdd(ctx *ExecutionContext) error {
-	// Pop the last two elements off the stack for this operation
+	// Pop x and compute the result in place of y, on top of the stack
 	x, err := ctx.Stack.Pop()
 	if err != nil {
 		return err
 	}
 
-	y, err := ctx.Stack.Pop()
+	y, err := ctx.Stack.Peek()
 	if err != nil {
 		return err
 	}
 
-	// Add the two numbers
-	result := uint256.NewInt(0)
-	result.Add(x, y)
-
-	return ctx.Stack.Push(result)
+	y.Add(&x, y)
+	return nil
 }
 
 // MUL implements x * y
 func opMul(ctx *ExecutionContext) error {
-	// Pop the last two elements off the stack for this operation
+	// Pop x and compute the result in place of y, on top of the stack
 	x, err := ctx.Stack.Pop()
 	if err != nil {
 		return err
 	}
 
-	y, err := ctx.Stack.Pop()
+	y, err := ctx.Stack.Peek()
 	if err != nil {
 		return err
 	}
 
-	// Multiply the two numbers
-	result := uint256.NewInt(0)
-	result.Mul(x, y)
-
-	return ctx.Stack.Push(result)
+	y.Mul(&x, y)
+	return nil
 }
 
 // SUB implements x - y
 func opSub(ctx *ExecutionContext) error {
-	// Pop the last two elements off the stack for this operation
+	// Pop x and compute the result in place of y, on top of the stack
 	x, err := ctx.Stack.Pop()
 	if err != nil {
 		return err
 	}
 
-	y, err := ctx.Stack.Pop()
+	y, err := ctx.Stack.Peek()
 	if err != nil {
 		return err
 	}
 
-	// Subtract the two numbers
-	result := uint256.NewInt(0)
-	result.Sub(x, y)
-
-	return ctx.Stack.Push(result)
+	y.Sub(&x, y)
+	return nil
 }
 
-// DIV implements x / y
+// DIV implements x / y, zero when y is zero
 func opDiv(ctx *ExecutionContext) error {
-	// Pop the last two elements off the stack for this operation
+	// Pop x and compute the result in place of y, on top of the stack
 	x, err := ctx.Stack.Pop()
 	if err != nil {
 		return err
 	}
 
-	y, err := ctx.Stack.Pop()
+	y, err := ctx.Stack.Peek()
 	if err != nil {
 		return err
 	}
 
-	// Handle division by zero
-	result := uint256.NewInt(0)
-	if y.IsZero() {
-		return ctx.Stack.Push(result)
-	}
-
-	// Divide the two numbers
-	result.Div(x, y)
-
-	return ctx.Stack.Push(result)
+	// Division by zero yields zero
+	y.Div(&x, y)
+	return nil
 }
 
 // Gas cost function for E
-func gasExp(ctx *ExecutionContext) uint64 {
+func gasExp(ctx *ExecutionContext) (uint64, error) {
 	// Check if we can access the stack safely
 	if ctx.Stack.Size() < 2 {
-		return 0
+		return 0, nil
 	}
 
 	// The exponent (y in x^y) is the 2nd item on the stack
 	exponent, err := ctx.Stack.GetItem(1) // 1 is the second item from the top (0-indexed)
 	if err != nil {
-		return 0
+		return 0, nil
 	}
 
 	exponentBytesLen := uint64((exponent.BitLen() + 7) / 8)
 
-	// Base cost + cost per byte in the exponent
-	return t.GasTierLow + t.GasTierLow*exponentBytesLen
+	// Cost per byte in the exponent, on top of the base cost
+	return t.GasTierLow * exponentBytesLen, nil
 }
 
-// MOD implements x % y
+// MOD implements x % y, zero when y is zero
 func opMod(ctx *ExecutionContext) error {
-	// Pop the last two elements off the stack for this operation
+	// Pop x and compute the result in place of y, on top of the stack
 	x, err := ctx.Stack.Pop()
 	if err != nil {
 		return err
 	}
 
-	y, err := ctx.Stack.Pop()
+	y, err := ctx.Stack.Peek()
 	if err != nil {
 		return err
 	}
 
-	// Handle modulo by zero
-	result := uint256.NewInt(0)
-	if y.IsZero() {
-		return ctx.Stack.Push(result)
-	}
-
-	// Modulo the two numbers
-	result.Mod(x, y)
-
-	return ctx.Stack.Push(result)
+	// Modulo by zero yields zero
+	y.Mod(&x, y)
+	return nil
 }
 
 // EXP implements x^y (x to the power of y)
 func opExp(ctx *ExecutionContext) error {
-	// Pop the last two elements off the stack for this operation
+	// Pop the base and compute the result in place of the exponent
 	base, err := ctx.Stack.Pop()
 	if err != nil {
 		return err
 	}
 
-	exponent, err := ctx.Stack.Pop()
+	exponent, err := ctx.Stack.Peek()
 	if err != nil {
 		return err
 	}
 
-	// Calculate the exponentiation
-	result := uint256.NewInt(0)
-	if exponent.IsZero() {
-		result = uint256.NewInt(1)
-	} else if base.IsZero() {
-		result = uint256.NewInt(0)
-	} else {
-		result.Exp(base, exponent)
-	}
-
-	return ctx.Stack.Push(result)
+	exponent.Exp(&base, exponent)
+	return nil
 }
 
 // AND implements x & y (bitwise AND)
 func opAnd(ctx *ExecutionContext) error {
+	// Pop x and compute the result in place of y, on top of the stack
 	x, err := ctx.Stack.Pop()
 	if err != nil {
 		return err
 	}
 
-	y, err := ctx.Stack.Pop()
+	y, err := ctx.Stack.Peek()
 	if err != nil {
 		return err
 	}
 
-	// Bitwise AND
-	result := uint256.NewInt(0)
-	result.And(x, y)
-
-	return ctx.Stack.Push(result)
+	y.And(&x, y)
+	return nil
 }
 
 // OR implements x | y (bitwise OR)
 func opOr(ctx *ExecutionContext) error {
-	// Pop the last two elements off the stack for this operation
+	// Pop x and compute the result in place of y, on top of the stack
 	x, err := ctx.Stack.Pop()
 	if err != nil {
 		return err
 	}
 
-	y, err := ctx.Stack.Pop()
+	y, err := ctx.Stack.Peek()
 	if err != nil {
 		return err
 	}
 
-	// Bitwise OR
-	result := uint256.NewInt(0)
-	result.Or(x, y)
-
-	return ctx.Stack.Push(result)
+	y.Or(&x, y)
+	return nil
 }
 
 // XOR implements x ^ y (bitwise XOR)
 func opXor(ctx *ExecutionContext) error {
-	// Pop the last two elements off the stack for this operation
+	// Pop x and compute the result in place of y, on top of the stack
 	x, err := ctx.Stack.Pop()
 	if err != nil {
 		return err
 	}
 
-	y, err := ctx.Stack.Pop()
+	y, err := ctx.Stack.Peek()
 	if err != nil {
 		return err
 	}
 
-	// Bitwise XOR
-	result := uint256.NewInt(0)
-	result.Xor(x, y)
-
-	return ctx.Stack.Push(result)
+	y.Xor(&x, y)
+	return nil
 }
 
 // NOT implements ~x (bitwise NOT)
 func opNot(ctx *ExecutionContext) error {
-	// Pop the last element off the stack for this operation
-	x, err := ctx.Stack.Pop()
+	x, err := ctx.Stack.Peek()
 	if err != nil {
 		return err
 	}
 
-	// Bitwise NOT
-	result := uint256.NewInt(0)
-	result.Not(x)
-
-	return ctx.Stack.Push(result)
+	x.Not(x)
+	return nil
 }
 
-// ===== Memory Operations =====
-
-// Gas cost calculation for memory operations
-func memoryGasCost(ctx *ExecutionContext, additionalSize uint64) uint64 {
-	oldSize := ctx.Memory.Size()
-	newSize := oldSize
-
-	if additionalSize > 0 {
-		newSize = additionalSize
-		if newSize < oldSize {
-			newSize = oldSize
-		}
+// SDIV implements x / y for signed integers, zero when y is zero
+func opSdiv(ctx *ExecutionContext) error {
+	// Pop x and compute the result in place of y, on top of the stack
+	x, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
 	}
 
-	// If memory doesn't expand, no additional cost
-	if newSize <= oldSize {
-		return t.GasTierVeryLow // Base cost for the operation
+	y, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
 	}
 
-	// Calculate memory expansion cost
-	memoryCost := t.CalculateMemoryGasCost(oldSize, newSize)
-	return t.GasTierVeryLow + memoryCost
+	y.SDiv(&x, y)
+	return nil
 }
 
-// Gas cost for MLOAD
-func gasMLoad(ctx *ExecutionContext) uint64 {
-	// Check if we can access the stack
-	if ctx.Stack.Size() < 1 {
-		return 0
+// SMOD implements x % y for signed integers, with the sign of x, zero when y is zero
+func opSmod(ctx *ExecutionContext) error {
+	// Pop x and compute the result in place of y, on top of the stack
+	x, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
 	}
 
-	offset, err := ctx.Stack.GetItem(0)
+	y, err := ctx.Stack.Peek()
 	if err != nil {
-		return 0
+		return err
 	}
 
-	// Gas cost is base cost + memory expansion cost if applicable
-	// We need to load 32 bytes from the offset
-	return memoryGasCost(ctx, offset.Uint64()+32)
+	y.SMod(&x, y)
+	return nil
 }
 
-// Gas cost for MSTORE
-func gasMStore(ctx *ExecutionContext) uint64 {
-	// Check if we can access the stack
-	if ctx.Stack.Size() < 2 {
-		return 0
+// ADDMOD implements (x + y) % m without overflow, zero when m is zero
+func opAddmod(ctx *ExecutionContext) error {
+	x, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	y, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
 	}
 
-	offset, err := ctx.Stack.GetItem(0)
+	m, err := ctx.Stack.Peek()
 	if err != nil {
-		return 0
+		return err
 	}
 
-	// Gas cost is base cost + memory expansion cost if applicable
-	// We need to store 32 bytes at the offset
-	return memoryGasCost(ctx, offset.Uint64()+32)
+	m.AddMod(&x, &y, m)
+	return nil
 }
 
-// Gas cost for MSTORE8
-func gasMStore8(ctx *ExecutionContext) uint64 {
-	// Check if we can access the stack
-	if ctx.Stack.Size() < 2 {
-		return 0
+// MULMOD implements (x * y) % m without overflow, zero when m is zero
+func opMulmod(ctx *ExecutionContext) error {
+	x, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	y, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
 	}
 
-	offset, err := ctx.Stack.GetItem(0)
+	m, err := ctx.Stack.Peek()
 	if err != nil {
-		return 0
+		return err
 	}
 
-	// Gas cost is base cost + memory expansion cost if applicable
-	// We need to store 1 byte at the offset
-	return memoryGasCost(ctx, offset.Uint64()+1)
+	m.MulMod(&x, &y, m)
+	return nil
 }
 
-// MLOAD implements load word from memory
-func opMload(ctx *ExecutionContext) error {
-	// Pop the last element off the stack for this operation
-	offset, err := ctx.Stack.Pop()
+// SIGNEXTEND extends the sign of the integer held in the low b+1 bytes of x
+func opSignExtend(ctx *ExecutionContext) error {
+	b, err := ctx.Stack.Pop()
 	if err != nil {
 		return err
 	}
 
-	// Load 32 bytes from memory at offset
-	value := ctx.Memory.Mload(offset.Uint64())
-	if value == nil {
-		value = make([]byte, 32)
+	x, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
 	}
 
-	// Convert bytes to uint256
-	result := uint256.NewInt(0)
-	result.SetBytes(value)
+	x.ExtendSign(x, &b)
+	return nil
+}
 
-	return ctx.Stack.Push(result)
+// ===== Comparison and Bitwise Operations =====
+
+// setBool sets x to 1 if cond holds, 0 otherwise
+func setBool(x *uint256.Int, cond bool) {
+	if cond {
+		x.SetOne()
+	} else {
+		x.Clear()
+	}
 }
 
-// MSTORE implements save word to memory
-func opMstore(ctx *ExecutionContext) error {
-	offset, err := ctx.Stack.Pop()
+// LT implements x < y
+func opLt(ctx *ExecutionContext) error {
+	// Pop x and compute the result in place of y, on top of the stack
+	x, err := ctx.Stack.Pop()
 	if err != nil {
 		return err
 	}
 
-	value, err := ctx.Stack.Pop()
+	y, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	setBool(y, x.Lt(y))
+	return nil
+}
+
+// GT implements x > y
+func opGt(ctx *ExecutionContext) error {
+	// Pop x and compute the result in place of y, on top of the stack
+	x, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	y, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	setBool(y, x.Gt(y))
+	return nil
+}
+
+// SLT implements x < y for signed integers
+func opSlt(ctx *ExecutionContext) error {
+	// Pop x and compute the result in place of y, on top of the stack
+	x, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	y, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	setBool(y, x.Slt(y))
+	return nil
+}
+
+// SGT implements x > y for signed integers
+func opSgt(ctx *ExecutionContext) error {
+	// Pop x and compute the result in place of y, on top of the stack
+	x, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	y, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	setBool(y, x.Sgt(y))
+	return nil
+}
+
+// EQ implements x == y
+func opEq(ctx *ExecutionContext) error {
+	// Pop x and compute the result in place of y, on top of the stack
+	x, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	y, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	setBool(y, x.Eq(y))
+	return nil
+}
+
+// ISZERO implements x == 0
+func opIszero(ctx *ExecutionContext) error {
+	x, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	setBool(x, x.IsZero())
+	return nil
+}
+
+// BYTE pushes the i-th byte of x, counting from the most significant byte, zero
+// when i is 32 or more
+func opByte(ctx *ExecutionContext) error {
+	i, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	x, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	x.Byte(&i)
+	return nil
+}
+
+// SHL implements x << shift, zero when shift is 256 or more
+func opShl(ctx *ExecutionContext) error {
+	shift, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	x, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	if shift.LtUint64(256) {
+		x.Lsh(x, uint(shift.Uint64()))
+	} else {
+		x.Clear()
+	}
+	return nil
+}
+
+// SHR implements x >> shift, zero when shift is 256 or more
+func opShr(ctx *ExecutionContext) error {
+	shift, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	x, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	if shift.LtUint64(256) {
+		x.Rsh(x, uint(shift.Uint64()))
+	} else {
+		x.Clear()
+	}
+	return nil
+}
+
+// SAR implements x >> shift for signed integers, filling with the sign bit
+func opSar(ctx *ExecutionContext) error {
+	shift, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	x, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	if shift.LtUint64(256) {
+		x.SRsh(x, uint(shift.Uint64()))
+	} else if x.Sign() >= 0 {
+		x.Clear()
+	} else {
+		x.SetAllOne()
+	}
+	return nil
+}
+
+// ===== Hashing Operations =====
+
+// Gas cost for KECCAK256, on top of its base cost
+func gasKeccak256(ctx *ExecutionContext) (uint64, error) {
+	if ctx.Stack.Size() < 2 {
+		return 0, nil
+	}
+
+	offset, _ := ctx.Stack.GetItem(0)
+	size, _ := ctx.Stack.GetItem(1)
+
+	wordCost, err := wordGasCost(size, t.GasKeccak256Word)
+	if err != nil {
+		return 0, err
+	}
+	memoryCost, err := memoryExpansionCost(ctx, offset, size)
+	if err != nil {
+		return 0, err
+	}
+	return safeAdd(wordCost, memoryCost)
+}
+
+// KECCAK256 pushes the Keccak-256 hash of a region of memory
+func opKeccak256(ctx *ExecutionContext) error {
+	offset, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	// The hash replaces the size on top of the stack
+	size, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	data := ctx.Memory.Expand(offset.Uint64(), size.Uint64())
+	size.SetBytes(crypto.Keccak256(data))
+	return nil
+}
+
+// ===== Memory Operations =====
+
+// maxMemorySize is the largest memory size whose expansion cost fits in 64 bits.
+// Larger sizes could never be paid for and are rejected as out of gas.
+const maxMemorySize = 0x1FFFFFFFE0
+
+// memoryGasCost returns the cost of expanding memory to cover size bytes at offset,
+// zero if it is large enough. It fails with ErrOutOfGas when the end of the region
+// does not fit in 64 bits or exceeds maxMemorySize.
+func memoryGasCost(ctx *ExecutionContext, offset *uint256.Int, size uint64) (uint64, error) {
+	// Accessing no bytes does not touch memory, whatever the offset
+	if size == 0 {
+		return 0, nil
+	}
+	if !offset.IsUint64() {
+		return 0, ErrOutOfGas
+	}
+	newSize := offset.Uint64() + size
+	if newSize < size || newSize > maxMemorySize {
+		return 0, ErrOutOfGas
+	}
+
+	oldSize := ctx.Memory.Size()
+	if newSize <= oldSize {
+		return 0, nil
+	}
+	return t.CalculateMemoryGasCost(oldSize, newSize), nil
+}
+
+// Gas cost for MLOAD
+func gasMLoad(ctx *ExecutionContext) (uint64, error) {
+	// Check if we can access the stack
+	if ctx.Stack.Size() < 1 {
+		return 0, nil
+	}
+
+	offset, err := ctx.Stack.GetItem(0)
+	if err != nil {
+		return 0, nil
+	}
+
+	// Memory expansion cost if applicable
+	// We need to load 32 bytes from the offset
+	return memoryGasCost(ctx, offset, 32)
+}
+
+// Gas cost for MSTORE
+func gasMStore(ctx *ExecutionContext) (uint64, error) {
+	// Check if we can access the stack
+	if ctx.Stack.Size() < 2 {
+		return 0, nil
+	}
+
+	offset, err := ctx.Stack.GetItem(0)
+	if err != nil {
+		return 0, nil
+	}
+
+	// Memory expansion cost if applicable
+	// We need to store 32 bytes at the offset
+	return memoryGasCost(ctx, offset, 32)
+}
+
+// Gas cost for MSTORE8
+func gasMStore8(ctx *ExecutionContext) (uint64, error) {
+	// Check if we can access the stack
+	if ctx.Stack.Size() < 2 {
+		return 0, nil
+	}
+
+	offset, err := ctx.Stack.GetItem(0)
+	if err != nil {
+		return 0, nil
+	}
+
+	// Memory expansion cost if applicable
+	// We need to store 1 byte at the offset
+	return memoryGasCost(ctx, offset, 1)
+}
+
+// MLOAD implements load word from memory
+func opMload(ctx *ExecutionContext) error {
+	// Pop the last element off the stack for this operation
+	offset, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	// Load 32 bytes from memory at offset
+	value := ctx.Memory.Mload(offset.Uint64())
+	if value == nil {
+		value = make([]byte, 32)
+	}
+
+	// Convert bytes to uint256
+	result := uint256.NewInt(0)
+	result.SetBytes(value)
+
+	return ctx.Stack.Push(result)
+}
+
+// MSTORE implements save word to memory
+func opMstore(ctx *ExecutionContext) error {
+	offset, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	value, err := ctx.Stack.Pop()
 	if err != nil {
 		return err
 	}
@@ -602,18 +1354,55 @@ func opMstore8(ctx *ExecutionContext) error {
 	return nil
 }
 
-// ===== Control Flow Operations =====
+// MSIZE pushes the size of the memory in bytes, rounded up to a whole word
+func opMsize(ctx *ExecutionContext) error {
+	size := (ctx.Memory.Size() + 31) / 32 * 32
+	return ctx.Stack.Push(uint256.NewInt(size))
+}
+
+// Gas cost for MCOPY. Memory is expanded to cover the later of the source and
+// destination regions.
+func gasMCopy(ctx *ExecutionContext) (uint64, error) {
+	if ctx.Stack.Size() < 3 {
+		return 0, nil
+	}
 
-// validateJumpDest checks if the destination is a valid JUMPDEST
-func validateJumpDest(ctx *ExecutionContext, dest uint64) bool {
-	if dest >= uint64(len(ctx.ByteCode)) {
-		return false
+	dstOffset, _ := ctx.Stack.GetItem(0)
+	srcOffset, _ := ctx.Stack.GetItem(1)
+	size, _ := ctx.Stack.GetItem(2)
+
+	offset := dstOffset
+	if srcOffset.Gt(dstOffset) {
+		offset = srcOffset
+	}
+	return memoryCopyCost(ctx, offset, size)
+}
+
+// MCOPY copies a region of memory to another, which may overlap it (EIP-5656)
+func opMcopy(ctx *ExecutionContext) error {
+	dstOffset, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	srcOffset, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	size, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
 	}
 
-	// Check if the destination is a JUMPDEST opcode
-	return t.Opcode(ctx.ByteCode[dest]) == t.JUMPDEST
+	if size.IsZero() {
+		return nil
+	}
+	data := common.CopyBytes(ctx.Memory.Expand(srcOffset.Uint64(), size.Uint64()))
+	ctx.Memory.Mstore(dstOffset.Uint64(), data)
+	return nil
 }
 
+// ===== Control Flow Operations =====
+
 // JUMP implements unconditional jump
 func opJump(ctx *ExecutionContext) error {
 	// Pop the destination from the stack
@@ -622,17 +1411,14 @@ func opJump(ctx *ExecutionContext) error {
 		return err
 	}
 
-	// Convert the destination to uint64
-	jumpDest := dest.Uint64()
-
 	// Validate jump destination
-	if !validateJumpDest(ctx, jumpDest) {
-		return errors.New("invalid jump destination")
+	if !ctx.validJumpDest(&dest) {
+		return ErrInvalidJump
 	}
 
 	// Set the program counter to the destination
 	// Subtract 1 because the PC will be incremented after this instruction
-	ctx.ProgramCounter = jumpDest
+	ctx.ProgramCounter = dest.Uint64()
 	return nil
 }
 
@@ -651,17 +1437,14 @@ func opJumpi(ctx *ExecutionContext) error {
 
 	// If condition is true, jump to destination
 	if !cond.IsZero() {
-		// Convert the destination to uint64
-		jumpDest := dest.Uint64()
-
 		// Validate jump destination
-		if !validateJumpDest(ctx, jumpDest) {
-			return errors.New("invalid jump destination")
+		if !ctx.validJumpDest(&dest) {
+			return ErrInvalidJump
 		}
 
 		// Set the program counter to the destination
 		// Subtract 1 because the PC will be incremented after this instruction
-		ctx.ProgramCounter = jumpDest
+		ctx.ProgramCounter = dest.Uint64()
 	}
 	return nil
 }
@@ -673,25 +1456,25 @@ func opJumpdest(ctx *ExecutionContext) error {
 }
 
 // Gas cost for RETURN
-func gasReturn(ctx *ExecutionContext) uint64 {
+func gasReturn(ctx *ExecutionContext) (uint64, error) {
 	// Check if we can access the stack
 	if ctx.Stack.Size() < 2 {
-		return 0
+		return 0, nil
 	}
 
 	// Get the offset and size from the stack
 	offset, err := ctx.Stack.GetItem(0)
 	if err != nil {
-		return 0
+		return 0, nil
 	}
 
 	size, err := ctx.Stack.GetItem(1)
 	if err != nil {
-		return 0
+		return 0, nil
 	}
 
-	// Cost is base cost + memory expansion cost
-	return memoryGasCost(ctx, offset.Uint64()+size.Uint64())
+	// Memory expansion cost
+	return memoryExpansionCost(ctx, offset, size)
 }
 
 // RETURN stops execution and returns data from memory
@@ -730,25 +1513,50 @@ func opReturn(ctx *ExecutionContext) error {
 	return nil
 }
 
+// REVERT stops execution, reverts state changes and returns data from memory
+func opRevert(ctx *ExecutionContext) error {
+	// REVERT reads its output exactly like RETURN
+	if err := opReturn(ctx); err != nil {
+		return err
+	}
+	return ErrExecutionReverted
+}
+
+// PC pushes the position of the PC instruction in the code
+func opPc(ctx *ExecutionContext) error {
+	// The program counter has already moved past the instruction
+	return ctx.Stack.Push(uint256.NewInt(ctx.ProgramCounter - 1))
+}
+
+// GAS pushes the gas left after paying for the GAS instruction
+func opGas(ctx *ExecutionContext) error {
+	return ctx.Stack.Push(uint256.NewInt(ctx.GasMeter.GasRemaining()))
+}
+
 // ===== Push Operations =====
 
+// PUSH0 pushes zero (EIP-3855)
+func opPush0(ctx *ExecutionContext) error {
+	return ctx.Stack.Push(uint256.NewInt(0))
+}
+
 // makePush creates a function to handle PUSH operations
 func makePush(size int) func(ctx *ExecutionContext) error {
 	return func(ctx *ExecutionContext) error {
 		// Check if there are enough bytes in the bytecode
 		if ctx.ProgramCounter+uint64(size) > uint64(len(ctx.ByteCode)) {
-			return errors.New("push: insufficient data in bytecode")
+			return ErrTruncatedPush
 		}
 
 		// Read 'size' bytes from bytecode
 		bytes := ctx.ByteCode[ctx.ProgramCounter : ctx.ProgramCounter+uint64(size)]
 
-		// Convert to uint256
-		value := uint256.NewInt(0)
+		// Convert to uint256, the stack keeps a copy so it does not escape
+		var value uint256.Int
 		value.SetBytes(bytes)
 
 		// Push value to stack
-		err := ctx.Stack.Push(value)
+		err := ctx.Stack.Push(&value)
 		if err != nil {
 			return err
 		}
@@ -762,6 +1570,12 @@ func makePush(size int) func(ctx *ExecutionContext) error {
 
 // ===== Stack Manipulation Operations =====
 
+// POP removes the top item of the stack
+func opPop(ctx *ExecutionContext) error {
+	_, err := ctx.Stack.Pop()
+	return err
+}
+
 // makeDup creates a function to handle DUP operations
 func makeDup(n int) func(ctx *ExecutionContext) error {
 	return func(ctx *ExecutionContext) error {
@@ -780,45 +1594,73 @@ func makeSwap(n int) func(ctx *ExecutionContext) error {
 
 // ===== Storage Operations =====
 
-// Gas cost for SSTORE
-func gasSstore(ctx *ExecutionContext) uint64 {
-	// Check if we can access the stack
-	if ctx.Stack.Size() < 2 {
-		return 0
+// warmSlot adds a storage slot of the current contract to the access list and
+// reports whether it was already there (EIP-2929). Bare runs have no access list
+// and all slots are warm.
+func warmSlot(ctx *ExecutionContext, key common.Hash) bool {
+	if ctx.EVM == nil {
+		return true
 	}
-
-	key, err := ctx.Stack.GetItem(0)
-	if err != nil {
-		return 0
+	if _, ok := ctx.EVM.State.SlotInAccessList(ctx.ContractAddress, key); ok {
+		return true
 	}
+	ctx.EVM.State.AddSlotToAccessList(ctx.ContractAddress, key)
+	return false
+}
 
-	val, err := ctx.Stack.GetItem(1)
-	if err != nil {
-		return 0
+// storageValues returns the value a storage slot of the current contract had at
+// the start of the transaction and its current value. Bare runs have no
+// transaction, the slot is considered unmodified.
+func storageValues(ctx *ExecutionContext, key common.Hash) (original, current common.Hash) {
+	current = common.BytesToHash(ctx.Storage.Sload(key))
+	if ctx.EVM == nil {
+		return current, current
 	}
+	return ctx.EVM.State.GetCommittedState(ctx.ContractAddress, key), current
+}
 
-	// Convert key to common.Hash
-	keyHash := common.BytesToHash(key.Bytes())
+// Gas cost for SLOAD, on top of the warm access: the access of the slot if it is cold
+func gasSload(ctx *ExecutionContext) (uint64, error) {
+	if ctx.Stack.Size() < 1 {
+		return 0, nil
+	}
 
-	// Check if value already exists in storage
-	currentValue := ctx.Storage.Sload(keyHash)
-	currentValUint := uint256.NewInt(0)
+	key, _ := ctx.Stack.GetItem(0)
+	if warmSlot(ctx, key.Bytes32()) {
+		return 0, nil
+	}
+	return t.GasColdSload - t.GasWarmAccess, nil
+}
 
-	if currentValue != nil {
-		currentValUint.SetBytes(currentValue)
+// Gas cost for SSTORE (EIP-2200 with the costs of EIP-2929 and EIP-3529). Only the
+// first write of a slot in a transaction pays for changing the stored value, later
+// ones cost a warm access. The refunds are granted by opSstore.
+func gasSstore(ctx *ExecutionContext) (uint64, error) {
+	if ctx.Stack.Size() < 2 {
+		return 0, nil
+	}
+	// Calls with only the stipend can not write to storage
+	if ctx.GasMeter.GasRemaining() <= t.GasSstoreSentry {
+		return 0, ErrOutOfGas
 	}
 
-	// Cost depends on whether we're setting a new value, updating, or clearing
-	if currentValue == nil && !val.IsZero() {
-		// Creating a new storage entry
-		return t.GasStorageSet
-	} else if !currentValUint.IsZero() && val.IsZero() {
-		// Clearing an existing entry (refund will be added separately)
-		return t.GasStorageUpdate
-	} else {
-		// Updating an existing entry
-		return t.GasStorageUpdate
+	key, _ := ctx.Stack.GetItem(0)
+	value, _ := ctx.Stack.GetItem(1)
+	slot := common.Hash(key.Bytes32())
+
+	cost := uint64(0)
+	if !warmSlot(ctx, slot) {
+		cost = t.GasColdSload
+	}
+
+	original, current := storageValues(ctx, slot)
+	if current == value.Bytes32() || original != current {
+		return cost + t.GasWarmAccess, nil
 	}
+	if original == (common.Hash{}) {
+		return cost + t.GasStorageSet, nil
+	}
+	return cost + t.GasStorageUpdate - t.GasColdSload, nil
 }
 
 // SLOAD implements load word from storage
@@ -844,8 +1686,13 @@ func opSload(ctx *ExecutionContext) error {
 	return ctx.Stack.Push(result)
 }
 
-// SSTORE implements store word to storage
+// SSTORE implements store word to storage. Clearing a slot is refunded, and so is
+// restoring the original value of a slot, minus the cost of a warm access.
 func opSstore(ctx *ExecutionContext) error {
+	if ctx.ReadOnly {
+		return ErrWriteProtection
+	}
+
 	// Pop key and value from stack
 	key, err := ctx.Stack.Pop()
 	if err != nil {
@@ -859,33 +1706,1012 @@ func opSstore(ctx *ExecutionContext) error {
 
 	// Convert key to common.Hash
 	keyHash := common.BytesToHash(key.Bytes())
-
-	// Check if value already exists in storage
-	currentValue := ctx.Storage.Sload(keyHash)
-	currentValUint := uint256.NewInt(0)
-	if currentValue != nil {
-		currentValUint.SetBytes(currentValue)
-	}
-
-	// Handle gas refund if we're clearing a storage slot
-	if !currentValUint.IsZero() && value.IsZero() {
-		ctx.GasMeter.RefundGas(t.GasStorageRefund)
+	newValue := common.Hash(value.Bytes32())
+
+	original, current := storageValues(ctx, keyHash)
+	if current != newValue {
+		if original != (common.Hash{}) {
+			if current == (common.Hash{}) {
+				// The slot was cleared earlier in the transaction and is set again
+				ctx.GasMeter.SubRefund(t.GasStorageClear)
+			} else if newValue == (common.Hash{}) {
+				ctx.GasMeter.RefundGas(t.GasStorageClear)
+			}
+		}
+		if original == newValue {
+			if original == (common.Hash{}) {
+				ctx.GasMeter.RefundGas(t.GasStorageSet - t.GasWarmAccess)
+			} else {
+				ctx.GasMeter.RefundGas(t.GasStorageUpdate - t.GasColdSload - t.GasWarmAccess)
+			}
+		}
 	}
 
 	// Store value
 	valueBytes := value.Bytes()
 	ctx.Storage.Sstore(keyHash, valueBytes[:])
+	if ctx.Tracer != nil && ctx.Tracer.OnStorageChange != nil {
+		ctx.Tracer.OnStorageChange(ctx.ContractAddress, keyHash, current, newValue)
+	}
+
+	return nil
+}
+
+// ===== Transient Storage Operations =====
+
+// TLOAD implements load word from transient storage (EIP-1153)
+func opTload(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+
+	// The value replaces the key on top of the stack
+	key, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
 
+	value := ctx.EVM.State.GetTransientState(ctx.ContractAddress, key.Bytes32())
+	key.SetBytes(value.Bytes())
 	return nil
 }
 
+// TSTORE implements store word to transient storage, which is discarded at the
+// end of the transaction
+func opTstore(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	if ctx.ReadOnly {
+		return ErrWriteProtection
+	}
+
+	key, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	value, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	ctx.EVM.State.SetTransientState(ctx.ContractAddress, key.Bytes32(), value.Bytes32())
+	return nil
+}
+
+// ===== Environment Operations =====
+
 func opCallValue(ctx *ExecutionContext) error {
 	// Get the value from the call
 	value := ctx.CallValue
 	if value == nil {
-		return errors.New("CallValue is nil")
+		return ErrNoCallValue
 	}
 
 	push_value := uint256.NewInt(0).Set(value)
 	return ctx.Stack.Push(push_value)
 }
+
+// ADDRESS pushes the address of the account executing the code
+func opAddress(ctx *ExecutionContext) error {
+	return ctx.Stack.Push(new(uint256.Int).SetBytes(ctx.ContractAddress.Bytes()))
+}
+
+// CALLER pushes the address of the account that called the current frame
+func opCaller(ctx *ExecutionContext) error {
+	return ctx.Stack.Push(new(uint256.Int).SetBytes(ctx.CallerAddress.Bytes()))
+}
+
+// ORIGIN pushes the sender of the transaction
+func opOrigin(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	return ctx.Stack.Push(new(uint256.Int).SetBytes(ctx.EVM.Tx.Origin.Bytes()))
+}
+
+// GASPRICE pushes the effective gas price of the transaction
+func opGasPrice(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	return ctx.Stack.Push(new(uint256.Int).Set(ctx.EVM.Tx.GasPrice))
+}
+
+// BALANCE pushes the balance of an account
+func opBalance(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+
+	// The balance replaces the address on top of the stack
+	address, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	address.Set(ctx.EVM.State.GetBalance(common.Address(address.Bytes20())))
+	return nil
+}
+
+// SELFBALANCE pushes the balance of the account executing the code (EIP-1884)
+func opSelfBalance(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	return ctx.Stack.Push(new(uint256.Int).Set(ctx.EVM.State.GetBalance(ctx.ContractAddress)))
+}
+
+// ===== Call Data Operations =====
+
+// CALLDATALOAD pushes the 32 bytes of the call data starting at an offset, padded
+// with zeros past the end
+func opCallDataLoad(ctx *ExecutionContext) error {
+	// The word replaces the offset on top of the stack
+	offset, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	offset.SetBytes(paddedSlice(ctx.Input, offset, 32))
+	return nil
+}
+
+// CALLDATASIZE pushes the size of the call data
+func opCallDataSize(ctx *ExecutionContext) error {
+	return ctx.Stack.Push(uint256.NewInt(uint64(len(ctx.Input))))
+}
+
+// Gas cost for CALLDATACOPY
+func gasCallDataCopy(ctx *ExecutionContext) (uint64, error) {
+	if ctx.Stack.Size() < 3 {
+		return 0, nil
+	}
+
+	memOffset, _ := ctx.Stack.GetItem(0)
+	size, _ := ctx.Stack.GetItem(2)
+
+	return memoryCopyCost(ctx, memOffset, size)
+}
+
+// CALLDATACOPY copies the call data to memory, padded with zeros past the end
+func opCallDataCopy(ctx *ExecutionContext) error {
+	memOffset, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	dataOffset, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	size, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if size.IsZero() {
+		return nil
+	}
+	ctx.Memory.Mstore(memOffset.Uint64(), paddedSlice(ctx.Input, &dataOffset, size.Uint64()))
+	return nil
+}
+
+// ===== Code Operations =====
+
+// CODESIZE pushes the size of the code of the frame. During contract creation this
+// is the initcode, followed by the constructor arguments.
+func opCodeSize(ctx *ExecutionContext) error {
+	return ctx.Stack.Push(uint256.NewInt(uint64(len(ctx.ByteCode))))
+}
+
+// Gas cost for CODECOPY
+func gasCodeCopy(ctx *ExecutionContext) (uint64, error) {
+	if ctx.Stack.Size() < 3 {
+		return 0, nil
+	}
+
+	memOffset, _ := ctx.Stack.GetItem(0)
+	size, _ := ctx.Stack.GetItem(2)
+
+	return memoryCopyCost(ctx, memOffset, size)
+}
+
+// CODECOPY copies the code of the frame to memory, padded with zeros past the end
+func opCodeCopy(ctx *ExecutionContext) error {
+	memOffset, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	codeOffset, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	size, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if size.IsZero() {
+		return nil
+	}
+	ctx.Memory.Mstore(memOffset.Uint64(), paddedSlice(ctx.ByteCode, &codeOffset, size.Uint64()))
+	return nil
+}
+
+// ===== Log Operations =====
+
+// Gas cost of the data of LOG operations, the topics are part of their constant gas
+func gasLog(ctx *ExecutionContext) (uint64, error) {
+	if ctx.Stack.Size() < 2 {
+		return 0, nil
+	}
+
+	offset, _ := ctx.Stack.GetItem(0)
+	size, _ := ctx.Stack.GetItem(1)
+
+	memoryCost, err := memoryExpansionCost(ctx, offset, size)
+	if err != nil {
+		return 0, err
+	}
+	dataCost, err := safeMul(size.Uint64(), t.GasLogData)
+	if err != nil {
+		return 0, err
+	}
+	return safeAdd(dataCost, memoryCost)
+}
+
+// makeLog creates a function to handle LOG operations with the given number of topics
+func makeLog(topics int) func(ctx *ExecutionContext) error {
+	return func(ctx *ExecutionContext) error {
+		if ctx.EVM == nil {
+			return ErrNoEnvironment
+		}
+		if ctx.ReadOnly {
+			return ErrWriteProtection
+		}
+
+		offset, err := ctx.Stack.Pop()
+		if err != nil {
+			return err
+		}
+		size, err := ctx.Stack.Pop()
+		if err != nil {
+			return err
+		}
+
+		log := &t.Log{
+			Address: ctx.ContractAddress,
+			Topics:  make([]common.Hash, topics),
+			Data:    common.CopyBytes(ctx.Memory.Expand(offset.Uint64(), size.Uint64())),
+		}
+		for i := range log.Topics {
+			topic, err := ctx.Stack.Pop()
+			if err != nil {
+				return err
+			}
+			log.Topics[i] = topic.Bytes32()
+		}
+
+		ctx.EVM.State.AddLog(log)
+		if ctx.Tracer != nil && ctx.Tracer.OnLog != nil {
+			ctx.Tracer.OnLog(log)
+		}
+		return nil
+	}
+}
+
+// ===== Block Operations =====
+
+// BLOCKHASH pushes the hash of one of the 256 blocks before the current one, zero
+// for other blocks or when block hashes are not available
+func opBlockHash(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+
+	// The hash replaces the block number on top of the stack
+	number, err := ctx.Stack.Peek()
+	if err != nil {
+		return err
+	}
+
+	block := ctx.EVM.Block
+	lower := uint64(0)
+	if block.Number > 256 {
+		lower = block.Number - 256
+	}
+	if block.GetHash == nil || !number.IsUint64() || number.Uint64() < lower || number.Uint64() >= block.Number {
+		number.Clear()
+		return nil
+	}
+	number.SetBytes(block.GetHash(number.Uint64()).Bytes())
+	return nil
+}
+
+// COINBASE pushes the beneficiary of the block
+func opCoinbase(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	return ctx.Stack.Push(new(uint256.Int).SetBytes(ctx.EVM.Block.Coinbase.Bytes()))
+}
+
+// TIMESTAMP pushes the timestamp of the block
+func opTimestamp(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	return ctx.Stack.Push(uint256.NewInt(ctx.EVM.Block.Time))
+}
+
+// NUMBER pushes the number of the block
+func opNumber(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	return ctx.Stack.Push(uint256.NewInt(ctx.EVM.Block.Number))
+}
+
+// PREVRANDAO pushes the beacon chain randomness of the block (EIP-4399)
+func opPrevRandao(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	return ctx.Stack.Push(new(uint256.Int).SetBytes(ctx.EVM.Block.PrevRandao.Bytes()))
+}
+
+// GASLIMIT pushes the gas limit of the block
+func opGasLimit(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	return ctx.Stack.Push(uint256.NewInt(ctx.EVM.Block.GasLimit))
+}
+
+// CHAINID pushes the chain ID (EIP-1344)
+func opChainID(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	return ctx.Stack.Push(uint256.NewInt(ctx.EVM.Block.ChainID))
+}
+
+// BASEFEE pushes the base fee of the block, zero before London (EIP-3198)
+func opBaseFee(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	result := uint256.NewInt(0)
+	if ctx.EVM.Block.BaseFee != nil {
+		result.Set(ctx.EVM.Block.BaseFee)
+	}
+	return ctx.Stack.Push(result)
+}
+
+// ===== Blob Operations =====
+
+// BLOBHASH pushes the versioned hash of the blob at the given index of the
+// transaction. Indices out of range push zero.
+func opBlobHash(ctx *ExecutionContext) error {
+	index, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	result := uint256.NewInt(0)
+	if ctx.EVM != nil {
+		if hash, ok := ctx.EVM.Tx.blobHash(&index); ok {
+			result.SetBytes(hash.Bytes())
+		}
+	}
+	return ctx.Stack.Push(result)
+}
+
+// BLOBBASEFEE pushes the blob base fee of the current block (EIP-7516)
+func opBlobBaseFee(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	return ctx.Stack.Push(ctx.EVM.Block.BlobBaseFee())
+}
+
+// ===== Account Operations =====
+
+// accessAccountCost warms the address and returns the cost of accessing it (EIP-2929)
+func accessAccountCost(ctx *ExecutionContext, address common.Address) uint64 {
+	if ctx.EVM.State.AddressInAccessList(address) {
+		return t.GasWarmAccess
+	}
+	ctx.EVM.State.AddAddressToAccessList(address)
+	return t.GasColdAccountAccess
+}
+
+// memoryExpansionCost returns the cost of expanding memory to cover size bytes at
+// offset. It fails with ErrOutOfGas when the region does not fit in 64 bits.
+func memoryExpansionCost(ctx *ExecutionContext, offset, size *uint256.Int) (uint64, error) {
+	if size.IsZero() {
+		return 0, nil
+	}
+	if !size.IsUint64() {
+		return 0, ErrOutOfGas
+	}
+	return memoryGasCost(ctx, offset, size.Uint64())
+}
+
+// copyGasCost returns the cost of copying size bytes, failing with ErrOutOfGas
+// when it does not fit in 64 bits
+func copyGasCost(size *uint256.Int) (uint64, error) {
+	return wordGasCost(size, t.GasCopyWord)
+}
+
+// wordGasCost returns the cost of processing size bytes at perWord gas per word,
+// failing with ErrOutOfGas when it does not fit in 64 bits
+func wordGasCost(size *uint256.Int, perWord uint64) (uint64, error) {
+	if !size.IsUint64() {
+		return 0, ErrOutOfGas
+	}
+	words := size.Uint64() / 32
+	if size.Uint64()%32 != 0 {
+		words++
+	}
+	return safeMul(words, perWord)
+}
+
+// memoryCopyCost returns the cost of copying size bytes to memory at offset: the
+// copy itself and the memory expansion
+func memoryCopyCost(ctx *ExecutionContext, offset, size *uint256.Int) (uint64, error) {
+	copyCost, err := copyGasCost(size)
+	if err != nil {
+		return 0, err
+	}
+	memoryCost, err := memoryExpansionCost(ctx, offset, size)
+	if err != nil {
+		return 0, err
+	}
+	return safeAdd(copyCost, memoryCost)
+}
+
+// safeAdd returns x + y, failing with ErrOutOfGas on overflow
+func safeAdd(x, y uint64) (uint64, error) {
+	sum, carry := bits.Add64(x, y, 0)
+	if carry != 0 {
+		return 0, ErrOutOfGas
+	}
+	return sum, nil
+}
+
+// safeMul returns x * y, failing with ErrOutOfGas on overflow
+func safeMul(x, y uint64) (uint64, error) {
+	hi, product := bits.Mul64(x, y)
+	if hi != 0 {
+		return 0, ErrOutOfGas
+	}
+	return product, nil
+}
+
+// Gas cost for EXTCODESIZE and EXTCODEHASH
+func gasAccountAccess(ctx *ExecutionContext) (uint64, error) {
+	if ctx.EVM == nil || ctx.Stack.Size() < 1 {
+		return 0, nil
+	}
+
+	address, err := ctx.Stack.GetItem(0)
+	if err != nil {
+		return 0, nil
+	}
+	return accessAccountCost(ctx, common.Address(address.Bytes20())), nil
+}
+
+// Gas cost for EXTCODECOPY
+func gasExtCodeCopy(ctx *ExecutionContext) (uint64, error) {
+	if ctx.EVM == nil || ctx.Stack.Size() < 4 {
+		return 0, nil
+	}
+
+	address, _ := ctx.Stack.GetItem(0)
+	memOffset, _ := ctx.Stack.GetItem(1)
+	size, _ := ctx.Stack.GetItem(3)
+
+	// The operands are checked before the account is warmed
+	cost, err := memoryCopyCost(ctx, memOffset, size)
+	if err != nil {
+		return 0, err
+	}
+	return safeAdd(cost, accessAccountCost(ctx, common.Address(address.Bytes20())))
+}
+
+// Gas cost for RETURNDATACOPY
+func gasReturnDataCopy(ctx *ExecutionContext) (uint64, error) {
+	if ctx.Stack.Size() < 3 {
+		return 0, nil
+	}
+
+	memOffset, _ := ctx.Stack.GetItem(0)
+	size, _ := ctx.Stack.GetItem(2)
+
+	return memoryCopyCost(ctx, memOffset, size)
+}
+
+// EXTCODESIZE pushes the size of the code of an account. For an account delegated
+// with EIP-7702 this is the size of the delegation designator, not of the target code.
+func opExtCodeSize(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+
+	address, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	size := ctx.EVM.State.GetCodeSize(common.Address(address.Bytes20()))
+	return ctx.Stack.Push(uint256.NewInt(uint64(size)))
+}
+
+// EXTCODECOPY copies the code of an account to memory, padding with zeros.
+// Delegated accounts expose their delegation designator.
+func opExtCodeCopy(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+
+	address, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	memOffset, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	codeOffset, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	size, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if size.IsZero() {
+		return nil
+	}
+	code := ctx.EVM.State.GetCode(common.Address(address.Bytes20()))
+	ctx.Memory.Mstore(memOffset.Uint64(), paddedSlice(code, &codeOffset, size.Uint64()))
+	return nil
+}
+
+// EXTCODEHASH pushes the hash of the code of an account, or zero if the account is
+// empty. Delegated accounts return the hash of their delegation designator.
+func opExtCodeHash(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+
+	address, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	result := uint256.NewInt(0)
+	if addr := common.Address(address.Bytes20()); !ctx.EVM.State.Empty(addr) {
+		result.SetBytes(ctx.EVM.State.GetCodeHash(addr).Bytes())
+	}
+	return ctx.Stack.Push(result)
+}
+
+// paddedSlice returns size bytes of data starting at offset, padded with zeros past the end
+func paddedSlice(data []byte, offset *uint256.Int, size uint64) []byte {
+	result := make([]byte, size)
+	if !offset.IsUint64() || offset.Uint64() >= uint64(len(data)) {
+		return result
+	}
+	copy(result, data[offset.Uint64():])
+	return result
+}
+
+// RETURNDATASIZE pushes the size of the data returned by the last call
+func opReturnDataSize(ctx *ExecutionContext) error {
+	return ctx.Stack.Push(uint256.NewInt(uint64(len(ctx.CallReturnData))))
+}
+
+// RETURNDATACOPY copies the data returned by the last call to memory.
+// Reading past the end of the return data is an error.
+func opReturnDataCopy(ctx *ExecutionContext) error {
+	memOffset, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	dataOffset, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+	size, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	end := new(uint256.Int).Add(&dataOffset, &size)
+	if !end.IsUint64() || end.Uint64() > uint64(len(ctx.CallReturnData)) || end.Lt(&dataOffset) {
+		return ErrReturnDataOutOfBounds
+	}
+	if size.IsZero() {
+		return nil
+	}
+	ctx.Memory.Mstore(memOffset.Uint64(), ctx.CallReturnData[dataOffset.Uint64():end.Uint64()])
+	return nil
+}
+
+// ===== Call Operations =====
+
+// makeGasCall creates the gas function of a call opcode. The cost covers the account
+// access, value transfer, new account creation, memory expansion and, for accounts
+// delegated with EIP-7702, the access of the delegation target. The gas forwarded to
+// the callee (all but 1/64th of what is left, EIP-150) is included in the cost and
+// stored in the context for the execution function.
+func makeGasCall(op t.Opcode) func(ctx *ExecutionContext) (uint64, error) {
+	hasValue := op == t.CALL || op == t.CALLCODE
+	pops := 6
+	if hasValue {
+		pops = 7
+	}
+
+	return func(ctx *ExecutionContext) (uint64, error) {
+		if ctx.EVM == nil || ctx.Stack.Size() < pops {
+			return 0, nil
+		}
+
+		requested, _ := ctx.Stack.GetItem(0)
+		addressWord, _ := ctx.Stack.GetItem(1)
+		address := common.Address(addressWord.Bytes20())
+
+		// Memory regions follow the optional value on the stack. They are checked
+		// first, so a call failing on its operands does not warm the address.
+		first := 2
+		if hasValue {
+			first = 3
+		}
+		inOffset, _ := ctx.Stack.GetItem(first)
+		inSize, _ := ctx.Stack.GetItem(first + 1)
+		retOffset, _ := ctx.Stack.GetItem(first + 2)
+		retSize, _ := ctx.Stack.GetItem(first + 3)
+		inCost, err := memoryExpansionCost(ctx, inOffset, inSize)
+		if err != nil {
+			return 0, err
+		}
+		retCost, err := memoryExpansionCost(ctx, retOffset, retSize)
+		if err != nil {
+			return 0, err
+		}
+		cost := max(inCost, retCost) + accessAccountCost(ctx, address)
+
+		if hasValue {
+			value, _ := ctx.Stack.GetItem(2)
+			if !value.IsZero() {
+				cost += t.GasCallValueTransfer
+				if op == t.CALL && ctx.EVM.State.Empty(address) {
+					cost += t.GasCallNewAccount
+				}
+			}
+		}
+
+		// Calling a delegated account also accesses the delegation target (EIP-7702)
+		if target, ok := gethtypes.ParseDelegation(ctx.EVM.State.GetCode(address)); ok {
+			cost += accessAccountCost(ctx, target)
+		}
+
+		remaining := ctx.GasMeter.GasRemaining()
+		if cost > remaining {
+			return cost, nil
+		}
+		available := remaining - cost
+		callGas := available - available/t.GasCallGasDivisor
+		if requested.IsUint64() && requested.Uint64() < callGas {
+			callGas = requested.Uint64()
+		}
+		ctx.callGasTemp = callGas
+
+		return cost + callGas, nil
+	}
+}
+
+// popCallArgs pops the address, optional value and memory regions of a call,
+// expands memory and returns the input data. The gas function of the call has
+// already rejected regions that do not fit in 64 bits.
+func popCallArgs(ctx *ExecutionContext, hasValue bool) (address common.Address, value *uint256.Int, input []byte, retOffset, retSize *uint256.Int, err error) {
+	// The requested gas has already been turned into ctx.callGasTemp
+	if _, err = ctx.Stack.Pop(); err != nil {
+		return
+	}
+	count := 5
+	if hasValue {
+		count = 6
+	}
+	args := make([]uint256.Int, count)
+	for i := range args {
+		if args[i], err = ctx.Stack.Pop(); err != nil {
+			return
+		}
+	}
+
+	address = common.Address(args[0].Bytes20())
+	value = uint256.NewInt(0)
+	if hasValue {
+		value, args = &args[1], args[1:]
+	}
+	inOffset, inSize := &args[1], &args[2]
+	retOffset, retSize = &args[3], &args[4]
+
+	input = common.CopyBytes(ctx.Memory.Expand(inOffset.Uint64(), inSize.Uint64()))
+	ctx.Memory.Expand(retOffset.Uint64(), retSize.Uint64())
+	return
+}
+
+// finishCall pushes the success flag of a call, copies its output to memory and
+// takes back the gas the callee did not use
+func finishCall(ctx *ExecutionContext, ret []byte, meter *t.GasMeter, err error, retOffset, retSize *uint256.Int) error {
+	success := uint256.NewInt(0)
+	if err == nil {
+		success.SetOne()
+		ctx.GasMeter.MergeRefund(meter)
+	}
+	if err == nil || errors.Is(err, ErrExecutionReverted) {
+		if size := min(retSize.Uint64(), uint64(len(ret))); size > 0 {
+			ctx.Memory.Mstore(retOffset.Uint64(), ret[:size])
+		}
+	}
+	gas := ctx.GasMeter.GasRemaining()
+	ctx.GasMeter.ReturnGas(meter.GasRemaining())
+	ctx.Tracer.captureGasChange(gas, ctx.GasMeter.GasRemaining(), GasChangeCallLeftOverReturned)
+	ctx.CallReturnData = ret
+
+	return ctx.Stack.Push(success)
+}
+
+// CALL implements a message call into an account
+func opCall(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+
+	address, value, input, retOffset, retSize, err := popCallArgs(ctx, true)
+	if err != nil {
+		return err
+	}
+	if ctx.ReadOnly && !value.IsZero() {
+		return ErrWriteProtection
+	}
+
+	// The callee gets a free stipend when value is transferred
+	gas := ctx.callGasTemp
+	if !value.IsZero() {
+		gas += t.GasCallStipend
+	}
+
+	ret, meter, err := ctx.EVM.Call(ctx.ContractAddress, address, input, gas, value)
+	return finishCall(ctx, ret, meter, err, retOffset, retSize)
+}
+
+// CALLCODE implements a message call running another account's code in this account's context
+func opCallCode(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+
+	address, value, input, retOffset, retSize, err := popCallArgs(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	gas := ctx.callGasTemp
+	if !value.IsZero() {
+		gas += t.GasCallStipend
+	}
+
+	ret, meter, err := ctx.EVM.CallCode(ctx.ContractAddress, address, input, gas, value)
+	return finishCall(ctx, ret, meter, err, retOffset, retSize)
+}
+
+// DELEGATECALL implements a message call running another account's code with the
+// caller and value of the current frame
+func opDelegateCall(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+
+	address, _, input, retOffset, retSize, err := popCallArgs(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	ret, meter, err := ctx.EVM.DelegateCall(ctx.CallerAddress, ctx.ContractAddress, address, input, ctx.callGasTemp, ctx.CallValue)
+	return finishCall(ctx, ret, meter, err, retOffset, retSize)
+}
+
+// STATICCALL implements a message call that cannot modify the state
+func opStaticCall(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+
+	address, _, input, retOffset, retSize, err := popCallArgs(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	ret, meter, err := ctx.EVM.StaticCall(ctx.ContractAddress, address, input, ctx.callGasTemp)
+	return finishCall(ctx, ret, meter, err, retOffset, retSize)
+}
+
+// ===== Create Operations =====
+
+// makeGasCreate creates the gas function of a create opcode. The cost covers the
+// memory expansion, the initcode (EIP-3860) and, for CREATE2, hashing the initcode.
+// Like for calls, the gas forwarded to the new contract (all but 1/64th of what is
+// left, EIP-150) is included in the cost and stored in the context.
+func makeGasCreate(op t.Opcode) func(ctx *ExecutionContext) (uint64, error) {
+	perWord := t.InitCodeWordGas
+	if op == t.CREATE2 {
+		perWord += t.GasKeccak256Word
+	}
+
+	return func(ctx *ExecutionContext) (uint64, error) {
+		if ctx.Stack.Size() < 3 {
+			return 0, nil
+		}
+
+		offset, _ := ctx.Stack.GetItem(1)
+		size, _ := ctx.Stack.GetItem(2)
+		memoryCost, err := memoryExpansionCost(ctx, offset, size)
+		if err != nil {
+			return 0, err
+		}
+		wordCost, err := wordGasCost(size, perWord)
+		if err != nil {
+			return 0, err
+		}
+		cost, err := safeAdd(memoryCost, wordCost)
+		if err != nil {
+			return 0, err
+		}
+
+		remaining := ctx.GasMeter.GasRemaining()
+		if cost > remaining || t.GasCreate > remaining-cost {
+			return cost, nil
+		}
+		available := remaining - cost - t.GasCreate
+		ctx.callGasTemp = available - available/t.GasCallGasDivisor
+
+		return cost + ctx.callGasTemp, nil
+	}
+}
+
+// popCreateArgs pops the value and initcode of a create operation. The initcode
+// is limited to MaxInitCodeSize bytes (EIP-3860).
+func popCreateArgs(ctx *ExecutionContext) (value *uint256.Int, code []byte, err error) {
+	args := make([]uint256.Int, 3)
+	for i := range args {
+		if args[i], err = ctx.Stack.Pop(); err != nil {
+			return
+		}
+	}
+	value, offset, size := &args[0], &args[1], &args[2]
+	if !size.IsUint64() || size.Uint64() > t.MaxInitCodeSize {
+		err = fmt.Errorf("%w: code size %s limit %d", ErrMaxInitCodeSizeExceeded, size.Dec(), t.MaxInitCodeSize)
+		return
+	}
+	code = common.CopyBytes(ctx.Memory.Expand(offset.Uint64(), size.Uint64()))
+	return
+}
+
+// finishCreate pushes the address of the new contract, zero if the creation
+// failed, and takes back the gas the initcode did not use. Only a reverted
+// creation leaves return data.
+func finishCreate(ctx *ExecutionContext, ret []byte, address common.Address, meter *t.GasMeter, err error) error {
+	result := uint256.NewInt(0)
+	if err == nil {
+		result.SetBytes(address.Bytes())
+		ctx.GasMeter.MergeRefund(meter)
+	}
+	gas := ctx.GasMeter.GasRemaining()
+	ctx.GasMeter.ReturnGas(meter.GasRemaining())
+	ctx.Tracer.captureGasChange(gas, ctx.GasMeter.GasRemaining(), GasChangeCallLeftOverReturned)
+
+	ctx.CallReturnData = nil
+	if errors.Is(err, ErrExecutionReverted) {
+		ctx.CallReturnData = ret
+	}
+	return ctx.Stack.Push(result)
+}
+
+// CREATE deploys a new contract at an address derived from the address and nonce
+// of the current account
+func opCreate(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	if ctx.ReadOnly {
+		return ErrWriteProtection
+	}
+
+	value, code, err := popCreateArgs(ctx)
+	if err != nil {
+		return err
+	}
+
+	ret, address, meter, err := ctx.EVM.Create(ctx.ContractAddress, code, ctx.callGasTemp, value)
+	return finishCreate(ctx, ret, address, meter, err)
+}
+
+// CREATE2 deploys a new contract at an address derived from the address of the
+// current account, a salt and the hash of the initcode (EIP-1014)
+func opCreate2(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	if ctx.ReadOnly {
+		return ErrWriteProtection
+	}
+
+	value, code, err := popCreateArgs(ctx)
+	if err != nil {
+		return err
+	}
+	salt, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	ret, address, meter, err := ctx.EVM.Create2(ctx.ContractAddress, code, ctx.callGasTemp, value, &salt)
+	return finishCreate(ctx, ret, address, meter, err)
+}
+
+// ===== Selfdestruct Operation =====
+
+// Gas cost for SELFDESTRUCT, on top of its base cost: the access of the
+// beneficiary if it is cold, and the creation of the beneficiary if a balance is
+// sent to an empty account
+func gasSelfdestruct(ctx *ExecutionContext) (uint64, error) {
+	if ctx.EVM == nil || ctx.Stack.Size() < 1 {
+		return 0, nil
+	}
+
+	word, _ := ctx.Stack.GetItem(0)
+	beneficiary := common.Address(word.Bytes20())
+
+	cost := uint64(0)
+	if !ctx.EVM.State.AddressInAccessList(beneficiary) {
+		ctx.EVM.State.AddAddressToAccessList(beneficiary)
+		cost += t.GasColdAccountAccess
+	}
+	if ctx.EVM.State.Empty(beneficiary) && !ctx.EVM.State.GetBalance(ctx.ContractAddress).IsZero() {
+		cost += t.GasSelfdestructNewAccount
+	}
+	return cost, nil
+}
+
+// SELFDESTRUCT sends the whole balance of the current account to a beneficiary
+// and halts. The account itself is only deleted if it was created by the current
+// transaction (EIP-6780), in which case a balance sent to itself is burnt.
+func opSelfdestruct(ctx *ExecutionContext) error {
+	if ctx.EVM == nil {
+		return ErrNoEnvironment
+	}
+	if ctx.ReadOnly {
+		return ErrWriteProtection
+	}
+
+	word, err := ctx.Stack.Pop()
+	if err != nil {
+		return err
+	}
+
+	state := ctx.EVM.State
+	balance := new(uint256.Int).Set(state.GetBalance(ctx.ContractAddress))
+	ctx.EVM.transfer(ctx.ContractAddress, common.Address(word.Bytes20()), balance)
+	if state.IsNewContract(ctx.ContractAddress) {
+		state.SelfDestruct(ctx.ContractAddress)
+	}
+
+	ctx.Stopped = true
+	return nil
+}
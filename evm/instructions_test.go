@@ -0,0 +1,426 @@
+package evm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Manuelshub/go-EVM/assembler"
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assemble assembles source, failing the test on errors
+func assemble(test *testing.T, source string) []byte {
+	code, err := assembler.Assemble(source)
+	require.NoError(test, err)
+	return code
+}
+
+func TestStackOperations(test *testing.T) {
+	minus := func(x uint64) *uint256.Int {
+		return new(uint256.Int).Neg(uint256.NewInt(x))
+	}
+	word := make([]byte, 32)
+	word[31] = 1
+
+	tests := []struct {
+		name     string
+		source   string
+		expected *uint256.Int
+	}{
+		{"SDIV", "PUSH 3; PUSH 10; PUSH 0; SUB; SDIV", minus(3)},
+		{"SDIV by zero", "PUSH 0; PUSH 10; SDIV", uint256.NewInt(0)},
+		{"SMOD keeps the sign of the dividend", "PUSH 3; PUSH 10; PUSH 0; SUB; SMOD", minus(1)},
+		{"ADDMOD", "PUSH 7; PUSH 5; PUSH 4; ADDMOD", uint256.NewInt(2)},
+		{"ADDMOD does not overflow", "PUSH 10; PUSH 2; PUSH 0; NOT; ADDMOD", uint256.NewInt(7)},
+		{"MULMOD", "PUSH 7; PUSH 5; PUSH 4; MULMOD", uint256.NewInt(6)},
+		{"MULMOD by zero", "PUSH 0; PUSH 5; PUSH 4; MULMOD", uint256.NewInt(0)},
+		{"SIGNEXTEND", "PUSH 0xff; PUSH 0; SIGNEXTEND", minus(1)},
+		{"SIGNEXTEND of a positive byte", "PUSH 0x017f; PUSH 0; SIGNEXTEND", uint256.NewInt(0x7f)},
+		{"LT", "PUSH 2; PUSH 1; LT", uint256.NewInt(1)},
+		{"GT", "PUSH 2; PUSH 1; GT", uint256.NewInt(0)},
+		{"SLT", "PUSH 1; PUSH 0; NOT; SLT", uint256.NewInt(1)},
+		{"SGT", "PUSH 1; PUSH 0; NOT; SGT", uint256.NewInt(0)},
+		{"EQ", "PUSH 5; PUSH 5; EQ", uint256.NewInt(1)},
+		{"ISZERO", "PUSH 0; ISZERO", uint256.NewInt(1)},
+		{"BYTE", "PUSH 0x1122; PUSH 30; BYTE", uint256.NewInt(0x11)},
+		{"BYTE past the word", "PUSH 0x1122; PUSH 32; BYTE", uint256.NewInt(0)},
+		{"SHL", "PUSH 1; PUSH 4; SHL", uint256.NewInt(16)},
+		{"SHL by 256", "PUSH 1; PUSH 256; SHL", uint256.NewInt(0)},
+		{"SHR", "PUSH 0x100; PUSH 4; SHR", uint256.NewInt(0x10)},
+		{"SAR", "PUSH 16; PUSH 0; SUB; PUSH 2; SAR", minus(4)},
+		{"SAR of a negative number by 256", "PUSH 0; NOT; PUSH 300; SAR", minus(1)},
+		{"KECCAK256 of nothing", "PUSH 0; PUSH 0; KECCAK256", new(uint256.Int).SetBytes(crypto.Keccak256(nil))},
+		{"KECCAK256", "PUSH 1; PUSH 0; MSTORE; PUSH 32; PUSH 0; KECCAK256", new(uint256.Int).SetBytes(crypto.Keccak256(word))},
+		{"PUSH0", "PUSH 1; PUSH0", uint256.NewInt(0)},
+		{"POP", "PUSH 1; PUSH 2; POP", uint256.NewInt(1)},
+		{"PC", "PUSH 1; POP; PC", uint256.NewInt(3)},
+		{"MSIZE is a whole number of words", "PUSH 1; PUSH 33; MSTORE8; MSIZE", uint256.NewInt(64)},
+		{"MCOPY", "PUSH 0x1122; PUSH 0; MSTORE; PUSH 32; PUSH 0; PUSH 32; MCOPY; PUSH 32; MLOAD", uint256.NewInt(0x1122)},
+		{"MCOPY overlapping regions", "PUSH 0x1122; PUSH 0; MSTORE; PUSH 31; PUSH 1; PUSH 0; MCOPY; PUSH 0; MLOAD", uint256.NewInt(0x112222)},
+		{"GAS", "GAS", uint256.NewInt(10_000_000 - 2)},
+	}
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			ctx := NewExecutionContext()
+			_, err := ctx.Run(assemble(test, tt.source))
+			require.NoError(test, err)
+			top, err := ctx.Stack.Peek()
+			require.NoError(test, err)
+			assert.Equal(test, tt.expected, top)
+		})
+	}
+	test.Run("Solidity prologue", func(test *testing.T) {
+		// mstore(0x40, 0x80) and revert if a value is sent, as emitted by solc
+		ctx := NewExecutionContext()
+		_, err := ctx.Run(common.FromHex("0x6080604052348015600e575f5ffd5b50"))
+		require.NoError(test, err)
+		assert.Equal(test, 0, ctx.Stack.Size())
+		assert.Equal(test, uint256.NewInt(0x80), new(uint256.Int).SetBytes(ctx.Memory.Expand(0x40, 32)))
+	})
+}
+
+func TestEnvironmentOperations(test *testing.T) {
+	blockHash := func(number uint64) common.Hash {
+		return crypto.Keccak256Hash(uint256.NewInt(number).Bytes())
+	}
+	address := func(address common.Address) common.Hash {
+		return common.BytesToHash(address.Bytes())
+	}
+	number := func(x uint64) common.Hash {
+		return common.BigToHash(uint256.NewInt(x).ToBig())
+	}
+
+	tests := []struct {
+		name     string
+		source   string
+		expected common.Hash
+	}{
+		{"ADDRESS", "ADDRESS", address(testReceiver)},
+		{"CALLER", "CALLER", address(testSender)},
+		{"ORIGIN", "ORIGIN", address(testSender)},
+		{"CALLVALUE", "CALLVALUE", number(5)},
+		{"GASPRICE", "GASPRICE", number(10)},
+		{"BALANCE", "PUSH " + testReceiver.Hex() + "; BALANCE", number(5)},
+		{"SELFBALANCE", "SELFBALANCE", number(5)},
+		{"COINBASE", "COINBASE", address(testCoinbase)},
+		{"TIMESTAMP", "TIMESTAMP", number(1234)},
+		{"NUMBER", "NUMBER", number(300)},
+		{"PREVRANDAO", "PREVRANDAO", common.HexToHash("0x0abc")},
+		{"GASLIMIT", "GASLIMIT", number(30_000_000)},
+		{"CHAINID", "CHAINID", number(1)},
+		{"BASEFEE", "BASEFEE", number(10)},
+		{"BLOCKHASH of the previous block", "PUSH 299; BLOCKHASH", blockHash(299)},
+		{"BLOCKHASH of the oldest available block", "PUSH 44; BLOCKHASH", blockHash(44)},
+		{"BLOCKHASH of an older block", "PUSH 43; BLOCKHASH", common.Hash{}},
+		{"BLOCKHASH of the current block", "PUSH 300; BLOCKHASH", common.Hash{}},
+		{"TLOAD after TSTORE", "PUSH 7; PUSH 1; TSTORE; PUSH 1; TLOAD", number(7)},
+		{"TLOAD of an unset slot", "PUSH 1; TLOAD", common.Hash{}},
+	}
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			evm := newTestEVM()
+			evm.Block.ChainID = 1
+			evm.Block.Number = 300
+			evm.Block.Time = 1234
+			evm.Block.PrevRandao = common.HexToHash("0x0abc")
+			evm.Block.GetHash = blockHash
+			// Store the value left on the stack in slot 0
+			evm.State.SetCode(testReceiver, assemble(test, tt.source+"; PUSH 0; SSTORE"))
+
+			result, err := ApplyMessage(evm, &Message{
+				From:     testSender,
+				To:       &testReceiver,
+				Value:    uint256.NewInt(5),
+				GasLimit: 100_000,
+				GasPrice: uint256.NewInt(10),
+			})
+			require.NoError(test, err)
+			require.NoError(test, result.Err)
+			assert.Equal(test, tt.expected, evm.State.GetState(testReceiver, common.Hash{}))
+
+			// Transient storage does not outlive the transaction
+			assert.Equal(test, common.Hash{}, evm.State.GetTransientState(testReceiver, common.BigToHash(common.Big1)))
+		})
+	}
+
+	test.Run("Bare bytecode has no environment", func(test *testing.T) {
+		for _, source := range []string{"ORIGIN", "NUMBER", "PUSH 0; BALANCE", "PUSH 0; TLOAD", "PUSH 0; PUSH 0; PUSH 0; CREATE"} {
+			_, err := NewExecutionContext().Run(assemble(test, source))
+			assert.ErrorIs(test, err, ErrNoEnvironment, source)
+		}
+	})
+}
+
+func TestCreateOperations(test *testing.T) {
+	// Initcode deploying the runtime code 0x00
+	initcode := assemble(test, "PUSH 1; PUSH 0; RETURN")
+	// Copy the initcode to memory and run CREATE or CREATE2 with it, storing the
+	// address of the new contract in slot 0
+	create := func(source string) []byte {
+		return assemble(test, "PUSH 0x"+common.Bytes2Hex(initcode)+"; PUSH 0; MSTORE; "+source+"; PUSH 0; SSTORE")
+	}
+	offset := 32 - len(initcode)
+	salt := common.HexToHash("0x2a")
+	tests := []struct {
+		name     string
+		code     []byte
+		expected common.Address
+		err      error
+	}{
+		{
+			name:     "CREATE",
+			code:     create("PUSH " + uint256.NewInt(uint64(len(initcode))).Hex() + "; PUSH " + uint256.NewInt(uint64(offset)).Hex() + "; PUSH 0; CREATE"),
+			expected: crypto.CreateAddress(testReceiver, 0),
+		},
+		{
+			name:     "CREATE2",
+			code:     create("PUSH 0x2a; PUSH " + uint256.NewInt(uint64(len(initcode))).Hex() + "; PUSH " + uint256.NewInt(uint64(offset)).Hex() + "; PUSH 0; CREATE2"),
+			expected: crypto.CreateAddress2(testReceiver, salt, crypto.Keccak256(initcode)),
+		},
+		{
+			name: "Failing initcode",
+			code: assemble(test, "PUSH 0xfe; PUSH 0; MSTORE8; PUSH 1; PUSH 0; PUSH 0; CREATE; PUSH 0; SSTORE"),
+		},
+		{
+			name: "Initcode too large",
+			code: assemble(test, "PUSH "+uint256.NewInt(t.MaxInitCodeSize+1).Hex()+"; PUSH 0; PUSH 0; CREATE; PUSH 0; SSTORE"),
+			err:  ErrMaxInitCodeSizeExceeded,
+		},
+	}
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			evm := newTestEVM()
+			evm.State.SetCode(testReceiver, tt.code)
+			result, err := ApplyMessage(evm, &Message{
+				From:     testSender,
+				To:       &testReceiver,
+				GasLimit: 1_000_000,
+				GasPrice: uint256.NewInt(10),
+			})
+			require.NoError(test, err)
+			if tt.err != nil {
+				assert.ErrorIs(test, result.Err, tt.err)
+				return
+			}
+
+			require.NoError(test, result.Err)
+			if tt.expected == (common.Address{}) {
+				// A failed creation pushes zero
+				assert.Equal(test, common.Hash{}, evm.State.GetState(testReceiver, common.Hash{}))
+				assert.Equal(test, uint64(0), evm.State.GetNonce(crypto.CreateAddress(testReceiver, 0)))
+				return
+			}
+			assert.Equal(test, common.BytesToHash(tt.expected.Bytes()), evm.State.GetState(testReceiver, common.Hash{}))
+			assert.Equal(test, []byte{0x00}, evm.State.GetCode(tt.expected))
+			assert.Equal(test, uint64(1), evm.State.GetNonce(tt.expected))
+			assert.Equal(test, uint64(1), evm.State.GetNonce(testReceiver))
+		})
+	}
+
+	test.Run("CREATE in a static call", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.State.SetCode(testLibrary, assemble(test, "PUSH 0; PUSH 0; PUSH 0; CREATE"))
+		_, _, err := evm.StaticCall(testSender, testLibrary, nil, 100_000)
+		assert.ErrorIs(test, err, ErrWriteProtection)
+	})
+}
+
+func TestStorageGas(test *testing.T) {
+	zero := uint256.NewInt(0)
+
+	// The test cases of EIP-3529: the gas used by the code, run on a warm slot 0,
+	// and the refund
+	tests := []struct {
+		code     string
+		original uint64
+		gas      uint64
+		refund   uint64
+	}{
+		{code: "0x60006000556000600055", original: 0, gas: 212, refund: 0},
+		{code: "0x60006000556001600055", original: 0, gas: 20112, refund: 0},
+		{code: "0x60016000556000600055", original: 0, gas: 20112, refund: 19900},
+		{code: "0x60016000556002600055", original: 0, gas: 20112, refund: 0},
+		{code: "0x60016000556001600055", original: 0, gas: 20112, refund: 0},
+		{code: "0x60006000556000600055", original: 1, gas: 3012, refund: 4800},
+		{code: "0x60006000556001600055", original: 1, gas: 3012, refund: 2800},
+		{code: "0x60006000556002600055", original: 1, gas: 3012, refund: 0},
+		{code: "0x60026000556000600055", original: 1, gas: 3012, refund: 4800},
+		{code: "0x60026000556003600055", original: 1, gas: 3012, refund: 0},
+		{code: "0x60026000556001600055", original: 1, gas: 3012, refund: 2800},
+		{code: "0x60026000556002600055", original: 1, gas: 3012, refund: 0},
+		{code: "0x60016000556000600055", original: 1, gas: 3012, refund: 4800},
+		{code: "0x60016000556002600055", original: 1, gas: 3012, refund: 0},
+		{code: "0x60016000556001600055", original: 1, gas: 212, refund: 0},
+		{code: "0x600160005560006000556001600055", original: 0, gas: 40118, refund: 19900},
+		{code: "0x600060005560016000556000600055", original: 1, gas: 5918, refund: 7600},
+	}
+
+	for _, tt := range tests {
+		test.Run(fmt.Sprintf("%s from %d", tt.code, tt.original), func(test *testing.T) {
+			evm := newTestEVM()
+			evm.State.SetCode(testReceiver, common.FromHex(tt.code))
+			evm.State.SetState(testReceiver, common.Hash{}, uint256.NewInt(tt.original).Bytes32())
+			evm.State.ResetJournal()
+			evm.State.AddSlotToAccessList(testReceiver, common.Hash{})
+
+			_, meter, err := evm.Call(testSender, testReceiver, nil, 100_000, zero)
+			require.NoError(test, err)
+			assert.Equal(test, tt.gas, meter.GasConsumed())
+			assert.Equal(test, tt.refund, meter.GasRefunded())
+		})
+	}
+
+	test.Run("Cold slots", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.State.SetCode(testReceiver, assemble(test, "PUSH 0; SLOAD; PUSH 0; SLOAD; PUSH 1; PUSH 1; SSTORE"))
+		_, meter, err := evm.Call(testSender, testReceiver, nil, 100_000, zero)
+		require.NoError(test, err)
+		assert.Equal(test, 3+t.GasColdSload+3+t.GasWarmAccess+3+3+t.GasColdSload+t.GasStorageSet, meter.GasConsumed())
+	})
+
+	test.Run("Access list warms the slots", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.State.SetCode(testReceiver, assemble(test, "PUSH 0; SLOAD"))
+		result, err := ApplyMessage(evm, &Message{
+			From:       testSender,
+			To:         &testReceiver,
+			GasLimit:   100_000,
+			GasPrice:   uint256.NewInt(10),
+			AccessList: gethtypes.AccessList{{Address: testReceiver, StorageKeys: []common.Hash{{}}}},
+		})
+		require.NoError(test, err)
+		require.NoError(test, result.Err)
+		assert.Equal(test, t.TxGas+t.TxAccessListAddressGas+t.TxAccessListStorageKeyGas+3+t.GasWarmAccess, result.UsedGas)
+	})
+
+	test.Run("Original values are committed before the transaction", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.State.SetCode(testReceiver, assemble(test, "PUSH 0; PUSH 0; SSTORE; PUSH 1; PUSH 0; SSTORE"))
+		evm.State.SetState(testReceiver, common.Hash{}, common.HexToHash("0x01"))
+		result, err := ApplyMessage(evm, &Message{
+			From:     testSender,
+			To:       &testReceiver,
+			GasLimit: 100_000,
+			GasPrice: uint256.NewInt(10),
+		})
+		require.NoError(test, err)
+		require.NoError(test, result.Err)
+		// Clearing the slot is refunded, setting it back to its original value
+		// takes that refund back and refunds most of the first write instead
+		assert.Equal(test, t.GasStorageUpdate-t.GasColdSload-t.GasWarmAccess, result.RefundedGas)
+		assert.Equal(test, t.TxGas+3+3+t.GasStorageUpdate+3+3+t.GasWarmAccess-result.RefundedGas, result.UsedGas)
+	})
+
+	test.Run("Refunds taken back in a child frame", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.State.SetCode(testLibrary, assemble(test, "PUSH 1; PUSH 0; SSTORE"))
+		// Clear slot 0 then run the library, which sets it back, in the same storage
+		evm.State.SetCode(testReceiver, assemble(test, "PUSH 0; PUSH 0; SSTORE; PUSH 0; PUSH 0; PUSH 0; PUSH 0; PUSH "+
+			testLibrary.Hex()+"; GAS; DELEGATECALL"))
+		evm.State.SetState(testReceiver, common.Hash{}, common.HexToHash("0x01"))
+		evm.State.ResetJournal()
+
+		_, meter, err := evm.Call(testSender, testReceiver, nil, 100_000, zero)
+		require.NoError(test, err)
+		assert.Equal(test, common.HexToHash("0x01"), evm.State.GetState(testReceiver, common.Hash{}))
+		assert.Equal(test, t.GasStorageUpdate-t.GasColdSload-t.GasWarmAccess, meter.GasRefunded())
+	})
+
+	test.Run("SSTORE needs more than the call stipend", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.State.SetCode(testReceiver, assemble(test, "PUSH 0; PUSH 0; SSTORE"))
+		evm.State.AddSlotToAccessList(testReceiver, common.Hash{})
+
+		_, _, err := evm.Call(testSender, testReceiver, nil, 3+3+t.GasSstoreSentry, zero)
+		assert.ErrorIs(test, err, ErrOutOfGas)
+		_, meter, err := evm.Call(testSender, testReceiver, nil, 3+3+t.GasSstoreSentry+1, zero)
+		require.NoError(test, err)
+		assert.Equal(test, 3+3+t.GasWarmAccess, meter.GasConsumed())
+	})
+}
+
+func TestTransientStorage(test *testing.T) {
+	key := common.BigToHash(common.Big1)
+	zero := uint256.NewInt(0)
+
+	test.Run("Values live until the end of the transaction", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.State.SetCode(testReceiver, assemble(test, "PUSH 7; PUSH 1; TSTORE"))
+		_, _, err := evm.Call(testSender, testReceiver, nil, 100_000, zero)
+		require.NoError(test, err)
+		assert.Equal(test, common.HexToHash("0x07"), evm.State.GetTransientState(testReceiver, key))
+
+		evm.State.Finalise()
+		assert.Equal(test, common.Hash{}, evm.State.GetTransientState(testReceiver, key))
+	})
+
+	test.Run("Values are reverted with their frame", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.State.SetCode(testReceiver, assemble(test, "PUSH 7; PUSH 1; TSTORE; PUSH 0; PUSH 0; REVERT"))
+		_, _, err := evm.Call(testSender, testReceiver, nil, 100_000, zero)
+		assert.ErrorIs(test, err, ErrExecutionReverted)
+		assert.Equal(test, common.Hash{}, evm.State.GetTransientState(testReceiver, key))
+	})
+
+	test.Run("TSTORE in a static call", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.State.SetCode(testReceiver, assemble(test, "PUSH 7; PUSH 1; TSTORE"))
+		_, _, err := evm.StaticCall(testSender, testReceiver, nil, 100_000)
+		assert.ErrorIs(test, err, ErrWriteProtection)
+	})
+}
+
+func TestSelfdestruct(test *testing.T) {
+	beneficiary := common.HexToAddress("0xbe")
+
+	test.Run("Existing contracts only send their balance", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.State.SetCode(testReceiver, assemble(test, "PUSH "+beneficiary.Hex()+"; SELFDESTRUCT"))
+		evm.State.SetBalance(testReceiver, uint256.NewInt(7))
+		result, err := ApplyMessage(evm, &Message{
+			From:     testSender,
+			To:       &testReceiver,
+			GasLimit: 100_000,
+			GasPrice: uint256.NewInt(10),
+		})
+		require.NoError(test, err)
+		require.NoError(test, result.Err)
+
+		assert.Equal(test, uint64(7), evm.State.GetBalance(beneficiary).Uint64())
+		assert.True(test, evm.State.GetBalance(testReceiver).IsZero())
+		assert.NotEmpty(test, evm.State.GetCode(testReceiver))
+		// Sending a balance to a cold, empty account
+		assert.Equal(test, t.TxGas+3+t.GasSelfdestruct+t.GasColdAccountAccess+t.GasSelfdestructNewAccount, result.UsedGas)
+	})
+
+	test.Run("Contracts created by the transaction are deleted", func(test *testing.T) {
+		evm := newTestEVM()
+		// Initcode sending the balance of the new contract to the beneficiary
+		initcode := assemble(test, "PUSH "+beneficiary.Hex()+"; SELFDESTRUCT")
+		result, err := ApplyMessage(evm, &Message{
+			From:     testSender,
+			Value:    uint256.NewInt(7),
+			GasLimit: 100_000,
+			GasPrice: uint256.NewInt(10),
+			Data:     initcode,
+		})
+		require.NoError(test, err)
+		require.NoError(test, result.Err)
+
+		assert.False(test, evm.State.Exist(result.ContractAddress))
+		assert.Equal(test, uint64(7), evm.State.GetBalance(beneficiary).Uint64())
+	})
+
+	test.Run("SELFDESTRUCT in a static call", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.State.SetCode(testLibrary, assemble(test, "PUSH 0; SELFDESTRUCT"))
+		_, _, err := evm.StaticCall(testSender, testLibrary, nil, 100_000)
+		assert.ErrorIs(test, err, ErrWriteProtection)
+	})
+}
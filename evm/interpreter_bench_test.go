@@ -0,0 +1,57 @@
+package evm
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/Manuelshub/go-EVM/assembler"
+	t "github.com/Manuelshub/go-EVM/types"
+)
+
+// Loop bodies run by the interpreter benchmarks, each leaves the stack as it found it
+var benchmarkBodies = []struct {
+	name string
+	body string
+}{
+	{"Empty", ""},
+	{"Arithmetic", `PUSH 3; DUP2; MUL; PUSH 7; ADD; DUP2; XOR; PUSH 0xffff; AND
+		PUSH 5; SWAP1; DIV; PUSH 2; EXP; NOT; PUSH 1; OR; DUP2; SWAP1; MOD; PUSH 0; MSTORE`},
+	{"Memory", "DUP1; PUSH 0x40; MSTORE; PUSH 0x40; MLOAD; PUSH 0x20; MSTORE; PUSH 1; PUSH 0x1f; MSTORE8"},
+	{"Push32", "PUSH32 0x0102030405060708091011121314151617181920212223242526272829303132; PUSH 0; MSTORE"},
+	{"StackShuffle", "PUSH 1; PUSH 2; PUSH 3; DUP3; DUP3; SWAP4; SWAP2; SWAP1; SWAP3; MUL; MUL; MUL; MUL; PUSH 0; MSTORE"},
+}
+
+// benchmarkProgram assembles a loop running body the given number of times
+func benchmarkProgram(b *testing.B, body string, iterations int) []byte {
+	code, err := assembler.Assemble(fmt.Sprintf(`PUSH %d
+		loop: JUMPDEST
+		%s
+		PUSH 1; SWAP1; SUB
+		DUP1; PUSH @loop; JUMPI
+		STOP`, iterations, body))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return code
+}
+
+func BenchmarkInterpreter(b *testing.B) {
+	for _, bench := range benchmarkBodies {
+		b.Run(bench.name, func(b *testing.B) {
+			code := benchmarkProgram(b, bench.body, 1000)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				ctx := NewExecutionContext()
+				ctx.GasMeter = t.NewGasMeter(math.MaxUint64)
+				if _, err := ctx.Run(code); err != nil {
+					b.Fatal(err)
+				}
+				if ctx.Stack.Size() != 1 {
+					b.Fatalf("stack size %d, want 1", ctx.Stack.Size())
+				}
+			}
+		})
+	}
+}
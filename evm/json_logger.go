@@ -0,0 +1,135 @@
+package evm
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// StructLog is a single line of an EIP-3155 trace, describing the state of the
+// frame before an opcode is executed
+type StructLog struct {
+	Pc            uint64         `json:"pc"`
+	Op            byte           `json:"op"`
+	Gas           hexutil.Uint64 `json:"gas"`
+	GasCost       hexutil.Uint64 `json:"gasCost"`
+	MemorySize    int            `json:"memSize"`
+	Stack         []string       `json:"stack"`
+	ReturnData    hexutil.Bytes  `json:"returnData,omitempty"`
+	Depth         int            `json:"depth"`
+	RefundCounter uint64         `json:"refund"`
+	OpName        string         `json:"opName"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// TraceSummary is the last line of an EIP-3155 trace
+type TraceSummary struct {
+	Output  string              `json:"output"`
+	GasUsed math.HexOrDecimal64 `json:"gasUsed"`
+	Error   string              `json:"error,omitempty"`
+
+	RevertReason string `json:"revertReason,omitempty"` // Decoded revert data, not part of EIP-3155
+}
+
+// JSONLogger writes EIP-3155 traces, one JSON object per line, in the same
+// format as `geth evm --json`
+type JSONLogger struct {
+	encoder *json.Encoder
+	errors  *ErrorDecoder // Decoder of the EVM running the transaction
+}
+
+// NewJSONLogger creates a JSONLogger writing to w
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	return &JSONLogger{encoder: json.NewEncoder(w)}
+}
+
+// Hooks returns the tracer hooks writing the trace. The summary line is written
+// when the frame of the transaction exits.
+func (l *JSONLogger) Hooks() *Hooks {
+	return &Hooks{
+		OnTxStart: l.OnTxStart,
+		OnOpcode:  l.OnOpcode,
+		OnFault:   l.OnFault,
+		OnExit:    l.OnExit,
+	}
+}
+
+// OnTxStart keeps the error decoder of the EVM to decode the revert reason
+func (l *JSONLogger) OnTxStart(evm *EVM, msg *Message) {
+	l.errors = evm.Errors
+}
+
+// OnOpcode writes the trace line of the opcode about to be executed
+func (l *JSONLogger) OnOpcode(pc uint64, op t.Opcode, gas, cost uint64, scope *ExecutionContext, depth int, err error) {
+	stack := make([]string, 0, scope.Stack.Size())
+	for _, item := range scope.Stack.Data() {
+		stack = append(stack, item.Hex())
+	}
+	// Bare bytecode runs outside of any call frame, it is reported at depth 1
+	if depth == 0 {
+		depth = 1
+	}
+
+	log := &StructLog{
+		Pc:            pc,
+		Op:            byte(op),
+		Gas:           hexutil.Uint64(gas),
+		GasCost:       hexutil.Uint64(cost),
+		MemorySize:    int(scope.Memory.Size()),
+		Stack:         stack,
+		ReturnData:    scope.CallReturnData,
+		Depth:         depth,
+		RefundCounter: scope.GasMeter.GasRefunded(),
+		OpName:        GetOpcodeName(byte(op)),
+	}
+	if err != nil {
+		log.Error = err.Error()
+	}
+	l.encoder.Encode(log)
+}
+
+// OnFault writes a second trace line for an opcode whose execution failed
+func (l *JSONLogger) OnFault(pc uint64, op t.Opcode, gas, cost uint64, scope *ExecutionContext, depth int, err error) {
+	l.OnOpcode(pc, op, gas, cost, scope, depth, err)
+}
+
+// OnExit writes the summary line when the frame of the transaction exits
+func (l *JSONLogger) OnExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	if depth == 0 {
+		l.writeSummary(output, gasUsed, err)
+	}
+}
+
+func (l *JSONLogger) writeSummary(output []byte, gasUsed uint64, err error) {
+	summary := &TraceSummary{
+		Output:  hex.EncodeToString(output),
+		GasUsed: math.HexOrDecimal64(gasUsed),
+	}
+	if err != nil {
+		summary.Error = errorCause(err).Error()
+	}
+	if reason := l.errors.revertReason(output, err); reason != nil {
+		summary.RevertReason = reason.String()
+	}
+	l.encoder.Encode(summary)
+}
+
+// Trace executes bare bytecode like Run, writing a trace line for every executed
+// opcode followed by the summary line
+func (l *JSONLogger) Trace(ctx *ExecutionContext, bytecode []byte) ([]byte, error) {
+	tracer := ctx.Tracer
+	ctx.Tracer = l.Hooks()
+	if ctx.EVM != nil {
+		l.errors = ctx.EVM.Errors
+	}
+	defer func() { ctx.Tracer = tracer }()
+
+	gasStart := ctx.GasMeter.GasRemaining()
+	output, err := ctx.Run(bytecode)
+	l.writeSummary(output, gasStart-ctx.GasMeter.GasRemaining(), err)
+	return output, err
+}
@@ -0,0 +1,59 @@
+package evm
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLogger(test *testing.T) {
+	tests := []struct {
+		name     string
+		bytecode string
+		lines    int
+		last     StructLog
+		summary  TraceSummary
+	}{
+		{
+			name:     "Successful execution",
+			bytecode: "0x600160020160005260206000f3", // MSTORE(0, 1+2) RETURN(0, 32)
+			lines:    8,
+			last:     StructLog{Pc: 12, Op: 0xf3, OpName: "RETURN", Stack: []string{"0x20", "0x0"}, MemorySize: 32, Depth: 1},
+			summary:  TraceSummary{Output: common.Bytes2Hex(common.LeftPadBytes([]byte{3}, 32))},
+		},
+		{
+			name:     "Invalid opcode",
+			bytecode: "0x6001fe",
+			lines:    2,
+			last:     StructLog{Pc: 2, Op: 0xfe, OpName: "INVALID", Stack: []string{"0x1"}, Depth: 1, Error: ErrInvalidOpcode.Error()},
+			summary:  TraceSummary{Error: ErrInvalidOpcode.Error()},
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			var out bytes.Buffer
+			ctx := NewExecutionContext()
+			NewJSONLogger(&out).Trace(ctx, common.FromHex(tt.bytecode))
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			require.Len(test, lines, tt.lines+1)
+
+			var last StructLog
+			require.NoError(test, json.Unmarshal([]byte(lines[tt.lines-1]), &last))
+			tt.last.Gas, tt.last.GasCost = last.Gas, last.GasCost
+			assert.Equal(test, tt.last, last)
+
+			var summary TraceSummary
+			require.NoError(test, json.Unmarshal([]byte(lines[tt.lines]), &summary))
+			assert.Equal(test, tt.summary.Output, summary.Output)
+			assert.Equal(test, tt.summary.Error, summary.Error)
+			assert.Equal(test, ctx.GasMeter.GasConsumed(), uint64(summary.GasUsed))
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package evm
+
+import (
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// The precompiled contracts of Prague, at addresses 0x01 to 0x11: ecrecover,
+// sha256, ripemd160, identity, modexp, the bn256 operations, blake2f, the KZG
+// point evaluation (EIP-4844) and the BLS12-381 operations (EIP-2537). Their
+// implementations are the ones of go-ethereum.
+var (
+	precompiles         = vm.PrecompiledContractsPrague
+	precompileAddresses = vm.PrecompiledAddressesPrague // Warm from the start of every transaction (EIP-2929)
+)
+
+// runPrecompile runs a precompiled contract inside the given frame. Its gas is
+// charged up front, and a failure consumes all the gas of the frame.
+func (evm *EVM) runPrecompile(snapshot int, frame *ExecutionContext, contract vm.PrecompiledContract) ([]byte, *t.GasMeter, error) {
+	meter := frame.GasMeter
+	gas := meter.GasRemaining()
+	if err := meter.UseGas(contract.RequiredGas(frame.Input)); err != nil {
+		evm.failFrame(snapshot, meter, err)
+		return nil, meter, err
+	}
+	evm.Tracer.captureGasChange(gas, meter.GasRemaining(), GasChangeCallPrecompiledContract)
+
+	ret, err := contract.Run(frame.Input)
+	if err != nil {
+		evm.failFrame(snapshot, meter, err)
+		return nil, meter, err
+	}
+	return ret, meter, nil
+}
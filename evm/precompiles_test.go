@@ -0,0 +1,106 @@
+package evm
+
+import (
+	"testing"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrecompiledContracts(test *testing.T) {
+	key, _ := crypto.GenerateKey()
+	hash := crypto.Keccak256([]byte("message"))
+	signature, err := crypto.Sign(hash, key)
+	require.NoError(test, err)
+	// ecrecover takes the hash, v, r and s as 32-byte words
+	recoverInput := append(append(common.CopyBytes(hash), common.LeftPadBytes([]byte{signature[64] + 27}, 32)...), signature[:64]...)
+
+	tests := []struct {
+		name    string
+		address common.Address
+		input   []byte
+		gas     uint64
+		output  []byte
+		used    uint64
+		err     error
+	}{
+		{
+			name:    "ecrecover",
+			address: common.BytesToAddress([]byte{0x01}),
+			input:   recoverInput,
+			gas:     10_000,
+			output:  common.LeftPadBytes(crypto.PubkeyToAddress(key.PublicKey).Bytes(), 32),
+			used:    3000,
+		},
+		{
+			name:    "sha256",
+			address: common.BytesToAddress([]byte{0x02}),
+			gas:     10_000,
+			output:  common.FromHex("0xe3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"),
+			used:    60,
+		},
+		{
+			name:    "identity",
+			address: common.BytesToAddress([]byte{0x04}),
+			input:   []byte("hello"),
+			gas:     10_000,
+			output:  []byte("hello"),
+			used:    15 + 3,
+		},
+		{
+			name:    "Not enough gas",
+			address: common.BytesToAddress([]byte{0x02}),
+			gas:     59,
+			used:    59,
+			err:     ErrOutOfGas,
+		},
+		{
+			// The point (1, 3) is not on the curve
+			name:    "Failure consumes all the gas",
+			address: common.BytesToAddress([]byte{0x06}),
+			input:   append(common.LeftPadBytes([]byte{1}, 32), common.LeftPadBytes([]byte{3}, 32)...),
+			gas:     10_000,
+			used:    10_000,
+		},
+	}
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			evm := newTestEVM()
+			ret, meter, err := evm.Call(testSender, tt.address, tt.input, tt.gas, uint256.NewInt(0))
+			switch {
+			case tt.err != nil:
+				assert.ErrorIs(test, err, tt.err)
+			case tt.output == nil:
+				assert.Error(test, err)
+			default:
+				require.NoError(test, err)
+				assert.Equal(test, tt.output, ret)
+			}
+			assert.Equal(test, tt.used, meter.GasConsumed())
+			assert.False(test, evm.State.Exist(tt.address))
+		})
+	}
+
+	test.Run("Precompiled contracts are warm", func(test *testing.T) {
+		evm := newTestEVM()
+		// STATICCALL(GAS, sha256, 0, 0, 0, 32) then SSTORE(0, MLOAD(0))
+		evm.State.SetCode(testReceiver, assemble(test, "PUSH 32; PUSH 0; PUSH 0; PUSH 0; PUSH 2; GAS; STATICCALL; POP; PUSH 0; MLOAD; PUSH 0; SSTORE"))
+		result, err := ApplyMessage(evm, &Message{
+			From:     testSender,
+			To:       &testReceiver,
+			GasLimit: 100_000,
+			GasPrice: uint256.NewInt(10),
+		})
+		require.NoError(test, err)
+		require.NoError(test, result.Err)
+		assert.Equal(test, common.HexToHash("0xe3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"), evm.State.GetState(testReceiver, common.Hash{}))
+		// Memory is expanded to one word by the call
+		call := 3 + 3 + 3 + 3 + 3 + 2 + t.GasWarmAccess + t.CalculateMemoryGasCost(0, 32) + 60 + 2
+		store := 3 + 3 + 3 + t.GasColdSload + t.GasStorageSet
+		assert.Equal(test, t.TxGas+call+store, result.UsedGas)
+	})
+}
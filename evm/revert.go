@@ -0,0 +1,262 @@
+package evm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ErrInvalidErrorFile is returned when a file is neither an ABI nor a signature database
+var ErrInvalidErrorFile = errors.New("invalid ABI or signature database")
+
+// Selectors of the errors emitted by Solidity itself
+var (
+	errorSelector = [4]byte{0x08, 0xc3, 0x79, 0xa0} // Error(string)
+	panicSelector = [4]byte{0x4e, 0x48, 0x7b, 0x71} // Panic(uint256)
+)
+
+// panicReasons describes the Solidity panic codes
+var panicReasons = map[uint64]string{
+	0x00: "generic compiler panic",
+	0x01: "assert(false)",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "enum conversion out of range",
+	0x22: "invalid encoded storage byte array",
+	0x31: "pop on empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call to uninitialized internal function",
+}
+
+// RevertReason is the decoded data of a REVERT
+type RevertReason struct {
+	Selector  [4]byte
+	Name      string        // "Error", "Panic" or the name of the custom error
+	Signature string        // Canonical signature, e.g. "Panic(uint256)"
+	Args      []interface{} // Decoded arguments
+	Message   string        // Message of Error(string), or description of the panic code
+}
+
+// String returns the message of Error(string), the description of a panic, or the
+// custom error with its arguments, e.g. "InsufficientBalance(10, 20)"
+func (reason *RevertReason) String() string {
+	switch reason.Signature {
+	case "Error(string)":
+		return reason.Message
+	case "Panic(uint256)":
+		return fmt.Sprintf("%s (panic code 0x%x)", reason.Message, reason.Args[0])
+	}
+	args := make([]string, len(reason.Args))
+	for i, arg := range reason.Args {
+		args[i] = FormatValue(arg)
+	}
+	return fmt.Sprintf("%s(%s)", reason.Name, strings.Join(args, ", "))
+}
+
+// ErrorDecoder decodes revert data. Error(string) and Panic(uint256) are always
+// known, custom errors are added from ABIs or signature databases.
+type ErrorDecoder struct {
+	errors map[[4]byte]abi.Error
+}
+
+// NewErrorDecoder creates an ErrorDecoder knowing no custom error
+func NewErrorDecoder() *ErrorDecoder {
+	return &ErrorDecoder{errors: make(map[[4]byte]abi.Error)}
+}
+
+// Len returns the number of custom errors known
+func (d *ErrorDecoder) Len() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.errors)
+}
+
+// AddABI adds the custom errors of a contract ABI
+func (d *ErrorDecoder) AddABI(contract *abi.ABI) {
+	for _, e := range contract.Errors {
+		d.add(e)
+	}
+}
+
+// AddSignature adds a custom error given by its text signature, e.g.
+// "InsufficientBalance(uint256,uint256)"
+func (d *ErrorDecoder) AddSignature(signature string) error {
+	name, inputs, err := ParseSignature(signature)
+	if err != nil {
+		return err
+	}
+	d.add(abi.NewError(name, inputs))
+	return nil
+}
+
+func (d *ErrorDecoder) add(e abi.Error) {
+	var selector [4]byte
+	copy(selector[:], e.ID[:4])
+	d.errors[selector] = e
+}
+
+// Load adds the custom errors of a file, returning the number of errors added. The
+// file holds either a contract ABI, bare or as the "abi" field of a compiler
+// artifact, or a 4-byte signature database mapping selectors to signatures:
+//
+//	{"0xcf479181": "InsufficientBalance(uint256,uint256)"}
+func (d *ErrorDecoder) Load(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	before := d.Len()
+
+	contract, err := ReadABI(data)
+	if err == nil {
+		d.AddABI(contract)
+		return d.Len() - before, nil
+	}
+
+	var signatures map[string]string
+	if err := json.Unmarshal(data, &signatures); err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidErrorFile, path)
+	}
+	for selector, signature := range signatures {
+		name, inputs, err := ParseSignature(signature)
+		if err != nil {
+			return 0, err
+		}
+		e := abi.NewError(name, inputs)
+		if !bytes.Equal(common.FromHex(selector), e.ID[:4]) {
+			return 0, fmt.Errorf("%w: selector %s does not match %s", ErrInvalidErrorFile, selector, signature)
+		}
+		d.add(e)
+	}
+	return d.Len() - before, nil
+}
+
+// ReadABI parses a contract ABI given as JSON, either bare or as the "abi" field
+// of a compiler artifact
+func ReadABI(data []byte) (*abi.ABI, error) {
+	var artifact struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		if err := json.Unmarshal(data, &artifact); err != nil || artifact.ABI == nil {
+			return nil, errors.New("no abi field")
+		}
+		data = artifact.ABI
+	}
+	contract, err := abi.JSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &contract, nil
+}
+
+// Decode decodes revert data, returning nil if it is not a known error or does
+// not match its signature. A nil decoder decodes Error(string) and Panic(uint256).
+func (d *ErrorDecoder) Decode(data []byte) *RevertReason {
+	if len(data) < 4 {
+		return nil
+	}
+	var selector [4]byte
+	copy(selector[:], data[:4])
+
+	var e abi.Error
+	switch {
+	case selector == errorSelector:
+		e = builtinError("Error", "string")
+	case selector == panicSelector:
+		e = builtinError("Panic", "uint256")
+	case d != nil:
+		var ok bool
+		if e, ok = d.errors[selector]; !ok {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	args, err := e.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil
+	}
+	reason := &RevertReason{Selector: selector, Name: e.Name, Signature: e.Sig, Args: args}
+	switch selector {
+	case errorSelector:
+		reason.Message = args[0].(string)
+	case panicSelector:
+		code := args[0].(*big.Int)
+		reason.Message = "unknown panic code"
+		if code.IsUint64() {
+			if description, ok := panicReasons[code.Uint64()]; ok {
+				reason.Message = description
+			}
+		}
+	}
+	return reason
+}
+
+// builtinError creates the abi.Error of an error with a single argument
+func builtinError(name, typ string) abi.Error {
+	argType, _ := abi.NewType(typ, "", nil)
+	return abi.NewError(name, abi.Arguments{{Name: "arg0", Type: argType}})
+}
+
+// revertReason decodes the revert data of a frame that failed with err, nil if
+// the frame was not reverted by REVERT
+func (d *ErrorDecoder) revertReason(output []byte, err error) *RevertReason {
+	if !errors.Is(err, ErrExecutionReverted) {
+		return nil
+	}
+	return d.Decode(output)
+}
+
+// FormatValue formats a value decoded by the ABI package: addresses and bytes in
+// hex, strings quoted, integers in decimal, arrays and tuples between brackets
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	case string:
+		return fmt.Sprintf("%q", v)
+	case *big.Int:
+		return v.String()
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return hexutil.Encode(b)
+		}
+		fallthrough
+	case reflect.Slice:
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = FormatValue(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Struct:
+		items := make([]string, rv.NumField())
+		for i := range items {
+			items[i] = FormatValue(rv.Field(i).Interface())
+		}
+		return "(" + strings.Join(items, ", ") + ")"
+	}
+	return fmt.Sprintf("%v", value)
+}
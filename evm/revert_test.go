@@ -0,0 +1,202 @@
+package evm
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testErrorABI = `[{"type":"error","name":"InsufficientBalance","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]}]`
+
+// encodeError returns the revert data of an error given by its signature
+func encodeError(test *testing.T, signature string, args ...interface{}) []byte {
+	name, inputs, err := ParseSignature(signature)
+	require.NoError(test, err)
+	data, err := inputs.Pack(args...)
+	require.NoError(test, err)
+	id := abi.NewError(name, inputs).ID
+	return append(id[:4:4], data...)
+}
+
+// revertCode returns code reverting with data
+func revertCode(data []byte) []byte {
+	var code []byte
+	for offset := 0; offset < len(data); offset += 32 {
+		word := make([]byte, 32)
+		copy(word, data[offset:])
+		code = append(code, byte(0x7f))
+		code = append(code, word...)
+		code = append(code, 0x60, byte(offset), 0x52)
+	}
+	return append(code, 0x60, byte(len(data)), 0x60, 0x00, 0xfd)
+}
+
+func TestDecodeRevert(test *testing.T) {
+	decoder := NewErrorDecoder()
+	require.NoError(test, decoder.AddSignature("Unauthorized(address caller)"))
+	caller := common.HexToAddress("0x1234")
+
+	tests := []struct {
+		name    string
+		decoder *ErrorDecoder
+		data    []byte
+		reason  string
+		message string
+	}{
+		{
+			name:    "Error(string)",
+			data:    encodeError(test, "Error(string)", "insufficient balance"),
+			reason:  "insufficient balance",
+			message: "insufficient balance",
+		},
+		{
+			name:    "Panic(uint256)",
+			data:    encodeError(test, "Panic(uint256)", big.NewInt(0x11)),
+			reason:  "arithmetic underflow or overflow (panic code 0x11)",
+			message: "arithmetic underflow or overflow",
+		},
+		{
+			name:    "Unknown panic code",
+			data:    encodeError(test, "Panic(uint256)", big.NewInt(0x99)),
+			reason:  "unknown panic code (panic code 0x99)",
+			message: "unknown panic code",
+		},
+		{
+			name:    "Custom error",
+			decoder: decoder,
+			data:    encodeError(test, "Unauthorized(address)", caller),
+			reason:  "Unauthorized(" + caller.Hex() + ")",
+		},
+		{
+			name: "Custom error without decoder",
+			data: encodeError(test, "Unauthorized(address)", caller),
+		},
+		{
+			name:    "Unknown selector",
+			decoder: decoder,
+			data:    encodeError(test, "Other(uint256)", big.NewInt(1)),
+		},
+		{
+			name:    "Malformed data",
+			decoder: decoder,
+			data:    encodeError(test, "Error(string)", "nope")[:40],
+		},
+		{
+			name: "Short data",
+			data: []byte{0x08, 0xc3, 0x79},
+		},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			reason := tt.decoder.Decode(tt.data)
+			if tt.reason == "" {
+				assert.Nil(test, reason)
+				return
+			}
+			require.NotNil(test, reason)
+			assert.Equal(test, tt.reason, reason.String())
+			assert.Equal(test, tt.message, reason.Message)
+			assert.Equal(test, tt.data[:4], reason.Selector[:])
+		})
+	}
+}
+
+func TestErrorDecoderLoad(test *testing.T) {
+	data := encodeError(test, "InsufficientBalance(uint256,uint256)", big.NewInt(10), big.NewInt(20))
+	dir := test.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(test, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		count   int
+		err     bool
+	}{
+		{name: "ABI", content: testErrorABI, count: 1},
+		{name: "Artifact", content: `{"contractName":"Token","abi":` + testErrorABI + `}`, count: 1},
+		{name: "Signature database", content: `{"0xcf479181":"InsufficientBalance(uint256,uint256)","0x8e4a23d6":"Unauthorized(address)"}`, count: 2},
+		{name: "Mismatched selector", content: `{"0x12345678":"InsufficientBalance(uint256,uint256)"}`, err: true},
+		{name: "Invalid file", content: `not json`, err: true},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			decoder := NewErrorDecoder()
+			count, err := decoder.Load(write(strings.ReplaceAll(tt.name, " ", "_")+".json", tt.content))
+			if tt.err {
+				assert.ErrorIs(test, err, ErrInvalidErrorFile)
+				return
+			}
+			require.NoError(test, err)
+			assert.Equal(test, tt.count, count)
+
+			reason := decoder.Decode(data)
+			require.NotNil(test, reason)
+			assert.Equal(test, "InsufficientBalance(10, 20)", reason.String())
+			assert.Equal(test, "InsufficientBalance(uint256,uint256)", reason.Signature)
+		})
+	}
+}
+
+func TestRevertReasonInResultAndTraces(test *testing.T) {
+	data := encodeError(test, "InsufficientBalance(uint256,uint256)", big.NewInt(10), big.NewInt(20))
+	evm := newTestEVM()
+	evm.Errors = NewErrorDecoder()
+	require.NoError(test, evm.Errors.AddSignature("InsufficientBalance(uint256,uint256)"))
+	evm.State.SetCode(testReceiver, revertCode(data))
+
+	tracer := NewCallTracer(false)
+	var trace strings.Builder
+	logger := NewJSONLogger(&trace)
+	evm.Tracer = &Hooks{
+		OnTxStart: func(evm *EVM, msg *Message) {
+			tracer.OnTxStart(evm, msg)
+			logger.OnTxStart(evm, msg)
+		},
+		OnEnter: tracer.OnEnter,
+		OnExit: func(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+			tracer.OnExit(depth, output, gasUsed, err, reverted)
+			logger.OnExit(depth, output, gasUsed, err, reverted)
+		},
+	}
+	result, err := ApplyMessage(evm, &Message{
+		From:     testSender,
+		To:       &testReceiver,
+		GasLimit: 100_000,
+		GasPrice: uint256.NewInt(10),
+	})
+	require.NoError(test, err)
+	assert.ErrorIs(test, result.Err, ErrExecutionReverted)
+	require.NotNil(test, result.RevertReason)
+	assert.Equal(test, "InsufficientBalance", result.RevertReason.Name)
+	assert.Equal(test, []interface{}{big.NewInt(10), big.NewInt(20)}, result.RevertReason.Args)
+
+	assert.Equal(test, "InsufficientBalance(10, 20)", tracer.Result().RevertReason)
+	assert.Contains(test, trace.String(), `"revertReason":"InsufficientBalance(10, 20)"`)
+}
+
+func TestFormatValue(test *testing.T) {
+	type pair struct {
+		A *big.Int
+		B bool
+	}
+	assert.Equal(test, "0x0102", FormatValue([]byte{1, 2}))
+	assert.Equal(test, "0x0a0b", FormatValue([2]byte{10, 11}))
+	assert.Equal(test, `"hi"`, FormatValue("hi"))
+	assert.Equal(test, "[1, 2]", FormatValue([]*big.Int{big.NewInt(1), big.NewInt(2)}))
+	assert.Equal(test, "(7, true)", FormatValue(pair{A: big.NewInt(7), B: true}))
+	assert.Equal(test, "42", FormatValue(uint8(42)))
+}
@@ -0,0 +1,82 @@
+package evm
+
+import (
+	"errors"
+	"fmt"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+)
+
+// Errors of EIP-7702 set-code transactions
+var (
+	ErrEmptyAuthList   = errors.New("EIP-7702 transaction with empty auth list")
+	ErrSetCodeTxCreate = errors.New("EIP-7702 transaction cannot be used to create contract")
+)
+
+// Errors of individual EIP-7702 authorizations. An invalid authorization is
+// skipped, it does not make the transaction invalid.
+var (
+	ErrAuthorizationWrongChainID       = errors.New("EIP-7702 authorization chain ID mismatch")
+	ErrAuthorizationNonceOverflow      = errors.New("EIP-7702 authorization nonce > 64 bit")
+	ErrAuthorizationInvalidSignature   = errors.New("EIP-7702 authorization has invalid signature")
+	ErrAuthorizationDestinationHasCode = errors.New("EIP-7702 authorization destination is a contract")
+	ErrAuthorizationNonceMismatch      = errors.New("EIP-7702 authorization nonce does not match current account nonce")
+)
+
+// validateAuthorization checks an authorization against the chain and the state
+// and returns the recovered authority
+func (st *stateTransition) validateAuthorization(auth *gethtypes.SetCodeAuthorization) (common.Address, error) {
+	state := st.evm.State
+
+	// The chain ID must be zero (any chain) or the current chain
+	if !auth.ChainID.IsZero() && !auth.ChainID.Eq(uint256.NewInt(st.evm.Block.ChainID)) {
+		return common.Address{}, ErrAuthorizationWrongChainID
+	}
+	// The nonce is limited to 2^64-1 (EIP-2681)
+	if auth.Nonce+1 < auth.Nonce {
+		return common.Address{}, ErrAuthorizationNonceOverflow
+	}
+	authority, err := auth.Authority()
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", ErrAuthorizationInvalidSignature, err)
+	}
+
+	// The authority is warm even if the authorization turns out to be invalid
+	state.AddAddressToAccessList(authority)
+
+	// The authority must not have code other than an existing delegation
+	code := state.GetCode(authority)
+	if _, ok := gethtypes.ParseDelegation(code); len(code) != 0 && !ok {
+		return authority, ErrAuthorizationDestinationHasCode
+	}
+	if nonce := state.GetNonce(authority); nonce != auth.Nonce {
+		return authority, ErrAuthorizationNonceMismatch
+	}
+	return authority, nil
+}
+
+// applyAuthorization sets the delegation designator of an authority. Delegating to
+// the zero address clears the delegation.
+func (st *stateTransition) applyAuthorization(auth *gethtypes.SetCodeAuthorization) error {
+	authority, err := st.validateAuthorization(auth)
+	if err != nil {
+		return err
+	}
+	state := st.evm.State
+
+	// The intrinsic gas assumed a new account, refund the difference for existing ones
+	if state.Exist(authority) {
+		st.refund += t.GasPerEmptyAccountCost - t.GasPerAuthBaseCost
+	}
+
+	state.SetNonce(authority, auth.Nonce+1)
+	if auth.Address == (common.Address{}) {
+		state.SetCode(authority, nil)
+		return nil
+	}
+	state.SetCode(authority, gethtypes.AddressToDelegation(auth.Address))
+	return nil
+}
@@ -0,0 +1,157 @@
+package evm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// ErrInvalidSignature is returned when a text signature cannot be parsed
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// ParseSignature parses a text signature such as "transfer(address,uint256)" into
+// its name and arguments. Parameter names are allowed after the types, and tuples
+// are written between parentheses, e.g. "f((uint256,address)[] items)".
+func ParseSignature(signature string) (string, abi.Arguments, error) {
+	name, inputs, outputs, err := ParseMethodSignature(signature)
+	if err == nil && outputs != nil {
+		err = fmt.Errorf("%w: %q", ErrInvalidSignature, signature)
+	}
+	return name, inputs, err
+}
+
+// ParseMethodSignature parses a method signature optionally followed by the types
+// it returns, e.g. "balanceOf(address)(uint256)". outputs is nil when the return
+// types are not given.
+func ParseMethodSignature(signature string) (name string, inputs, outputs abi.Arguments, err error) {
+	signature = strings.TrimSpace(signature)
+	open := strings.Index(signature, "(")
+	if open <= 0 {
+		return "", nil, nil, fmt.Errorf("%w: %q", ErrInvalidSignature, signature)
+	}
+	name = strings.TrimSpace(signature[:open])
+	if strings.ContainsAny(name, " ,()") {
+		return "", nil, nil, fmt.Errorf("%w: %q", ErrInvalidSignature, signature)
+	}
+
+	rest := signature[open:]
+	if inputs, rest, err = parseArguments(rest); err != nil {
+		return "", nil, nil, fmt.Errorf("%w: %q: %v", ErrInvalidSignature, signature, err)
+	}
+	if rest = strings.TrimSpace(rest); rest != "" {
+		if outputs, rest, err = parseArguments(rest); err != nil || strings.TrimSpace(rest) != "" {
+			return "", nil, nil, fmt.Errorf("%w: %q", ErrInvalidSignature, signature)
+		}
+	}
+	return name, inputs, outputs, nil
+}
+
+// parseArguments parses the parenthesized argument list s starts with, returning
+// the text following it
+func parseArguments(s string) (abi.Arguments, string, error) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, s, errors.New("missing parentheses")
+	}
+	end := matchingParen(s)
+	if end < 0 {
+		return nil, s, errors.New("unbalanced parentheses")
+	}
+
+	params, err := parseParams(s[1:end])
+	if err != nil {
+		return nil, s, err
+	}
+	arguments := make(abi.Arguments, 0, len(params))
+	for _, param := range params {
+		typ, err := abi.NewType(param.Type, "", param.Components)
+		if err != nil {
+			return nil, s, err
+		}
+		arguments = append(arguments, abi.Argument{Name: param.Name, Type: typ})
+	}
+	return arguments, s[end+1:], nil
+}
+
+// parseParams parses a comma separated list of parameters, the content of the
+// parentheses of a signature or of a tuple
+func parseParams(list string) ([]abi.ArgumentMarshaling, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	var params []abi.ArgumentMarshaling
+	for _, item := range splitList(list) {
+		param, err := parseParam(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// parseParam parses a single parameter: a type followed by an optional name
+func parseParam(item string) (abi.ArgumentMarshaling, error) {
+	if item == "" {
+		return abi.ArgumentMarshaling{}, errors.New("empty parameter")
+	}
+
+	var param abi.ArgumentMarshaling
+	if item[0] == '(' {
+		// Tuple: the components are between the matching parentheses, array
+		// suffixes may follow
+		end := matchingParen(item)
+		if end < 0 {
+			return param, errors.New("unbalanced parentheses")
+		}
+		components, err := parseParams(item[1:end])
+		if err != nil {
+			return param, err
+		}
+		// Tuple fields need names to be decoded into structs
+		for i := range components {
+			if components[i].Name == "" {
+				components[i].Name = fmt.Sprintf("field%d", i)
+			}
+		}
+		param.Components = components
+		item = "tuple" + item[end+1:]
+	}
+
+	fields := strings.Fields(item)
+	typ := fields[0]
+	if len(fields) > 1 {
+		// Data locations and indexed may come between the type and the name
+		param.Name = fields[len(fields)-1]
+	}
+	param.Type = canonicalType(typ)
+	return param, nil
+}
+
+// canonicalType expands the aliases of Solidity types, int and uint are 256 bits
+func canonicalType(typ string) string {
+	for _, alias := range []string{"uint", "int"} {
+		if typ == alias || strings.HasPrefix(typ, alias+"[") {
+			return alias + "256" + typ[len(alias):]
+		}
+	}
+	return typ
+}
+
+// matchingParen returns the index of the parenthesis closing the one s starts with
+func matchingParen(s string) int {
+	depth := 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,72 @@
+package evm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSignature(test *testing.T) {
+	tests := []struct {
+		name      string
+		signature string
+		function  string
+		types     []string
+		argNames  []string
+		err       bool
+	}{
+		{
+			name:      "Plain types",
+			signature: "transfer(address,uint256)",
+			function:  "transfer",
+			types:     []string{"address", "uint256"},
+			argNames:  []string{"", ""},
+		},
+		{
+			name:      "No arguments",
+			signature: "totalSupply()",
+			function:  "totalSupply",
+			types:     []string{},
+			argNames:  []string{},
+		},
+		{
+			name:      "Parameter names and aliases",
+			signature: " InsufficientBalance(uint available, int[] memory required) ",
+			function:  "InsufficientBalance",
+			types:     []string{"uint256", "int256[]"},
+			argNames:  []string{"available", "required"},
+		},
+		{
+			name:      "Tuples",
+			signature: "f((uint256,(address,bytes32))[2] items,bool)",
+			function:  "f",
+			types:     []string{"(uint256,(address,bytes32))[2]", "bool"},
+			argNames:  []string{"items", ""},
+		},
+		{name: "Missing parentheses", signature: "transfer", err: true},
+		{name: "Missing name", signature: "(uint256)", err: true},
+		{name: "Unknown type", signature: "f(uint256,foo)", err: true},
+		{name: "Unbalanced tuple", signature: "f((uint256)", err: true},
+		{name: "Empty parameter", signature: "f(uint256,)", err: true},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			name, args, err := ParseSignature(tt.signature)
+			if tt.err {
+				assert.ErrorIs(test, err, ErrInvalidSignature)
+				return
+			}
+			require.NoError(test, err)
+			assert.Equal(test, tt.function, name)
+			types, argNames := []string{}, []string{}
+			for _, arg := range args {
+				types = append(types, arg.Type.String())
+				argNames = append(argNames, arg.Name)
+			}
+			assert.Equal(test, tt.types, types)
+			assert.Equal(test, tt.argNames, argNames)
+		})
+	}
+}
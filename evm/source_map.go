@@ -0,0 +1,73 @@
+package evm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SourceMapEntry maps an instruction to a range of a source file, as found in
+// the compressed source maps emitted by solc
+type SourceMapEntry struct {
+	Start  int    // Byte offset of the range in the source file
+	Length int    // Length of the range
+	File   int    // Index of the source file, -1 for compiler generated code
+	Jump   string // "i" for a jump into a function, "o" for a return, "-" otherwise
+}
+
+// SourceFile is a source file referenced by a source map
+type SourceFile struct {
+	Path    string
+	Content []byte
+}
+
+// ParseSourceMap decodes a solc source map ("s:l:f:j;s:l:f:j;..."). There is one
+// entry per instruction, and empty fields repeat the value of the previous entry.
+func ParseSourceMap(sourceMap string) ([]SourceMapEntry, error) {
+	sourceMap = strings.TrimSpace(sourceMap)
+	if sourceMap == "" {
+		return nil, nil
+	}
+
+	var (
+		entries []SourceMapEntry
+		last    = SourceMapEntry{File: -1, Jump: "-"}
+	)
+	for i, item := range strings.Split(sourceMap, ";") {
+		entry := last
+		for field, value := range strings.Split(item, ":") {
+			if value == "" {
+				continue
+			}
+			if field == 3 {
+				entry.Jump = value
+				continue
+			}
+			if field > 3 {
+				// The modifier depth is not used
+				continue
+			}
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid source map entry %d: %q", i, item)
+			}
+			switch field {
+			case 0:
+				entry.Start = number
+			case 1:
+				entry.Length = number
+			case 2:
+				entry.File = number
+			}
+		}
+		entries = append(entries, entry)
+		last = entry
+	}
+	return entries, nil
+}
+
+// lineOf returns the 1-based line holding the given byte offset
+func (file *SourceFile) lineOf(offset int) int {
+	offset = min(offset, len(file.Content))
+	return strings.Count(string(file.Content[:offset]), "\n") + 1
+}
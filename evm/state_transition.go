@@ -0,0 +1,366 @@
+package evm
+
+import (
+	"errors"
+	"fmt"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+)
+
+// Errors that make a message invalid. When one of them is returned the message
+// is not included and the state is left untouched.
+var (
+	ErrNonceTooLow             = errors.New("nonce too low")
+	ErrNonceTooHigh            = errors.New("nonce too high")
+	ErrNonceMax                = errors.New("nonce has max value")
+	ErrSenderNoEOA             = errors.New("sender not an eoa")
+	ErrGasLimitReached         = errors.New("gas limit reached")
+	ErrInsufficientFunds       = errors.New("insufficient funds for gas * price + value")
+	ErrIntrinsicGas            = errors.New("intrinsic gas too low")
+	ErrTipAboveFeeCap          = errors.New("max priority fee per gas higher than max fee per gas")
+	ErrFeeCapTooLow            = errors.New("max fee per gas less than block base fee")
+	ErrMaxInitCodeSizeExceeded = errors.New("max initcode size exceeded")
+)
+
+// Message is a transaction reduced to the fields needed to execute it
+type Message struct {
+	From       common.Address
+	To         *common.Address // nil means contract creation
+	Nonce      uint64
+	Value      *uint256.Int
+	GasLimit   uint64
+	GasPrice   *uint256.Int // Legacy gas price, used when GasFeeCap is nil
+	GasFeeCap  *uint256.Int // EIP-1559 max fee per gas
+	GasTipCap  *uint256.Int // EIP-1559 max priority fee per gas
+	Data       []byte
+	AccessList gethtypes.AccessList
+
+	BlobGasFeeCap         *uint256.Int                     // EIP-4844 max fee per blob gas, nil for non-blob transactions
+	BlobHashes            []common.Hash                    // EIP-4844 versioned hashes of the blobs
+	SetCodeAuthorizations []gethtypes.SetCodeAuthorization // EIP-7702 authorization list, nil for other transactions
+}
+
+// ExecutionResult is the receipt-like outcome of applying a message
+type ExecutionResult struct {
+	UsedGas           uint64         // Gas charged to the sender, after refunds
+	BlobGasUsed       uint64         // Blob gas charged to the sender
+	RefundedGas       uint64         // Gas refunded to the sender at the end of execution
+	EffectiveGasPrice *uint256.Int   // Price paid per unit of gas
+	ReturnData        []byte         // Output of the call, or revert data
+	ContractAddress   common.Address // Address of the created contract, if any
+	Logs              []*t.Log       // Logs emitted during execution
+	Err               error          // Execution error, e.g. revert or out of gas
+	RevertReason      *RevertReason  // Decoded revert data, nil if not reverted or not decodable
+}
+
+// Failed returns true if the execution did not succeed
+func (result *ExecutionResult) Failed() bool {
+	return result.Err != nil
+}
+
+// Revert returns the revert data if the execution was reverted by REVERT
+func (result *ExecutionResult) Revert() []byte {
+	if !errors.Is(result.Err, ErrExecutionReverted) {
+		return nil
+	}
+	return common.CopyBytes(result.ReturnData)
+}
+
+// feeCaps returns the fee cap and tip cap of the message, falling back to the
+// legacy gas price for both when no EIP-1559 fields are set
+func (msg *Message) feeCaps() (feeCap, tipCap *uint256.Int) {
+	if msg.GasFeeCap == nil {
+		price := msg.GasPrice
+		if price == nil {
+			price = uint256.NewInt(0)
+		}
+		return price, price
+	}
+	tipCap = msg.GasTipCap
+	if tipCap == nil {
+		tipCap = uint256.NewInt(0)
+	}
+	return msg.GasFeeCap, tipCap
+}
+
+// EffectiveGasPrice returns the price per gas paid by the sender given the block
+// base fee: min(feeCap, baseFee + tipCap)
+func (msg *Message) EffectiveGasPrice(baseFee *uint256.Int) *uint256.Int {
+	feeCap, tipCap := msg.feeCaps()
+	if baseFee == nil {
+		return new(uint256.Int).Set(feeCap)
+	}
+	price := new(uint256.Int).Add(baseFee, tipCap)
+	if price.Gt(feeCap) {
+		price.Set(feeCap)
+	}
+	return price
+}
+
+// IntrinsicGas computes the gas charged for a message before any code runs
+func IntrinsicGas(data []byte, accessList gethtypes.AccessList, authList []gethtypes.SetCodeAuthorization, isContractCreation bool) uint64 {
+	gas := t.TxGas
+	if isContractCreation {
+		gas = t.TxGasContractCreation
+	}
+
+	// Transaction data is charged per byte
+	nonZero := uint64(0)
+	for _, b := range data {
+		if b != 0 {
+			nonZero++
+		}
+	}
+	zero := uint64(len(data)) - nonZero
+	gas += nonZero*t.TxDataNonZeroGas + zero*t.TxDataZeroGas
+
+	// Initcode is charged per word (EIP-3860)
+	if isContractCreation {
+		gas += (uint64(len(data)) + 31) / 32 * t.InitCodeWordGas
+	}
+
+	// Access list entries are charged upfront (EIP-2930)
+	gas += uint64(len(accessList)) * t.TxAccessListAddressGas
+	gas += uint64(accessList.StorageKeys()) * t.TxAccessListStorageKeyGas
+
+	// Every authorization is charged as if it created a new account (EIP-7702)
+	gas += uint64(len(authList)) * t.GasPerEmptyAccountCost
+
+	return gas
+}
+
+// stateTransition holds the bookkeeping of a single message being applied
+type stateTransition struct {
+	evm      *EVM
+	msg      *Message
+	gasPrice *uint256.Int
+	refund   uint64 // Refunds collected outside of the call frames
+}
+
+// ApplyMessage applies a message against the state of the EVM: it validates the
+// nonce and balance of the sender, buys gas, charges the intrinsic gas, runs the
+// call or creation, refunds unused gas and pays the fees. An error is returned
+// only if the message is invalid; execution failures are reported in the result.
+func ApplyMessage(evm *EVM, msg *Message) (*ExecutionResult, error) {
+	st := &stateTransition{
+		evm:      evm,
+		msg:      msg,
+		gasPrice: msg.EffectiveGasPrice(evm.Block.BaseFee),
+	}
+
+	if evm.Tracer != nil && evm.Tracer.OnTxStart != nil {
+		evm.Tracer.OnTxStart(evm, msg)
+	}
+	result, err := st.execute()
+	if evm.Tracer != nil && evm.Tracer.OnTxEnd != nil {
+		evm.Tracer.OnTxEnd(result, err)
+	}
+	return result, err
+}
+
+// value returns the value transferred by the message, zero if unset
+func (st *stateTransition) value() *uint256.Int {
+	if st.msg.Value == nil {
+		return uint256.NewInt(0)
+	}
+	return st.msg.Value
+}
+
+// preCheck validates the message against the current state and block. It does
+// not modify the state.
+func (st *stateTransition) preCheck() error {
+	msg, state := st.msg, st.evm.State
+
+	nonce := state.GetNonce(msg.From)
+	if msg.Nonce < nonce {
+		return fmt.Errorf("%w: address %v, tx: %d state: %d", ErrNonceTooLow, msg.From, msg.Nonce, nonce)
+	}
+	if msg.Nonce > nonce {
+		return fmt.Errorf("%w: address %v, tx: %d state: %d", ErrNonceTooHigh, msg.From, msg.Nonce, nonce)
+	}
+	if nonce+1 < nonce {
+		return fmt.Errorf("%w: address %v, nonce: %d", ErrNonceMax, msg.From, nonce)
+	}
+
+	// Only externally owned accounts can send transactions (EIP-3607). Accounts
+	// delegated with EIP-7702 are still externally owned.
+	code := state.GetCode(msg.From)
+	if _, delegated := gethtypes.ParseDelegation(code); len(code) != 0 && !delegated {
+		return fmt.Errorf("%w: address %v", ErrSenderNoEOA, msg.From)
+	}
+
+	if msg.SetCodeAuthorizations != nil {
+		if msg.To == nil {
+			return fmt.Errorf("%w: address %v", ErrSetCodeTxCreate, msg.From)
+		}
+		if len(msg.SetCodeAuthorizations) == 0 {
+			return fmt.Errorf("%w: address %v", ErrEmptyAuthList, msg.From)
+		}
+	}
+
+	if st.evm.Block.GasLimit != 0 && msg.GasLimit > st.evm.Block.GasLimit {
+		return fmt.Errorf("%w: tx gas %d, block gas limit %d", ErrGasLimitReached, msg.GasLimit, st.evm.Block.GasLimit)
+	}
+
+	feeCap, tipCap := msg.feeCaps()
+	if feeCap.Lt(tipCap) {
+		return fmt.Errorf("%w: tip %v, fee cap %v", ErrTipAboveFeeCap, tipCap, feeCap)
+	}
+	if baseFee := st.evm.Block.BaseFee; baseFee != nil && feeCap.Lt(baseFee) {
+		return fmt.Errorf("%w: fee cap %v, base fee %v", ErrFeeCapTooLow, feeCap, baseFee)
+	}
+	if err := st.checkBlobs(); err != nil {
+		return err
+	}
+
+	intrinsic := IntrinsicGas(msg.Data, msg.AccessList, msg.SetCodeAuthorizations, msg.To == nil)
+	if msg.GasLimit < intrinsic {
+		return fmt.Errorf("%w: have %d, want %d", ErrIntrinsicGas, msg.GasLimit, intrinsic)
+	}
+	if msg.To == nil && len(msg.Data) > t.MaxInitCodeSize {
+		return fmt.Errorf("%w: code size %d limit %d", ErrMaxInitCodeSizeExceeded, len(msg.Data), t.MaxInitCodeSize)
+	}
+	return nil
+}
+
+// buyGas checks that the sender can afford the worst case cost of the message,
+// including the value and the blob fee, and deducts gasLimit * gasPrice plus
+// blobGas * blobBaseFee from its balance
+func (st *stateTransition) buyGas() error {
+	msg, state := st.msg, st.evm.State
+	gasLimit := uint256.NewInt(msg.GasLimit)
+	blobGas := uint256.NewInt(msg.blobGas())
+
+	// The balance must cover the fee caps, not just the effective prices
+	feeCap, _ := msg.feeCaps()
+	required, overflow := new(uint256.Int).MulOverflow(gasLimit, feeCap)
+	if !overflow && msg.BlobGasFeeCap != nil {
+		blobCost, blobOverflow := new(uint256.Int).MulOverflow(blobGas, msg.BlobGasFeeCap)
+		_, overflow = required.AddOverflow(required, blobCost)
+		overflow = overflow || blobOverflow
+	}
+	if !overflow {
+		_, overflow = required.AddOverflow(required, st.value())
+	}
+	if balance := state.GetBalance(msg.From); overflow || balance.Lt(required) {
+		return fmt.Errorf("%w: address %v have %v want %v", ErrInsufficientFunds, msg.From, balance, required)
+	}
+
+	cost := new(uint256.Int).Mul(gasLimit, st.gasPrice)
+	// Blob gas is paid upfront at the blob base fee and burnt
+	if msg.blobGas() > 0 {
+		cost.Add(cost, blobGas.Mul(blobGas, st.evm.Block.BlobBaseFee()))
+	}
+	state.SubBalance(msg.From, cost)
+	return nil
+}
+
+// execute applies the message and returns the result
+func (st *stateTransition) execute() (*ExecutionResult, error) {
+	msg, evm, state := st.msg, st.evm, st.evm.State
+
+	// Earlier transactions are final, only this one can be reverted
+	state.ResetJournal()
+	if err := st.preCheck(); err != nil {
+		return nil, err
+	}
+	if err := st.buyGas(); err != nil {
+		return nil, err
+	}
+
+	isCreate := msg.To == nil
+	intrinsic := IntrinsicGas(msg.Data, msg.AccessList, msg.SetCodeAuthorizations, isCreate)
+
+	evm.Tx = TxContext{Origin: msg.From, GasPrice: st.gasPrice, BlobHashes: msg.BlobHashes}
+	st.prepareAccessList()
+	state.ResetLogs()
+
+	var (
+		ret      []byte
+		contract common.Address
+		meter    *t.GasMeter
+		vmerr    error
+	)
+	evm.Tracer.captureGasChange(0, msg.GasLimit, GasChangeTxInitialBalance)
+	gas := msg.GasLimit - intrinsic
+	evm.Tracer.captureGasChange(msg.GasLimit, gas, GasChangeTxIntrinsicGas)
+	if isCreate {
+		ret, contract, meter, vmerr = evm.Create(msg.From, msg.Data, gas, st.value())
+	} else {
+		state.SetNonce(msg.From, state.GetNonce(msg.From)+1)
+
+		// Invalid authorizations are skipped without failing the transaction
+		for i := range msg.SetCodeAuthorizations {
+			st.applyAuthorization(&msg.SetCodeAuthorizations[i])
+		}
+		// The delegation target of the recipient is warm
+		if target, ok := gethtypes.ParseDelegation(state.GetCode(*msg.To)); ok {
+			state.AddAddressToAccessList(target)
+		}
+
+		ret, meter, vmerr = evm.Call(msg.From, *msg.To, msg.Data, gas, st.value())
+	}
+
+	// Refunds are capped to a fraction of the gas used (EIP-3529)
+	gasUsed := msg.GasLimit - meter.GasRemaining()
+	refund := meter.GasRefunded() + st.refund
+	if limit := gasUsed / t.RefundQuotient; refund > limit {
+		refund = limit
+	}
+	gasUsed -= refund
+	evm.Tracer.captureGasChange(meter.GasRemaining(), msg.GasLimit-gasUsed, GasChangeTxRefunds)
+
+	// Return the unused gas to the sender
+	evm.Tracer.captureGasChange(msg.GasLimit-gasUsed, 0, GasChangeTxLeftOverReturned)
+	remaining := uint256.NewInt(msg.GasLimit - gasUsed)
+	state.AddBalance(msg.From, remaining.Mul(remaining, st.gasPrice))
+
+	// Pay the priority fee to the coinbase; the base fee is burnt
+	tip := new(uint256.Int).Set(st.gasPrice)
+	if baseFee := evm.Block.BaseFee; baseFee != nil {
+		tip.Sub(tip, baseFee)
+	}
+	if tip.Mul(tip, uint256.NewInt(gasUsed)); !tip.IsZero() {
+		state.AddBalance(evm.Block.Coinbase, tip)
+	}
+	// Self-destructed contracts are deleted and transient storage is cleared
+	state.Finalise()
+
+	return &ExecutionResult{
+		UsedGas:           gasUsed,
+		BlobGasUsed:       msg.blobGas(),
+		RefundedGas:       refund,
+		EffectiveGasPrice: st.gasPrice,
+		ReturnData:        ret,
+		ContractAddress:   contract,
+		Logs:              state.Logs(),
+		Err:               vmerr,
+		RevertReason:      evm.Errors.revertReason(ret, vmerr),
+	}, nil
+}
+
+// prepareAccessList resets the access list and warms the sender, the recipient,
+// the precompiled contracts, the coinbase (EIP-3651) and the entries of the
+// message access list (EIP-2930)
+func (st *stateTransition) prepareAccessList() {
+	state := st.evm.State
+	state.ResetAccessList()
+
+	state.AddAddressToAccessList(st.msg.From)
+	if st.msg.To != nil {
+		state.AddAddressToAccessList(*st.msg.To)
+	}
+	for _, address := range precompileAddresses {
+		state.AddAddressToAccessList(address)
+	}
+	state.AddAddressToAccessList(st.evm.Block.Coinbase)
+	for _, tuple := range st.msg.AccessList {
+		state.AddAddressToAccessList(tuple.Address)
+		for _, key := range tuple.StorageKeys {
+			state.AddSlotToAccessList(tuple.Address, key)
+		}
+	}
+}
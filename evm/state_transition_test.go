@@ -0,0 +1,219 @@
+package evm
+
+import (
+	"math"
+	"testing"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testSender   = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	testReceiver = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	testCoinbase = common.HexToAddress("0xc000000000000000000000000000000000000000")
+)
+
+// newTestEVM returns an EVM with a funded sender and a base fee of 10 wei
+func newTestEVM() *EVM {
+	state := t.NewStateDB()
+	state.SetBalance(testSender, uint256.NewInt(1_000_000_000))
+	return NewEVM(BlockContext{
+		Coinbase: testCoinbase,
+		GasLimit: 30_000_000,
+		BaseFee:  uint256.NewInt(10),
+	}, state)
+}
+
+func TestApplyMessage(test *testing.T) {
+	test.Run("Value transfer pays base fee and tip", func(test *testing.T) {
+		evm := newTestEVM()
+		result, err := ApplyMessage(evm, &Message{
+			From:      testSender,
+			To:        &testReceiver,
+			Value:     uint256.NewInt(1000),
+			GasLimit:  50_000,
+			GasFeeCap: uint256.NewInt(20),
+			GasTipCap: uint256.NewInt(2),
+		})
+		require.NoError(test, err)
+		assert.NoError(test, result.Err)
+		assert.Equal(test, t.TxGas, result.UsedGas)
+		assert.Equal(test, uint64(12), result.EffectiveGasPrice.Uint64())
+
+		// The sender pays value + gasUsed * effective price, the coinbase receives only the tip
+		assert.Equal(test, uint64(1_000_000_000-1000-21000*12), evm.State.GetBalance(testSender).Uint64())
+		assert.Equal(test, uint64(1000), evm.State.GetBalance(testReceiver).Uint64())
+		assert.Equal(test, uint64(21000*2), evm.State.GetBalance(testCoinbase).Uint64())
+		assert.Equal(test, uint64(1), evm.State.GetNonce(testSender))
+	})
+
+	test.Run("Contract creation deploys returned code", func(test *testing.T) {
+		evm := newTestEVM()
+		runtime := common.FromHex("0x6001600201")
+		initcode := common.FromHex("0x6460016002016000526005601bf3")
+		result, err := ApplyMessage(evm, &Message{
+			From:     testSender,
+			GasLimit: 100_000,
+			GasPrice: uint256.NewInt(10),
+			Data:     initcode,
+		})
+		require.NoError(test, err)
+		require.NoError(test, result.Err)
+
+		assert.Equal(test, crypto.CreateAddress(testSender, 0), result.ContractAddress)
+		assert.Equal(test, runtime, evm.State.GetCode(result.ContractAddress))
+		assert.Equal(test, uint64(1), evm.State.GetNonce(result.ContractAddress))
+		assert.Equal(test, uint64(1), evm.State.GetNonce(testSender))
+	})
+
+	test.Run("Revert keeps remaining gas and undoes state changes", func(test *testing.T) {
+		evm := newTestEVM()
+		// PUSH1 0x01 PUSH1 0x00 SSTORE PUSH1 0x00 PUSH1 0x00 REVERT
+		evm.State.SetCode(testReceiver, common.FromHex("0x600160005560006000fd"))
+		result, err := ApplyMessage(evm, &Message{
+			From:     testSender,
+			To:       &testReceiver,
+			GasLimit: 100_000,
+			GasPrice: uint256.NewInt(10),
+		})
+		require.NoError(test, err)
+		assert.ErrorIs(test, result.Err, ErrExecutionReverted)
+		assert.Equal(test, t.TxGas+3+3+t.GasColdSload+t.GasStorageSet+3+3+3, result.UsedGas)
+		assert.Equal(test, common.Hash{}, evm.State.GetState(testReceiver, common.Hash{}))
+		assert.Equal(test, uint64(1), evm.State.GetNonce(testSender))
+	})
+
+	test.Run("Calls into other contracts", func(test *testing.T) {
+		evm := newTestEVM()
+		callee := common.HexToAddress("0x3000000000000000000000000000000000000003")
+		// PUSH1 0x01 PUSH1 0x00 SSTORE STOP
+		evm.State.SetCode(callee, common.FromHex("0x600160005500"))
+		// CALL(0xffff, callee, 0, 0, 0, 0, 0) then STATICCALL(0xffff, callee, 0, 0, 0, 0)
+		// and store the success flag of the static call in slot 0
+		pushCallee := "73" + common.Bytes2Hex(callee.Bytes())
+		evm.State.SetCode(testReceiver, common.FromHex("0x60006000600060006000"+pushCallee+"61fffff1"+
+			"6000600060006000"+pushCallee+"61fffffa60005500"))
+
+		result, err := ApplyMessage(evm, &Message{
+			From:     testSender,
+			To:       &testReceiver,
+			GasLimit: 200_000,
+			GasPrice: uint256.NewInt(10),
+		})
+		require.NoError(test, err)
+		require.NoError(test, result.Err)
+
+		// The call wrote to the callee storage, the static call failed with write protection
+		assert.Equal(test, common.BigToHash(common.Big1), evm.State.GetState(callee, common.Hash{}))
+		assert.Equal(test, common.Hash{}, evm.State.GetState(testReceiver, common.Hash{}))
+	})
+
+	test.Run("Blob transaction exposes hashes and pays blob gas", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.Block.ExcessBlobGas = 2 * t.BlobTxBlobGaspriceUpdateFraction
+		blobFee := evm.Block.BlobBaseFee()
+
+		// Store BLOBHASH(1), BLOBHASH(5) and BLOBBASEFEE in slots 0, 1 and 2
+		evm.State.SetCode(testReceiver, common.FromHex("0x600149600055600549600155"+"4a60025500"))
+		hashes := []common.Hash{{0x01, 0xaa}, {0x01, 0xbb}}
+		result, err := ApplyMessage(evm, &Message{
+			From:          testSender,
+			To:            &testReceiver,
+			GasLimit:      100_000,
+			GasPrice:      uint256.NewInt(10),
+			BlobGasFeeCap: blobFee,
+			BlobHashes:    hashes,
+		})
+		require.NoError(test, err)
+		require.NoError(test, result.Err)
+
+		assert.Equal(test, 2*t.GasPerBlob, result.BlobGasUsed)
+		assert.Equal(test, hashes[1], evm.State.GetState(testReceiver, common.Hash{}))
+		assert.Equal(test, common.Hash{}, evm.State.GetState(testReceiver, common.BigToHash(common.Big1)))
+		assert.Equal(test, common.Hash(blobFee.Bytes32()), evm.State.GetState(testReceiver, common.BigToHash(common.Big2)))
+
+		paid := 1_000_000_000 - evm.State.GetBalance(testSender).Uint64()
+		assert.Equal(test, result.UsedGas*10+2*t.GasPerBlob*blobFee.Uint64(), paid)
+
+		_, err = ApplyMessage(evm, &Message{
+			From:          testSender,
+			Nonce:         1,
+			To:            &testReceiver,
+			GasLimit:      100_000,
+			GasPrice:      uint256.NewInt(10),
+			BlobGasFeeCap: new(uint256.Int).SubUint64(blobFee, 1),
+			BlobHashes:    hashes,
+		})
+		assert.ErrorIs(test, err, ErrBlobFeeCapTooLow)
+	})
+
+	test.Run("Journal only spans the current transaction", func(test *testing.T) {
+		evm := newTestEVM()
+		// LOG0 with no data, then STOP
+		evm.State.SetCode(testReceiver, common.FromHex("0x60006000a000"))
+		revision := evm.State.Snapshot()
+		send := func(nonce uint64) *ExecutionResult {
+			result, err := ApplyMessage(evm, &Message{
+				From:     testSender,
+				Nonce:    nonce,
+				To:       &testReceiver,
+				GasLimit: 100_000,
+				GasPrice: uint256.NewInt(10),
+			})
+			require.NoError(test, err)
+			require.NoError(test, result.Err)
+			return result
+		}
+		send(0)
+		assert.Len(test, send(1).Logs, 1)
+		assert.Equal(test, uint64(2), evm.State.Diff(revision).Post[testSender].Nonce)
+
+		// Revisions from before the transaction refer to committed changes
+		assert.NotPanics(test, func() { evm.State.RevertToSnapshot(revision) })
+		assert.Equal(test, uint64(2), evm.State.GetNonce(testSender))
+		assert.Len(test, evm.State.Logs(), 1)
+	})
+
+	test.Run("Invalid messages leave the state untouched", func(test *testing.T) {
+		evm := newTestEVM()
+		_, err := ApplyMessage(evm, &Message{From: testSender, To: &testReceiver, Nonce: 1, GasLimit: 21000, GasPrice: uint256.NewInt(10)})
+		assert.ErrorIs(test, err, ErrNonceTooHigh)
+
+		_, err = ApplyMessage(evm, &Message{From: testSender, To: &testReceiver, GasLimit: 20000, GasPrice: uint256.NewInt(10)})
+		assert.ErrorIs(test, err, ErrIntrinsicGas)
+
+		_, err = ApplyMessage(evm, &Message{From: testSender, To: &testReceiver, GasLimit: 21000, GasPrice: uint256.NewInt(5)})
+		assert.ErrorIs(test, err, ErrFeeCapTooLow)
+
+		_, err = ApplyMessage(evm, &Message{From: testSender, To: &testReceiver, GasLimit: 21000, GasPrice: uint256.NewInt(1_000_000)})
+		assert.ErrorIs(test, err, ErrInsufficientFunds)
+
+		assert.Equal(test, uint64(1_000_000_000), evm.State.GetBalance(testSender).Uint64())
+		assert.Equal(test, uint64(0), evm.State.GetNonce(testSender))
+	})
+}
+
+func TestCalcBlobFee(test *testing.T) {
+	assert.Equal(test, uint64(1), CalcBlobFee(0).Uint64())
+	// e**10 ~= 22026
+	assert.Equal(test, uint64(22026), CalcBlobFee(10*t.BlobTxBlobGaspriceUpdateFraction).Uint64())
+	// Fees that do not fit in 256 bits saturate
+	maxFee := new(uint256.Int).SetAllOne()
+	assert.Equal(test, maxFee, CalcBlobFee(math.MaxUint64))
+	assert.Equal(test, maxFee, CalcBlobFee(178*t.BlobTxBlobGaspriceUpdateFraction))
+	assert.True(test, CalcBlobFee(177*t.BlobTxBlobGaspriceUpdateFraction).Lt(maxFee))
+	assert.Equal(test, uint64(0), CalcExcessBlobGas(0, t.BlobTxTargetBlobGasPerBlock))
+	assert.Equal(test, t.GasPerBlob, CalcExcessBlobGas(0, t.BlobTxTargetBlobGasPerBlock+t.GasPerBlob))
+}
+
+func TestIntrinsicGas(test *testing.T) {
+	assert.Equal(test, t.TxGas, IntrinsicGas(nil, nil, nil, false))
+	assert.Equal(test, t.TxGas+t.TxDataZeroGas+t.TxDataNonZeroGas, IntrinsicGas([]byte{0x00, 0x01}, nil, nil, false))
+	// 33 bytes of initcode are 2 words
+	assert.Equal(test, t.TxGasContractCreation+33*t.TxDataZeroGas+2*t.InitCodeWordGas, IntrinsicGas(make([]byte, 33), nil, nil, true))
+}
@@ -0,0 +1,79 @@
+package evm
+
+import (
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// GasChangeReason explains why the gas available to a frame changed
+type GasChangeReason byte
+
+const (
+	GasChangeUnspecified GasChangeReason = iota
+	// GasChangeTxInitialBalance is the gas limit of the transaction, bought by the sender
+	GasChangeTxInitialBalance
+	// GasChangeTxIntrinsicGas is the intrinsic gas charged before execution
+	GasChangeTxIntrinsicGas
+	// GasChangeTxRefunds is the refund granted at the end of the transaction
+	GasChangeTxRefunds
+	// GasChangeTxLeftOverReturned is the unused gas returned to the sender
+	GasChangeTxLeftOverReturned
+	// GasChangeCallOpCode is the cost of an executed opcode
+	GasChangeCallOpCode
+	// GasChangeCallLeftOverReturned is the unused gas of a sub-call returned to its caller
+	GasChangeCallLeftOverReturned
+	// GasChangeCallFailedExecution is the remaining gas of a frame consumed by an exceptional halt
+	GasChangeCallFailedExecution
+	// GasChangeCallPrecompiledContract is the cost of running a precompiled contract
+	GasChangeCallPrecompiledContract
+)
+
+// Hooks are the callbacks invoked during execution, used to build tracers,
+// profilers and coverage tools on top of the interpreter. Nil hooks are skipped.
+type Hooks struct {
+	// OnTxStart is called before a message is executed
+	OnTxStart func(evm *EVM, msg *Message)
+	// OnTxEnd is called after a message is executed. err is set if the message
+	// was invalid, in which case result is nil.
+	OnTxEnd func(result *ExecutionResult, err error)
+	// OnEnter is called when a call frame starts. depth is 0 for the frame of the
+	// transaction and typ is the opcode that created the frame (CALL for the
+	// transaction, CREATE for contract creation).
+	OnEnter func(depth int, typ t.Opcode, from, to common.Address, input []byte, gas uint64, value *uint256.Int)
+	// OnExit is called when a call frame ends. reverted is set when the state
+	// changes of the frame were rolled back.
+	OnExit func(depth int, output []byte, gasUsed uint64, err error, reverted bool)
+	// OnOpcode is called before an opcode is executed, once its gas cost is known.
+	// err is set if the opcode cannot be executed (invalid opcode, out of gas).
+	OnOpcode func(pc uint64, op t.Opcode, gas, cost uint64, scope *ExecutionContext, depth int, err error)
+	// OnFault is called when the execution of an opcode fails
+	OnFault func(pc uint64, op t.Opcode, gas, cost uint64, scope *ExecutionContext, depth int, err error)
+	// OnGasChange is called when the gas available to a frame changes
+	OnGasChange func(old, new uint64, reason GasChangeReason)
+	// OnStorageChange is called when a storage slot is written
+	OnStorageChange func(address common.Address, slot, prev, new common.Hash)
+	// OnLog is called when a log is emitted
+	OnLog func(log *t.Log)
+}
+
+// captureBegin notifies the tracer that a call frame starts
+func (evm *EVM) captureBegin(typ t.Opcode, from, to common.Address, input []byte, gas uint64, value *uint256.Int) {
+	if evm.Tracer != nil && evm.Tracer.OnEnter != nil {
+		evm.Tracer.OnEnter(evm.depth, typ, from, to, input, gas, value)
+	}
+}
+
+// captureEnd notifies the tracer that a call frame ended
+func (evm *EVM) captureEnd(startGas uint64, meter *t.GasMeter, ret []byte, err error) {
+	if evm.Tracer != nil && evm.Tracer.OnExit != nil {
+		evm.Tracer.OnExit(evm.depth, ret, startGas-meter.GasRemaining(), err, err != nil)
+	}
+}
+
+// captureGasChange notifies the tracer that the gas available to a frame changed
+func (hooks *Hooks) captureGasChange(old, new uint64, reason GasChangeReason) {
+	if hooks != nil && hooks.OnGasChange != nil && old != new {
+		hooks.OnGasChange(old, new, reason)
+	}
+}
@@ -0,0 +1,108 @@
+package evm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooks(test *testing.T) {
+	evm := newTestEVM()
+	callee := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	// SSTORE(0, 1) LOG0(0, 0) STOP
+	evm.State.SetCode(callee, common.FromHex("0x600160005560006000a000"))
+	// CALL(0xffff, callee, 0, 0, 0, 0, 0) then INVALID
+	evm.State.SetCode(testReceiver, common.FromHex("0x60006000600060006000"+"73"+common.Bytes2Hex(callee.Bytes())+"61fffff1fe"))
+
+	var events []string
+	opcodes := make(map[int]int)
+	var gasOut uint64
+	evm.Tracer = &Hooks{
+		OnTxStart: func(evm *EVM, msg *Message) { events = append(events, "tx start") },
+		OnTxEnd:   func(result *ExecutionResult, err error) { events = append(events, "tx end") },
+		OnEnter: func(depth int, typ t.Opcode, from, to common.Address, input []byte, gas uint64, value *uint256.Int) {
+			events = append(events, fmt.Sprintf("enter %d %s", depth, GetOpcodeName(byte(typ))))
+		},
+		OnExit: func(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+			events = append(events, fmt.Sprintf("exit %d %v", depth, reverted))
+		},
+		OnOpcode: func(pc uint64, op t.Opcode, gas, cost uint64, scope *ExecutionContext, depth int, err error) {
+			opcodes[depth]++
+		},
+		OnFault: func(pc uint64, op t.Opcode, gas, cost uint64, scope *ExecutionContext, depth int, err error) {
+			events = append(events, "fault")
+		},
+		OnStorageChange: func(address common.Address, slot, prev, new common.Hash) {
+			events = append(events, fmt.Sprintf("sstore %s %s", address.Hex(), new.Big()))
+		},
+		OnLog: func(log *t.Log) { events = append(events, "log") },
+		OnGasChange: func(old, new uint64, reason GasChangeReason) {
+			if reason == GasChangeTxLeftOverReturned {
+				gasOut = old
+			}
+		},
+	}
+
+	result, err := ApplyMessage(evm, &Message{
+		From:     testSender,
+		To:       &testReceiver,
+		GasLimit: 200_000,
+		GasPrice: uint256.NewInt(10),
+	})
+	require.NoError(test, err)
+	assert.ErrorIs(test, result.Err, ErrInvalidOpcode)
+
+	assert.Equal(test, []string{
+		"tx start",
+		"enter 0 CALL",
+		"enter 1 CALL",
+		"sstore " + callee.Hex() + " 1",
+		"log",
+		"exit 1 false",
+		"exit 0 true",
+		"tx end",
+	}, events)
+	// The caller runs 9 opcodes including INVALID, the callee 7
+	assert.Equal(test, map[int]int{1: 9, 2: 7}, opcodes)
+	assert.Equal(test, uint64(0), gasOut)
+}
+
+func TestJSONLoggerNestedCalls(test *testing.T) {
+	evm := newTestEVM()
+	callee := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	evm.State.SetCode(callee, common.FromHex("0x600100"))
+	evm.State.SetCode(testReceiver, common.FromHex("0x60006000600060006000"+"73"+common.Bytes2Hex(callee.Bytes())+"61fffff100"))
+
+	var out bytes.Buffer
+	evm.Tracer = NewJSONLogger(&out).Hooks()
+	result, err := ApplyMessage(evm, &Message{
+		From:     testSender,
+		To:       &testReceiver,
+		GasLimit: 200_000,
+		GasPrice: uint256.NewInt(10),
+	})
+	require.NoError(test, err)
+	require.NoError(test, result.Err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(test, lines, 9+2+1)
+	depths := make([]int, 0, len(lines)-1)
+	for _, line := range lines[:len(lines)-1] {
+		var log StructLog
+		require.NoError(test, json.Unmarshal([]byte(line), &log))
+		depths = append(depths, log.Depth)
+	}
+	assert.Equal(test, []int{1, 1, 1, 1, 1, 1, 1, 1, 2, 2, 1}, depths)
+
+	var summary TraceSummary
+	require.NoError(test, json.Unmarshal([]byte(lines[len(lines)-1]), &summary))
+	assert.Empty(test, summary.Error)
+}
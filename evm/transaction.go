@@ -0,0 +1,103 @@
+package evm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+)
+
+// Errors returned when decoding or converting signed transactions
+var (
+	ErrTxTypeNotSupported = errors.New("transaction type not supported")
+	ErrInvalidSender      = errors.New("invalid transaction sender")
+	ErrFieldOverflow      = errors.New("transaction field higher than 2^256-1")
+)
+
+// DecodeTransaction decodes a raw signed transaction. Both legacy RLP transactions
+// and EIP-2718 typed envelopes (access list, dynamic fee, blob and set-code) are accepted.
+func DecodeTransaction(raw []byte) (*gethtypes.Transaction, error) {
+	tx := new(gethtypes.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("decoding transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// Signer returns the transaction signer for the chain of the given block. It accepts
+// every transaction type and rejects transactions signed for another chain ID
+// (EIP-155 replay protection). Unprotected legacy transactions are still accepted.
+func (block *BlockContext) Signer() gethtypes.Signer {
+	return gethtypes.LatestSignerForChainID(new(big.Int).SetUint64(block.ChainID))
+}
+
+// TransactionToMessage verifies the signature of a transaction, recovers its sender
+// and converts it into a Message ready to be applied
+func TransactionToMessage(tx *gethtypes.Transaction, signer gethtypes.Signer) (*Message, error) {
+	switch tx.Type() {
+	case gethtypes.LegacyTxType, gethtypes.AccessListTxType, gethtypes.DynamicFeeTxType, gethtypes.BlobTxType, gethtypes.SetCodeTxType:
+	default:
+		return nil, fmt.Errorf("%w: type %d", ErrTxTypeNotSupported, tx.Type())
+	}
+
+	from, err := gethtypes.Sender(signer, tx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSender, err)
+	}
+
+	msg := &Message{
+		From:       from,
+		To:         tx.To(),
+		Nonce:      tx.Nonce(),
+		GasLimit:   tx.Gas(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}
+	if msg.Value, err = toUint256("value", tx.Value()); err != nil {
+		return nil, err
+	}
+	if tx.Type() == gethtypes.LegacyTxType || tx.Type() == gethtypes.AccessListTxType {
+		if msg.GasPrice, err = toUint256("gas price", tx.GasPrice()); err != nil {
+			return nil, err
+		}
+	} else {
+		if msg.GasFeeCap, err = toUint256("max fee per gas", tx.GasFeeCap()); err != nil {
+			return nil, err
+		}
+		if msg.GasTipCap, err = toUint256("max priority fee per gas", tx.GasTipCap()); err != nil {
+			return nil, err
+		}
+	}
+	if tx.Type() == gethtypes.BlobTxType {
+		if msg.BlobGasFeeCap, err = toUint256("max fee per blob gas", tx.BlobGasFeeCap()); err != nil {
+			return nil, err
+		}
+		msg.BlobHashes = tx.BlobHashes()
+	}
+	if tx.Type() == gethtypes.SetCodeTxType {
+		msg.SetCodeAuthorizations = tx.SetCodeAuthorizations()
+	}
+	return msg, nil
+}
+
+// toUint256 converts an amount of a decoded transaction. The RLP encoding of legacy
+// and dynamic fee transactions does not bound their amounts to 256 bits.
+func toUint256(field string, value *big.Int) (*uint256.Int, error) {
+	converted, overflow := uint256.FromBig(value)
+	if overflow {
+		return nil, fmt.Errorf("%w: %s %v", ErrFieldOverflow, field, value)
+	}
+	return converted, nil
+}
+
+// ApplyTransaction recovers the sender of a signed transaction and applies it
+// against the state of the EVM
+func ApplyTransaction(evm *EVM, tx *gethtypes.Transaction) (*ExecutionResult, error) {
+	msg, err := TransactionToMessage(tx, evm.Block.Signer())
+	if err != nil {
+		return nil, err
+	}
+	return ApplyMessage(evm, msg)
+}
@@ -0,0 +1,133 @@
+package evm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyTransaction(test *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+
+	signAndEncode := func(chainID uint64, inner gethtypes.TxData) []byte {
+		signer := gethtypes.LatestSignerForChainID(new(big.Int).SetUint64(chainID))
+		tx, err := gethtypes.SignNewTx(key, signer, inner)
+		require.NoError(test, err)
+		raw, err := tx.MarshalBinary()
+		require.NoError(test, err)
+		return raw
+	}
+
+	test.Run("Dynamic fee transaction", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.Block.ChainID = 1
+		evm.State.SetBalance(sender, uint256.NewInt(1_000_000_000))
+
+		raw := signAndEncode(1, &gethtypes.DynamicFeeTx{
+			ChainID:   big.NewInt(1),
+			Gas:       21000,
+			GasFeeCap: big.NewInt(20),
+			GasTipCap: big.NewInt(1),
+			To:        &testReceiver,
+			Value:     big.NewInt(5),
+		})
+		tx, err := DecodeTransaction(raw)
+		require.NoError(test, err)
+		assert.Equal(test, uint8(gethtypes.DynamicFeeTxType), tx.Type())
+
+		msg, err := TransactionToMessage(tx, evm.Block.Signer())
+		require.NoError(test, err)
+		assert.Equal(test, sender, msg.From)
+
+		result, err := ApplyTransaction(evm, tx)
+		require.NoError(test, err)
+		assert.NoError(test, result.Err)
+		assert.Equal(test, uint64(5), evm.State.GetBalance(testReceiver).Uint64())
+	})
+
+	test.Run("Transaction signed for another chain is rejected", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.Block.ChainID = 1
+
+		raw := signAndEncode(5, &gethtypes.LegacyTx{
+			Gas:      21000,
+			GasPrice: big.NewInt(10),
+			To:       &testReceiver,
+		})
+		tx, err := DecodeTransaction(raw)
+		require.NoError(test, err)
+
+		_, err = ApplyTransaction(evm, tx)
+		assert.ErrorIs(test, err, ErrInvalidSender)
+	})
+
+	test.Run("Set-code transaction delegates the authority", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.Block.ChainID = 1
+		evm.State.SetBalance(sender, uint256.NewInt(1_000_000_000))
+
+		// The delegation target stores 1 in slot 0 of the account it runs for
+		target := common.HexToAddress("0x7702000000000000000000000000000000007702")
+		evm.State.SetCode(target, common.FromHex("0x600160005500"))
+
+		authorityKey, _ := crypto.GenerateKey()
+		authority := crypto.PubkeyToAddress(authorityKey.PublicKey)
+		auth, err := gethtypes.SignSetCode(authorityKey, gethtypes.SetCodeAuthorization{
+			ChainID: *uint256.NewInt(1),
+			Address: target,
+		})
+		require.NoError(test, err)
+
+		raw := signAndEncode(1, &gethtypes.SetCodeTx{
+			ChainID:   uint256.NewInt(1),
+			Nonce:     0,
+			Gas:       100_000,
+			GasFeeCap: uint256.NewInt(20),
+			GasTipCap: uint256.NewInt(1),
+			To:        authority,
+			AuthList:  []gethtypes.SetCodeAuthorization{auth},
+		})
+		tx, err := DecodeTransaction(raw)
+		require.NoError(test, err)
+
+		result, err := ApplyTransaction(evm, tx)
+		require.NoError(test, err)
+		require.NoError(test, result.Err)
+
+		assert.Equal(test, gethtypes.AddressToDelegation(target), evm.State.GetCode(authority))
+		assert.Equal(test, uint64(1), evm.State.GetNonce(authority))
+		assert.Equal(test, common.BigToHash(big.NewInt(1)), evm.State.GetState(authority, common.Hash{}))
+		assert.Equal(test, common.Hash{}, evm.State.GetState(target, common.Hash{}))
+	})
+
+	test.Run("Amounts above 2^256-1 are rejected", func(test *testing.T) {
+		evm := newTestEVM()
+		evm.Block.ChainID = 1
+		huge := new(big.Int).Lsh(big.NewInt(1), 256)
+
+		for _, inner := range []gethtypes.TxData{
+			&gethtypes.LegacyTx{Gas: 21000, GasPrice: big.NewInt(10), To: &testReceiver, Value: huge},
+			&gethtypes.LegacyTx{Gas: 21000, GasPrice: huge, To: &testReceiver},
+			&gethtypes.DynamicFeeTx{ChainID: big.NewInt(1), Gas: 21000, GasFeeCap: huge, GasTipCap: big.NewInt(1), To: &testReceiver},
+			&gethtypes.DynamicFeeTx{ChainID: big.NewInt(1), Gas: 21000, GasFeeCap: big.NewInt(20), GasTipCap: huge, To: &testReceiver},
+		} {
+			tx, err := DecodeTransaction(signAndEncode(1, inner))
+			require.NoError(test, err)
+
+			_, err = ApplyTransaction(evm, tx)
+			assert.ErrorIs(test, err, ErrFieldOverflow)
+		}
+	})
+
+	test.Run("Malformed transaction", func(test *testing.T) {
+		_, err := DecodeTransaction(common.FromHex("0x02c0"))
+		assert.Error(test, err)
+	})
+}
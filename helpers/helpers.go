@@ -2,30 +2,84 @@ package helpers
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
 
+	"github.com/Manuelshub/go-EVM/assembler"
 	"github.com/Manuelshub/go-EVM/evm"
+	t "github.com/Manuelshub/go-EVM/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/holiman/uint256"
 )
 
+// Defaults of the local chain used by the CLI
+const (
+	LocalChainID  uint64 = 1
+	LocalGasLimit uint64 = 30000000
+)
+
+// LocalCaller is the sender of the calls made with the call command
+var LocalCaller = common.HexToAddress("0x00000000000000000000000000000000000ca11e")
+
+// NewLocalEVM creates the EVM holding the local world state the CLI executes transactions against
+func NewLocalEVM() *evm.EVM {
+	return evm.NewEVM(evm.BlockContext{
+		ChainID:  LocalChainID,
+		GasLimit: LocalGasLimit,
+	}, t.NewStateDB())
+}
+
 // PrintHelp prints the help message for the CLI when `help` is entered
 func PrintHelp() {
 	fmt.Println("Available commands:")
 	fmt.Println("  help               - Show this help")
 	fmt.Println("  run <bytecode>     - Execute bytecode (hex format)")
 	fmt.Println("  debug <bytecode>   - Execute bytecode with step-by-step tracing")
+	fmt.Println("  trace <bytecode>   - Execute bytecode and print an EIP-3155 JSON trace")
+	fmt.Println("  disasm <hex|file>  - Disassemble bytecode given in hex or read from a file")
+	fmt.Println("  cfg <hex|file> [dot|json] [file] - Write the control flow graph of bytecode,")
+	fmt.Println("                       as DOT by default, to a file if given")
+	fmt.Println("  asm <source|file>  - Assemble and execute assembly, statements separated by ';'")
+	fmt.Println("  errors <file>      - Load custom errors to decode revert reasons from an ABI,")
+	fmt.Println("                       a compiler artifact or a 4-byte signature database (JSON)")
+	fmt.Println("  abi <file>         - Load a contract ABI (JSON or compiler artifact) to encode calls")
+	fmt.Println("                       and decode return values, events and errors")
+	fmt.Println("  call <address> <signature|method> [args...] - Call a contract with ABI encoded")
+	fmt.Println("                       arguments, e.g. call 0x.. \"transfer(address,uint256)\" 0x.. 100")
+	fmt.Println("  deploy <artifact[:Contract]> [args...] [--lib <library>=<address>...] - Deploy a")
+	fmt.Println("                       contract from a solc standard-JSON, Foundry or Hardhat artifact")
+	fmt.Println("  cover <bytecode>   - Execute bytecode and record its coverage")
+	fmt.Println("  coverage [annotate] - Display the coverage recorded so far, with the annotated disassembly")
+	fmt.Println("  coverage lcov <code_hash> <source_map_file> <out_file> <sources...>")
+	fmt.Println("                     - Write the coverage of a code as lcov using a solc source map")
 	fmt.Println("  stack              - Display current stack")
 	fmt.Println("  storage <key>      - Display storage value at key (hex format)")
 	fmt.Println("  push <value>       - Push a hex value onto the stack")
+	fmt.Println("  tx <raw_tx> [tracer] - Decode a signed transaction (hex format) and execute it,")
+	fmt.Println("                       optionally traced with json (EIP-3155), call, call-logs or profile")
+	fmt.Println("  profile <bytecode> [file] - Execute bytecode and print its gas profile,")
+	fmt.Println("                       writing folded stacks for flamegraphs to file if given")
+	fmt.Println("  diff [json]        - Display the state changes of the last transaction")
+	fmt.Println("  root               - Display the state root of the world state")
+	fmt.Println("  proof <address> [slots...] - Display the Merkle proof of an account and storage slots (JSON)")
+	fmt.Println("  state save <file>  - Save the world state to a file")
+	fmt.Println("  state load <file>  - Load the world state from a file")
+	fmt.Println("  state dump [file]  - Dump the world state as genesis alloc JSON, to a file if given")
 	fmt.Println("  reset              - Reset the execution context")
 	fmt.Println("  exit, quit         - Exit the program")
 }
 
-// RunBytecode executes the given bytecode and prints the result
-func RunBytecode(ctx *evm.ExecutionContext, hexString string) {
+// RunBytecode executes the given bytecode and prints the result, decoding the
+// revert reason with decoder
+func RunBytecode(ctx *evm.ExecutionContext, decoder *evm.ErrorDecoder, hexString string) {
 	if strings.HasPrefix(hexString, "0x") {
 		hexString = hexString[2:]
 	}
@@ -40,6 +94,7 @@ func RunBytecode(ctx *evm.ExecutionContext, hexString string) {
 	result, err := ctx.Run(bytecode)
 	if err != nil {
 		fmt.Printf("Execution failed: %v\n", err)
+		printRevertReason(decoder, result)
 	} else if result != nil {
 		fmt.Printf("Execution successful. Result: 0x%s\n", hex.EncodeToString(result))
 	} else {
@@ -53,6 +108,239 @@ func RunBytecode(ctx *evm.ExecutionContext, hexString string) {
 	}
 }
 
+// Disassemble prints the instructions of bytecode given in hex or read from a
+// file holding either hex text or the raw code
+func Disassemble(source string) {
+	bytecode, err := readBytecode(source)
+	if err != nil {
+		fmt.Printf("Error decoding bytecode: %v\n", err)
+		return
+	}
+	evm.Disassemble(bytecode).Write(os.Stdout)
+}
+
+// WriteCFG builds the control flow graph of bytecode given in hex or read from
+// a file, and writes it as DOT or JSON to outPath, or to stdout if it is empty
+func WriteCFG(source string, format string, outPath string) {
+	bytecode, err := readBytecode(source)
+	if err != nil {
+		fmt.Printf("Error decoding bytecode: %v\n", err)
+		return
+	}
+	cfg := evm.BuildCFG(bytecode)
+
+	var out io.Writer = os.Stdout
+	if outPath != "" {
+		file, err := os.Create(outPath)
+		if err != nil {
+			fmt.Printf("Error writing graph: %v\n", err)
+			return
+		}
+		defer file.Close()
+		out = file
+	}
+
+	switch format {
+	case "dot":
+		err = cfg.WriteDot(out)
+	case "json":
+		err = cfg.WriteJSON(out)
+	default:
+		fmt.Printf("Unknown format %q. Usage: cfg <hex|file> [dot|json] [out_file]\n", format)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error writing graph: %v\n", err)
+		return
+	}
+	if outPath != "" {
+		fmt.Printf("Control flow graph written to %s (%d blocks, %d edges)\n", outPath, len(cfg.Blocks), len(cfg.Edges))
+	}
+}
+
+// readBytecode decodes hex bytecode, or reads it from the file at source if it exists
+func readBytecode(source string) ([]byte, error) {
+	data, err := os.ReadFile(source)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		data = []byte(source)
+	}
+
+	text := strings.TrimPrefix(strings.TrimSpace(string(data)), "0x")
+	bytecode, hexErr := hex.DecodeString(text)
+	if hexErr == nil {
+		return bytecode, nil
+	}
+	if err == nil {
+		// The file holds the raw code
+		return data, nil
+	}
+	return nil, hexErr
+}
+
+// AssembleAndRun assembles the source, given inline or read from a file, and
+// executes the resulting bytecode
+func AssembleAndRun(ctx *evm.ExecutionContext, decoder *evm.ErrorDecoder, source string) {
+	if data, err := os.ReadFile(source); err == nil {
+		source = string(data)
+	}
+
+	bytecode, err := assembler.Assemble(source)
+	if err != nil {
+		fmt.Printf("Error assembling: %v\n", err)
+		return
+	}
+	RunBytecode(ctx, decoder, hex.EncodeToString(bytecode))
+}
+
+// TraceBytecode executes bytecode and prints one EIP-3155 JSON line per executed
+// opcode, followed by the summary line
+func TraceBytecode(ctx *evm.ExecutionContext, decoder *evm.ErrorDecoder, hexString string) {
+	if strings.HasPrefix(hexString, "0x") {
+		hexString = hexString[2:]
+	}
+
+	bytecode, err := hex.DecodeString(hexString)
+	if err != nil {
+		fmt.Printf("Error decoding bytecode: %v\n", err)
+		return
+	}
+
+	if output, err := evm.NewJSONLogger(os.Stdout).Trace(ctx, bytecode); err != nil {
+		printRevertReason(decoder, output)
+	}
+}
+
+// ProfileBytecode executes bytecode and prints the gas spent by opcode and by
+// program counter. The folded stacks are written to foldedPath if it is set.
+func ProfileBytecode(ctx *evm.ExecutionContext, hexString string, foldedPath string) {
+	if strings.HasPrefix(hexString, "0x") {
+		hexString = hexString[2:]
+	}
+
+	bytecode, err := hex.DecodeString(hexString)
+	if err != nil {
+		fmt.Printf("Error decoding bytecode: %v\n", err)
+		return
+	}
+
+	profiler := evm.NewGasProfiler()
+	ctx.Tracer = profiler.Hooks()
+	defer func() { ctx.Tracer = nil }()
+
+	if _, err := ctx.Run(bytecode); err != nil {
+		fmt.Printf("Execution failed: %v\n", err)
+	}
+	printProfile(profiler)
+
+	if foldedPath != "" {
+		file, err := os.Create(foldedPath)
+		if err != nil {
+			fmt.Printf("Error writing folded stacks: %v\n", err)
+			return
+		}
+		defer file.Close()
+		if err := profiler.WriteFoldedStacks(file); err != nil {
+			fmt.Printf("Error writing folded stacks: %v\n", err)
+			return
+		}
+		fmt.Printf("Folded stacks written to %s\n", foldedPath)
+	}
+}
+
+// printProfile prints the gas profile tables and the folded stacks
+func printProfile(profiler *evm.GasProfiler) {
+	fmt.Printf("\nGas by opcode (total %d):\n", profiler.TotalGas())
+	profiler.WriteOpcodeTable(os.Stdout)
+	fmt.Println("\nGas by program counter:")
+	profiler.WritePCTable(os.Stdout)
+	fmt.Println("\nFolded stacks:")
+	profiler.WriteFoldedStacks(os.Stdout)
+}
+
+// CoverBytecode executes bytecode and records its coverage in the collector
+func CoverBytecode(ctx *evm.ExecutionContext, collector *evm.CoverageCollector, hexString string) {
+	if strings.HasPrefix(hexString, "0x") {
+		hexString = hexString[2:]
+	}
+
+	bytecode, err := hex.DecodeString(hexString)
+	if err != nil {
+		fmt.Printf("Error decoding bytecode: %v\n", err)
+		return
+	}
+
+	ctx.Tracer = collector.Hooks()
+	defer func() { ctx.Tracer = nil }()
+
+	if _, err := ctx.Run(bytecode); err != nil {
+		fmt.Printf("Execution failed: %v\n", err)
+	}
+	collector.WriteReport(os.Stdout)
+}
+
+// PrintCoverage prints the coverage recorded so far, followed by the annotated
+// disassembly of every code if annotate is set
+func PrintCoverage(collector *evm.CoverageCollector, annotate bool) {
+	if len(collector.Codes()) == 0 {
+		fmt.Println("No coverage recorded yet")
+		return
+	}
+	collector.WriteReport(os.Stdout)
+	if !annotate {
+		return
+	}
+	for _, coverage := range collector.Codes() {
+		fmt.Printf("\n%s:\n", coverage.Hash.Hex())
+		coverage.WriteAnnotated(os.Stdout)
+	}
+}
+
+// WriteLcov writes the coverage of a code as lcov, mapping its instructions to
+// the source files with a solc source map
+func WriteLcov(collector *evm.CoverageCollector, codeHash, sourceMapPath, outPath string, sourcePaths []string) {
+	coverage := collector.Code(common.HexToHash(codeHash))
+	if coverage == nil {
+		fmt.Printf("Error: no coverage recorded for code %s\n", codeHash)
+		return
+	}
+
+	data, err := os.ReadFile(sourceMapPath)
+	if err != nil {
+		fmt.Printf("Error reading source map: %v\n", err)
+		return
+	}
+	sourceMap, err := evm.ParseSourceMap(string(data))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	sources := make([]evm.SourceFile, 0, len(sourcePaths))
+	for _, path := range sourcePaths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Error reading source: %v\n", err)
+			return
+		}
+		sources = append(sources, evm.SourceFile{Path: path, Content: content})
+	}
+
+	file, err := os.Create(outPath)
+	if err != nil {
+		fmt.Printf("Error writing lcov: %v\n", err)
+		return
+	}
+	defer file.Close()
+	if err := coverage.WriteLcov(file, sourceMap, sources); err != nil {
+		fmt.Printf("Error writing lcov: %v\n", err)
+		return
+	}
+	fmt.Printf("Coverage written to %s\n", outPath)
+}
+
 func PushValue(ctx *evm.ExecutionContext, hexValue string) {
 	if strings.HasPrefix(hexValue, "0x") {
 		hexValue = hexValue[2:]
@@ -85,7 +373,9 @@ func PushValue(ctx *evm.ExecutionContext, hexValue string) {
 	fmt.Printf("Stack: %s\n", ctx.Stack.ToString())
 }
 
-func DebugBytecode(ctx *evm.ExecutionContext, hexString string) {
+// DebugBytecode executes bytecode one instruction at a time, waiting for Enter
+// between the steps
+func DebugBytecode(ctx *evm.ExecutionContext, decoder *evm.ErrorDecoder, hexString string) {
 	// Remove 0x prefix from string if present
 	if strings.HasPrefix(hexString, "0x") {
 		hexString = hexString[2:]
@@ -130,6 +420,9 @@ func DebugBytecode(ctx *evm.ExecutionContext, hexString string) {
 		err := evm.ExecuteStep(ctx)
 		if err != nil {
 			fmt.Printf("Execution failed: %v\n", err)
+			if errors.Is(err, evm.ErrExecutionReverted) {
+				printRevertReason(decoder, ctx.ReturnData)
+			}
 			break
 		}
 
@@ -148,3 +441,446 @@ func DebugBytecode(ctx *evm.ExecutionContext, hexString string) {
 	fmt.Printf("\nFinal memory: %s\n", ctx.Memory.ToString())
 	fmt.Printf("\nGas used: %d\n", ctx.GasMeter.GasConsumed())
 }
+
+// RunTransaction decodes a raw signed transaction, executes it against the local
+// state and prints the result
+func RunTransaction(chain *evm.EVM, hexString string, tracer string) *t.StateDiff {
+	if strings.HasPrefix(hexString, "0x") {
+		hexString = hexString[2:]
+	}
+
+	raw, err := hex.DecodeString(hexString)
+	if err != nil {
+		fmt.Printf("Error decoding transaction: %v\n", err)
+		return nil
+	}
+
+	tx, err := evm.DecodeTransaction(raw)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return nil
+	}
+
+	msg, err := evm.TransactionToMessage(tx, chain.Block.Signer())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return nil
+	}
+
+	fmt.Printf("Transaction: %s (type %d)\n", tx.Hash().Hex(), tx.Type())
+	fmt.Printf("From: %s\n", msg.From.Hex())
+	if msg.To != nil {
+		fmt.Printf("To: %s\n", msg.To.Hex())
+	} else {
+		fmt.Println("To: contract creation")
+	}
+
+	// The call tracer prints its tree once the transaction is done
+	var (
+		callTracer *evm.CallTracer
+		profiler   *evm.GasProfiler
+	)
+	switch tracer {
+	case "":
+	case "json":
+		chain.Tracer = evm.NewJSONLogger(os.Stdout).Hooks()
+	case "call", "call-logs":
+		callTracer = evm.NewCallTracer(tracer == "call-logs")
+		chain.Tracer = callTracer.Hooks()
+	case "profile":
+		profiler = evm.NewGasProfiler()
+		chain.Tracer = profiler.Hooks()
+	default:
+		fmt.Printf("Error: unknown tracer %s (expected json, call, call-logs or profile)\n", tracer)
+		return nil
+	}
+	defer func() { chain.Tracer = nil }()
+
+	revision := chain.State.Snapshot()
+	result, err := evm.ApplyMessage(chain, msg)
+	if err != nil {
+		fmt.Printf("Transaction rejected: %v\n", err)
+		return nil
+	}
+
+	if result.Failed() {
+		fmt.Printf("Execution failed: %v\n", result.Err)
+		if result.RevertReason != nil {
+			fmt.Printf("Revert reason: %s\n", result.RevertReason)
+		}
+	} else {
+		fmt.Println("Execution successful.")
+	}
+	if msg.To == nil && !result.Failed() {
+		fmt.Printf("Contract address: %s\n", result.ContractAddress.Hex())
+	}
+	if len(result.ReturnData) > 0 {
+		fmt.Printf("Return data: 0x%s\n", hex.EncodeToString(result.ReturnData))
+	}
+	fmt.Printf("Gas used: %d\n", result.UsedGas)
+	fmt.Printf("State root: %s\n", chain.State.IntermediateRoot().Hex())
+	if callTracer != nil {
+		encoded, _ := json.MarshalIndent(callTracer.Result(), "", "  ")
+		fmt.Println(string(encoded))
+	}
+	if profiler != nil {
+		printProfile(profiler)
+	}
+	return chain.State.Diff(revision)
+}
+
+// PrintProof prints the Merkle proof of an account and some of its storage slots
+// in the eth_getProof JSON format
+func PrintProof(chain *evm.EVM, address string, slots []string) {
+	if !common.IsHexAddress(address) {
+		fmt.Printf("Error: invalid address %s\n", address)
+		return
+	}
+
+	keys := make([]common.Hash, 0, len(slots))
+	for _, slot := range slots {
+		keys = append(keys, common.HexToHash(slot))
+	}
+
+	proof, err := chain.State.GetProof(common.HexToAddress(address), keys)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	encoded, _ := json.MarshalIndent(proof, "", "  ")
+	fmt.Println(string(encoded))
+}
+
+// SaveState writes the world state of the chain to a file
+func SaveState(chain *evm.EVM, path string) {
+	if err := chain.State.Save(t.NewFileBackend(path)); err != nil {
+		fmt.Printf("Error saving state: %v\n", err)
+		return
+	}
+	fmt.Printf("State saved to %s\n", path)
+}
+
+// LoadState replaces the world state of the chain with the one saved in a file
+func LoadState(chain *evm.EVM, path string) {
+	state, err := t.LoadStateDB(t.NewFileBackend(path))
+	if err != nil {
+		fmt.Printf("Error loading state: %v\n", err)
+		return
+	}
+	chain.State = state
+	fmt.Printf("State loaded from %s\n", path)
+	fmt.Printf("State root: %s\n", state.IntermediateRoot().Hex())
+}
+
+// LoadPrestate replaces the world state of the chain with the accounts of a
+// genesis alloc / prestate JSON file
+func LoadPrestate(chain *evm.EVM, path string) error {
+	alloc, err := t.ReadAlloc(path)
+	if err != nil {
+		return err
+	}
+	state, err := t.LoadAlloc(alloc)
+	if err != nil {
+		return err
+	}
+	chain.State = state
+	return nil
+}
+
+// DumpState prints the world state of the chain in the genesis alloc JSON format,
+// or writes it to a file if a path is given
+func DumpState(chain *evm.EVM, path string) {
+	if path != "" {
+		if err := chain.State.WriteAlloc(path); err != nil {
+			fmt.Printf("Error dumping state: %v\n", err)
+			return
+		}
+		fmt.Printf("State dumped to %s\n", path)
+		return
+	}
+	encoded, _ := json.MarshalIndent(chain.State.DumpAlloc(), "", "  ")
+	fmt.Println(string(encoded))
+}
+
+// PrintStateDiff prints the accounts modified by a transaction with their values
+// before and after, or the prestate tracer diff JSON if asJSON is set
+func PrintStateDiff(diff *t.StateDiff, asJSON bool) {
+	if asJSON {
+		encoded, _ := json.MarshalIndent(diff, "", "  ")
+		fmt.Println(string(encoded))
+		return
+	}
+
+	addresses := make([]common.Address, 0, len(diff.Post))
+	for address := range diff.Post {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i].Bytes(), addresses[j].Bytes()) < 0
+	})
+	if len(addresses) == 0 {
+		fmt.Println("No state changes")
+		return
+	}
+
+	for _, address := range addresses {
+		pre, post := diff.Pre[address], diff.Post[address]
+		if pre == nil {
+			fmt.Printf("%s (created)\n", address.Hex())
+			pre = &t.DiffAccount{}
+		} else {
+			fmt.Printf("%s\n", address.Hex())
+		}
+
+		if post.Balance != nil {
+			fmt.Printf("  balance: %s -> %s\n", pre.Balance.ToInt(), post.Balance.ToInt())
+		}
+		if post.Nonce != 0 {
+			fmt.Printf("  nonce: %d -> %d\n", pre.Nonce, post.Nonce)
+		}
+		if post.Code != nil {
+			fmt.Printf("  code: %d bytes -> %d bytes\n", len(pre.Code), len(post.Code))
+		}
+
+		slots := make(map[common.Hash]struct{})
+		for key := range pre.Storage {
+			slots[key] = struct{}{}
+		}
+		for key := range post.Storage {
+			slots[key] = struct{}{}
+		}
+		keys := make([]common.Hash, 0, len(slots))
+		for key := range slots {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return bytes.Compare(keys[i].Bytes(), keys[j].Bytes()) < 0
+		})
+		for _, key := range keys {
+			fmt.Printf("  storage %s: %s -> %s\n", key.Hex(), pre.Storage[key].Hex(), post.Storage[key].Hex())
+		}
+	}
+}
+
+// printRevertReason prints the revert reason of revert data decoded with decoder
+func printRevertReason(decoder *evm.ErrorDecoder, data []byte) {
+	if reason := decoder.Decode(data); reason != nil {
+		fmt.Printf("Revert reason: %s\n", reason)
+	}
+}
+
+// LoadErrors adds the custom errors of an ABI, compiler artifact or 4-byte
+// signature database file to decoder
+func LoadErrors(decoder *evm.ErrorDecoder, path string) {
+	count, err := decoder.Load(path)
+	if err != nil {
+		fmt.Printf("Error loading errors: %v\n", err)
+		return
+	}
+	fmt.Printf("Loaded %d errors from %s (%d known)\n", count, path, decoder.Len())
+}
+
+// SplitCommand splits a command line into its words. Double quotes group words
+// containing spaces, e.g. "transfer(address to, uint256 amount)".
+func SplitCommand(command string) []string {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+		quoted bool
+	)
+	for _, c := range command {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			inWord = true
+		case (c == ' ' || c == '\t') && !quoted:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// LoadABI adds a contract ABI, bare or from a compiler artifact, to the registry
+// used by call and its custom errors to decoder
+func LoadABI(registry *evm.ABIRegistry, decoder *evm.ErrorDecoder, path string) {
+	contract, err := registry.Load(path)
+	if err != nil {
+		fmt.Printf("Error loading ABI: %v\n", err)
+		return
+	}
+	decoder.AddABI(contract)
+	fmt.Printf("Loaded %d methods, %d events and %d errors from %s\n",
+		len(contract.Methods), len(contract.Events), len(contract.Errors), path)
+}
+
+// CallContract calls a method of the contract at address from LocalCaller, with
+// the arguments ABI encoded from their text. The return value and the logs are
+// decoded with the loaded ABIs. The state changes are kept, like a transaction.
+func CallContract(chain *evm.EVM, registry *evm.ABIRegistry, address, signature string, args []string) *t.StateDiff {
+	if !common.IsHexAddress(address) {
+		fmt.Printf("Error: invalid address %s\n", address)
+		return nil
+	}
+	to := common.HexToAddress(address)
+
+	method, err := registry.Method(signature)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return nil
+	}
+	data, err := evm.EncodeCall(method, args)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return nil
+	}
+	fmt.Printf("Calling %s on %s\n", method.Sig, to.Hex())
+	fmt.Printf("Call data: 0x%s\n", hex.EncodeToString(data))
+
+	msg := &evm.Message{
+		From:     LocalCaller,
+		To:       &to,
+		Nonce:    chain.State.GetNonce(LocalCaller),
+		GasLimit: LocalGasLimit,
+		GasPrice: uint256.NewInt(0),
+		Data:     data,
+	}
+	revision := chain.State.Snapshot()
+	result, err := evm.ApplyMessage(chain, msg)
+	if err != nil {
+		fmt.Printf("Call rejected: %v\n", err)
+		return nil
+	}
+
+	if result.Failed() {
+		fmt.Printf("Execution failed: %v\n", result.Err)
+		if result.RevertReason != nil {
+			fmt.Printf("Revert reason: %s\n", result.RevertReason)
+		}
+	} else {
+		fmt.Println("Execution successful.")
+		printReturnValue(method, result.ReturnData)
+	}
+	for _, log := range result.Logs {
+		if event := registry.DecodeLog(log); event != nil {
+			fmt.Printf("Event: %s\n", event)
+			continue
+		}
+		fmt.Printf("Log: %s topics %v data 0x%s\n", log.Address.Hex(), log.Topics, hex.EncodeToString(log.Data))
+	}
+	fmt.Printf("Gas used: %d\n", result.UsedGas)
+	return chain.State.Diff(revision)
+}
+
+// printReturnValue prints the decoded return value of a method, or the raw data
+// if its return types are unknown or do not match
+func printReturnValue(method abi.Method, data []byte) {
+	if method.Outputs != nil {
+		values, err := evm.DecodeOutputs(method, data)
+		if err == nil {
+			for _, value := range values {
+				fmt.Printf("Returned: %s\n", value)
+			}
+			return
+		}
+		fmt.Printf("Error decoding return value: %v\n", err)
+	}
+	if len(data) > 0 {
+		fmt.Printf("Return data: 0x%s\n", hex.EncodeToString(data))
+	}
+}
+
+// DeployArtifact deploys a contract from a compiler artifact with CREATE, sent by
+// LocalCaller. The contract is selected with a ":Contract" suffix in outputs of
+// solc holding several contracts. Libraries are linked from "Name=0xaddress"
+// words following --lib, the other words are the constructor arguments. The ABI of
+// the contract is loaded for call and the decoding of its errors.
+func DeployArtifact(chain *evm.EVM, registry *evm.ABIRegistry, decoder *evm.ErrorDecoder, source string, words []string) *t.StateDiff {
+	// The contract name may itself hold a colon, e.g. "out.json:src/Token.sol:Token",
+	// the path is the first prefix naming a file
+	path, contract := source, ""
+	for i, c := range source {
+		if c != ':' {
+			continue
+		}
+		if _, err := os.Stat(source[:i]); err == nil {
+			path, contract = source[:i], source[i+1:]
+			break
+		}
+	}
+	artifact, err := evm.LoadArtifact(path, contract)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return nil
+	}
+
+	var args []string
+	libraries := make(map[string]common.Address)
+	for i := 0; i < len(words); i++ {
+		if words[i] != "--lib" {
+			args = append(args, words[i])
+			continue
+		}
+		if i++; i == len(words) {
+			fmt.Println("Error: missing library after --lib")
+			return nil
+		}
+		name, address, ok := strings.Cut(words[i], "=")
+		if !ok || !common.IsHexAddress(address) {
+			fmt.Printf("Error: invalid library %s, expected <library>=<address>\n", words[i])
+			return nil
+		}
+		libraries[name] = common.HexToAddress(address)
+	}
+
+	data, err := artifact.DeployData(libraries, args)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return nil
+	}
+	fmt.Printf("Deploying %s (%d bytes of initcode)\n", artifact.ContractName, len(data))
+
+	msg := &evm.Message{
+		From:     LocalCaller,
+		Nonce:    chain.State.GetNonce(LocalCaller),
+		GasLimit: LocalGasLimit,
+		GasPrice: uint256.NewInt(0),
+		Data:     data,
+	}
+	revision := chain.State.Snapshot()
+	result, err := evm.ApplyMessage(chain, msg)
+	if err != nil {
+		fmt.Printf("Deployment rejected: %v\n", err)
+		return nil
+	}
+	if result.Failed() {
+		fmt.Printf("Deployment failed: %v\n", result.Err)
+		if result.RevertReason != nil {
+			fmt.Printf("Revert reason: %s\n", result.RevertReason)
+		}
+		fmt.Printf("Gas used: %d\n", result.UsedGas)
+		return chain.State.Diff(revision)
+	}
+
+	registry.Add(artifact.ABI)
+	decoder.AddABI(artifact.ABI)
+	for _, log := range result.Logs {
+		if event := registry.DecodeLog(log); event != nil {
+			fmt.Printf("Event: %s\n", event)
+		}
+	}
+	fmt.Printf("Contract address: %s\n", result.ContractAddress.Hex())
+	fmt.Printf("Code size: %d bytes\n", len(chain.State.GetCode(result.ContractAddress)))
+	fmt.Printf("Gas used: %d\n", result.UsedGas)
+	return chain.State.Diff(revision)
+}
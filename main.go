@@ -2,19 +2,37 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/Manuelshub/go-EVM/evm"
 	h "github.com/Manuelshub/go-EVM/helpers"
+	t "github.com/Manuelshub/go-EVM/types"
 )
 
 func main() {
+	prestate := flag.String("prestate", "", "Genesis alloc / prestate JSON file to seed the world state with")
+	flag.Parse()
+
 	fmt.Println("Go-EVM - A simple Ethereum Virtual Machine implementation")
 	fmt.Println("Type 'help' for available commands")
 
 	executionContext := evm.NewExecutionContext()
+	errorDecoder := evm.NewErrorDecoder()
+	abis := evm.NewABIRegistry()
+	chain := h.NewLocalEVM()
+	chain.Errors = errorDecoder
+	var lastDiff *t.StateDiff
+	coverage := evm.NewCoverageCollector()
+	if *prestate != "" {
+		if err := h.LoadPrestate(chain, *prestate); err != nil {
+			fmt.Printf("Error loading prestate: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Prestate loaded from %s\n", *prestate)
+	}
 
 	for {
 		fmt.Printf("(go-EVM) ")
@@ -27,7 +45,7 @@ func main() {
 			continue
 		}
 
-		parts := strings.Split(command, " ")
+		parts := h.SplitCommand(command)
 		cmd := parts[0]
 
 		switch cmd {
@@ -42,7 +60,160 @@ func main() {
 				fmt.Println("Error: Missing bytecode. Usage: run <bytecode>")
 				continue
 			}
-			h.RunBytecode(executionContext, parts[1])
+			h.RunBytecode(executionContext, errorDecoder, parts[1])
+
+		case "tx":
+			if len(parts) < 2 {
+				fmt.Println("Error: Missing transaction. Usage: tx <raw_tx> [json|call|call-logs|profile]")
+				continue
+			}
+			tracer := ""
+			if len(parts) > 2 {
+				tracer = parts[2]
+			}
+			if diff := h.RunTransaction(chain, parts[1], tracer); diff != nil {
+				lastDiff = diff
+			}
+
+		case "diff":
+			if lastDiff == nil {
+				fmt.Println("No transaction executed yet")
+				continue
+			}
+			h.PrintStateDiff(lastDiff, len(parts) > 1 && parts[1] == "json")
+
+		case "root":
+			fmt.Printf("State root: %s\n", chain.State.IntermediateRoot().Hex())
+
+		case "proof":
+			if len(parts) < 2 {
+				fmt.Println("Error: Missing address. Usage: proof <address> [slots...]")
+				continue
+			}
+			h.PrintProof(chain, parts[1], parts[2:])
+
+		case "state":
+			if len(parts) < 2 {
+				fmt.Println("Error: Missing command. Usage: state save <file> | state load <file> | state dump [file]")
+				continue
+			}
+			switch parts[1] {
+			case "save", "load":
+				if len(parts) < 3 {
+					fmt.Printf("Error: Missing file. Usage: state %s <file>\n", parts[1])
+					continue
+				}
+				if parts[1] == "save" {
+					h.SaveState(chain, parts[2])
+				} else {
+					h.LoadState(chain, parts[2])
+				}
+			case "dump":
+				path := ""
+				if len(parts) > 2 {
+					path = parts[2]
+				}
+				h.DumpState(chain, path)
+			default:
+				fmt.Println("Unknown state command. Usage: state save <file> | state load <file> | state dump [file]")
+			}
+
+		case "trace":
+			if len(parts) < 2 {
+				fmt.Println("Error: Missing bytecode. Usage: trace <bytecode>")
+				continue
+			}
+			h.TraceBytecode(executionContext, errorDecoder, parts[1])
+
+		case "asm":
+			source := strings.TrimSpace(strings.TrimPrefix(command, "asm"))
+			if source == "" {
+				fmt.Println("Error: Missing source. Usage: asm <source|file>")
+				continue
+			}
+			h.AssembleAndRun(executionContext, errorDecoder, source)
+
+		case "disasm":
+			if len(parts) < 2 {
+				fmt.Println("Error: Missing bytecode. Usage: disasm <hex|file>")
+				continue
+			}
+			h.Disassemble(parts[1])
+
+		case "cfg":
+			if len(parts) < 2 {
+				fmt.Println("Error: Missing bytecode. Usage: cfg <hex|file> [dot|json] [out_file]")
+				continue
+			}
+			format, outPath := "dot", ""
+			if len(parts) > 2 {
+				format = parts[2]
+			}
+			if len(parts) > 3 {
+				outPath = parts[3]
+			}
+			h.WriteCFG(parts[1], format, outPath)
+
+		case "profile":
+			if len(parts) < 2 {
+				fmt.Println("Error: Missing bytecode. Usage: profile <bytecode> [folded_file]")
+				continue
+			}
+			foldedPath := ""
+			if len(parts) > 2 {
+				foldedPath = parts[2]
+			}
+			h.ProfileBytecode(executionContext, parts[1], foldedPath)
+
+		case "cover":
+			if len(parts) < 2 {
+				fmt.Println("Error: Missing bytecode. Usage: cover <bytecode>")
+				continue
+			}
+			h.CoverBytecode(executionContext, coverage, parts[1])
+
+		case "coverage":
+			if len(parts) > 1 && parts[1] == "lcov" {
+				if len(parts) < 6 {
+					fmt.Println("Error: Missing arguments. Usage: coverage lcov <code_hash> <source_map_file> <out_file> <sources...>")
+					continue
+				}
+				h.WriteLcov(coverage, parts[2], parts[3], parts[4], parts[5:])
+				continue
+			}
+			h.PrintCoverage(coverage, len(parts) > 1 && parts[1] == "annotate")
+
+		case "abi":
+			if len(parts) < 2 {
+				fmt.Println("Error: Missing file. Usage: abi <abi|artifact file>")
+				continue
+			}
+			h.LoadABI(abis, errorDecoder, parts[1])
+
+		case "call":
+			if len(parts) < 3 {
+				fmt.Println("Error: Missing arguments. Usage: call <address> <signature|method> [args...]")
+				continue
+			}
+			if diff := h.CallContract(chain, abis, parts[1], parts[2], parts[3:]); diff != nil {
+				lastDiff = diff
+			}
+
+		case "deploy":
+			if len(parts) < 2 {
+				fmt.Println("Error: Missing artifact. Usage: deploy <artifact[:Contract]> [args...] [--lib <library>=<address>...]")
+				continue
+			}
+			if diff := h.DeployArtifact(chain, abis, errorDecoder, parts[1], parts[2:]); diff != nil {
+				lastDiff = diff
+			}
+
+		case "errors":
+			if len(parts) < 2 {
+				fmt.Println("Error: Missing file. Usage: errors <abi|artifact|signatures file>")
+				continue
+			}
+			h.LoadErrors(errorDecoder, parts[1])
 
 		case "stack":
 			fmt.Println(executionContext.Stack.ToString())
@@ -59,10 +230,19 @@ func main() {
 				fmt.Println("Error: Missing value. Usage: debug <bytecode>")
 				continue
 			}
-			h.DebugBytecode(executionContext, parts[1])
+			h.DebugBytecode(executionContext, errorDecoder, parts[1])
 
 		case "reset":
 			executionContext = evm.NewExecutionContext()
+			chain = h.NewLocalEVM()
+			chain.Errors = errorDecoder
+			lastDiff = nil
+			coverage = evm.NewCoverageCollector()
+			if *prestate != "" {
+				if err := h.LoadPrestate(chain, *prestate); err != nil {
+					fmt.Printf("Error loading prestate: %v\n", err)
+				}
+			}
 			fmt.Println("Execution context reset")
 
 		default:
@@ -0,0 +1,64 @@
+package types
+
+import "github.com/ethereum/go-ethereum/common"
+
+// AccessList is the set of addresses and storage slots that have been accessed
+// during a transaction (EIP-2929). Accessed ("warm") entries are cheaper to touch again.
+type AccessList struct {
+	addresses map[common.Address]map[common.Hash]struct{}
+}
+
+// NewAccessList creates an empty AccessList
+func NewAccessList() *AccessList {
+	return &AccessList{
+		addresses: make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// ContainsAddress returns true if the address is in the access list
+func (al *AccessList) ContainsAddress(address common.Address) bool {
+	_, ok := al.addresses[address]
+	return ok
+}
+
+// Contains returns whether the address and the slot are in the access list
+func (al *AccessList) Contains(address common.Address, slot common.Hash) (addressPresent bool, slotPresent bool) {
+	slots, ok := al.addresses[address]
+	if !ok {
+		return false, false
+	}
+	_, slotPresent = slots[slot]
+	return true, slotPresent
+}
+
+// AddAddress adds an address to the access list, returning true if it was not present before
+func (al *AccessList) AddAddress(address common.Address) bool {
+	if _, ok := al.addresses[address]; ok {
+		return false
+	}
+	al.addresses[address] = make(map[common.Hash]struct{})
+	return true
+}
+
+// AddSlot adds the address and slot to the access list. It returns whether each
+// of them was newly added.
+func (al *AccessList) AddSlot(address common.Address, slot common.Hash) (addrChange bool, slotChange bool) {
+	addrChange = al.AddAddress(address)
+	if _, ok := al.addresses[address][slot]; ok {
+		return addrChange, false
+	}
+	al.addresses[address][slot] = struct{}{}
+	return addrChange, true
+}
+
+// deleteAddress removes an address from the access list. Used by the journal only.
+func (al *AccessList) deleteAddress(address common.Address) {
+	delete(al.addresses, address)
+}
+
+// deleteSlot removes a slot from the access list. Used by the journal only.
+func (al *AccessList) deleteSlot(address common.Address, slot common.Hash) {
+	if slots, ok := al.addresses[address]; ok {
+		delete(slots, slot)
+	}
+}
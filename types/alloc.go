@@ -0,0 +1,85 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+)
+
+// ReadAlloc reads a genesis alloc / prestate JSON file, a map from addresses to
+// accounts with balance, nonce, code and storage as used by geth's t8n tool and
+// the prestate tracer
+func ReadAlloc(path string) (gethtypes.GenesisAlloc, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var alloc gethtypes.GenesisAlloc
+	if err := json.Unmarshal(data, &alloc); err != nil {
+		return nil, fmt.Errorf("decoding alloc %s: %w", path, err)
+	}
+	return alloc, nil
+}
+
+// LoadAlloc creates a world state seeded with the accounts of an alloc
+func LoadAlloc(alloc gethtypes.GenesisAlloc) (*StateDB, error) {
+	accounts := make([]*StoredAccount, 0, len(alloc))
+	for address, account := range alloc {
+		stored := &StoredAccount{
+			Address: address,
+			Nonce:   account.Nonce,
+			Balance: new(uint256.Int),
+			Code:    account.Code,
+		}
+		if account.Balance != nil {
+			if stored.Balance.SetFromBig(account.Balance) {
+				return nil, fmt.Errorf("balance of %s overflows 256 bits", address.Hex())
+			}
+		}
+		for key, value := range account.Storage {
+			stored.Storage = append(stored.Storage, StoredSlot{Key: key, Value: value})
+		}
+		accounts = append(accounts, stored)
+	}
+	// Order the accounts so that loading is deterministic
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i].Address.Bytes(), accounts[j].Address.Bytes()) < 0
+	})
+	return newStateDBFromAccounts(accounts), nil
+}
+
+// DumpAlloc returns all accounts of the state in the genesis alloc format.
+// Zero storage slots are left out.
+func (s *StateDB) DumpAlloc() gethtypes.GenesisAlloc {
+	alloc := make(gethtypes.GenesisAlloc, len(s.accounts))
+	for _, stored := range s.storedAccounts() {
+		account := gethtypes.Account{
+			Nonce:   stored.Nonce,
+			Balance: stored.Balance.ToBig(),
+			Code:    stored.Code,
+		}
+		if len(stored.Storage) > 0 {
+			account.Storage = make(map[common.Hash]common.Hash, len(stored.Storage))
+			for _, slot := range stored.Storage {
+				account.Storage[slot.Key] = slot.Value
+			}
+		}
+		alloc[stored.Address] = account
+	}
+	return alloc
+}
+
+// WriteAlloc writes the state to a file in the genesis alloc JSON format
+func (s *StateDB) WriteAlloc(path string) error {
+	data, err := json.MarshalIndent(s.DumpAlloc(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
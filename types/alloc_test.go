@@ -0,0 +1,46 @@
+package types
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlloc(test *testing.T) {
+	prestate := `{
+		"0x0000000000000000000000000000000000001000": {"balance": "0xde0b6b3a7640000", "nonce": "0x3"},
+		"0000000000000000000000000000000000002000": {
+			"balance": "42",
+			"code": "0x600160005500",
+			"storage": {"0x01": "0x2a", "0x0000000000000000000000000000000000000000000000000000000000000002": "0xbeef"}
+		}
+	}`
+	var alloc gethtypes.GenesisAlloc
+	require.NoError(test, json.Unmarshal([]byte(prestate), &alloc))
+
+	state, err := LoadAlloc(alloc)
+	require.NoError(test, err)
+	eoa, contract := common.HexToAddress("0x1000"), common.HexToAddress("0x2000")
+	assert.Equal(test, uint64(1_000_000_000_000_000_000), state.GetBalance(eoa).Uint64())
+	assert.Equal(test, uint64(3), state.GetNonce(eoa))
+	assert.Equal(test, uint64(42), state.GetBalance(contract).Uint64())
+	assert.Equal(test, common.FromHex("0x600160005500"), state.GetCode(contract))
+	assert.Equal(test, common.HexToHash("0x2a"), state.GetState(contract, common.HexToHash("0x01")))
+	assert.Equal(test, common.HexToHash("0xbeef"), state.GetState(contract, common.HexToHash("0x02")))
+
+	// Dumping and reading back the state gives the same alloc
+	path := filepath.Join(test.TempDir(), "alloc.json")
+	require.NoError(test, state.WriteAlloc(path))
+	dumped, err := ReadAlloc(path)
+	require.NoError(test, err)
+	assert.Equal(test, alloc, dumped)
+
+	reloaded, err := LoadAlloc(dumped)
+	require.NoError(test, err)
+	assert.Equal(test, state.IntermediateRoot(), reloaded.IntermediateRoot())
+}
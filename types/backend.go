@@ -0,0 +1,170 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/holiman/uint256"
+)
+
+// StoredAccount is the persisted form of an account
+type StoredAccount struct {
+	Address common.Address
+	Nonce   uint64
+	Balance *uint256.Int
+	Code    []byte
+	Storage []StoredSlot
+}
+
+// StoredSlot is a non-zero storage slot of a persisted account
+type StoredSlot struct {
+	Key   common.Hash
+	Value common.Hash
+}
+
+// Backend persists the accounts of a world state between sessions
+type Backend interface {
+	// Load returns the accounts previously saved
+	Load() ([]*StoredAccount, error)
+	// Save replaces the saved accounts
+	Save(accounts []*StoredAccount) error
+}
+
+// ===== Memory backend =====
+
+// MemoryBackend keeps the saved accounts in memory
+type MemoryBackend struct {
+	accounts []*StoredAccount
+}
+
+// NewMemoryBackend creates an empty MemoryBackend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{}
+}
+
+// Load returns the accounts previously saved
+func (b *MemoryBackend) Load() ([]*StoredAccount, error) {
+	return b.accounts, nil
+}
+
+// Save replaces the saved accounts
+func (b *MemoryBackend) Save(accounts []*StoredAccount) error {
+	b.accounts = accounts
+	return nil
+}
+
+// ===== File backend =====
+
+// FileBackend saves the accounts to a file as an RLP snapshot
+type FileBackend struct {
+	path string
+}
+
+// NewFileBackend creates a FileBackend reading and writing the given file
+func NewFileBackend(path string) *FileBackend {
+	return &FileBackend{path: path}
+}
+
+// Load decodes the accounts stored in the file
+func (b *FileBackend) Load() ([]*StoredAccount, error) {
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []*StoredAccount
+	if err := rlp.DecodeBytes(data, &accounts); err != nil {
+		return nil, fmt.Errorf("decoding state snapshot %s: %w", b.path, err)
+	}
+	return accounts, nil
+}
+
+// Save writes the accounts to the file. The file is replaced atomically so that
+// an interrupted save does not corrupt an earlier snapshot.
+func (b *FileBackend) Save(accounts []*StoredAccount) error {
+	data, err := rlp.EncodeToBytes(accounts)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(b.path), filepath.Base(b.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), b.path)
+}
+
+// ===== StateDB persistence =====
+
+// Save stores all accounts of the state in the backend
+func (s *StateDB) Save(backend Backend) error {
+	return backend.Save(s.storedAccounts())
+}
+
+// storedAccounts returns the persisted form of all accounts, sorted by address.
+// Zero storage slots are left out.
+func (s *StateDB) storedAccounts() []*StoredAccount {
+	accounts := make([]*StoredAccount, 0, len(s.accounts))
+	for address, account := range s.accounts {
+		stored := &StoredAccount{
+			Address: address,
+			Nonce:   account.Nonce,
+			Balance: new(uint256.Int).Set(account.Balance),
+			Code:    common.CopyBytes(account.Code),
+		}
+		for key, value := range account.Storage.elem {
+			if len(common.TrimLeftZeroes(value)) == 0 {
+				continue
+			}
+			stored.Storage = append(stored.Storage, StoredSlot{Key: key, Value: common.BytesToHash(value)})
+		}
+		sort.Slice(stored.Storage, func(i, j int) bool {
+			return bytes.Compare(stored.Storage[i].Key.Bytes(), stored.Storage[j].Key.Bytes()) < 0
+		})
+		accounts = append(accounts, stored)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i].Address.Bytes(), accounts[j].Address.Bytes()) < 0
+	})
+	return accounts
+}
+
+// LoadStateDB creates a world state holding the accounts saved in the backend
+func LoadStateDB(backend Backend) (*StateDB, error) {
+	accounts, err := backend.Load()
+	if err != nil {
+		return nil, err
+	}
+	return newStateDBFromAccounts(accounts), nil
+}
+
+// newStateDBFromAccounts creates a world state holding the given accounts.
+// The accounts are not journaled, they cannot be reverted.
+func newStateDBFromAccounts(accounts []*StoredAccount) *StateDB {
+	s := NewStateDB()
+	for _, stored := range accounts {
+		account := s.createAccount(stored.Address)
+		account.Nonce = stored.Nonce
+		if stored.Balance != nil {
+			account.Balance = new(uint256.Int).Set(stored.Balance)
+		}
+		account.Code = stored.Code
+		for _, slot := range stored.Storage {
+			account.Storage.restore(slot.Key, slot.Value.Bytes())
+		}
+	}
+	s.journal.entries = s.journal.entries[:0]
+	return s
+}
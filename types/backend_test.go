@@ -0,0 +1,51 @@
+package types
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateBackends(test *testing.T) {
+	state := NewStateDB()
+	contract := common.HexToAddress("0x2000")
+	state.SetNonce(contract, 1)
+	state.SetBalance(contract, uint256.NewInt(42))
+	state.SetCode(contract, common.FromHex("0x600160005500"))
+	state.SetState(contract, common.HexToHash("0x01"), common.HexToHash("0x2a"))
+	state.SetState(contract, common.HexToHash("0x02"), common.Hash{})
+	state.SetBalance(common.HexToAddress("0x1000"), uint256.NewInt(1))
+
+	tests := []struct {
+		name    string
+		backend Backend
+	}{
+		{"Memory", NewMemoryBackend()},
+		{"File", NewFileBackend(filepath.Join(test.TempDir(), "state.rlp"))},
+	}
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			require.NoError(test, state.Save(tt.backend))
+
+			loaded, err := LoadStateDB(tt.backend)
+			require.NoError(test, err)
+			assert.Equal(test, state.IntermediateRoot(), loaded.IntermediateRoot())
+			assert.Equal(test, uint64(1), loaded.GetNonce(contract))
+			assert.Equal(test, common.HexToHash("0x2a"), loaded.GetState(contract, common.HexToHash("0x01")))
+
+			// The loaded accounts survive reverting to the initial snapshot
+			loaded.SetBalance(contract, uint256.NewInt(0))
+			loaded.RevertToSnapshot(0)
+			assert.Equal(test, uint64(42), loaded.GetBalance(contract).Uint64())
+		})
+	}
+
+	test.Run("Missing file", func(test *testing.T) {
+		_, err := LoadStateDB(NewFileBackend(filepath.Join(test.TempDir(), "missing.rlp")))
+		assert.Error(test, err)
+	})
+}
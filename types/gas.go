@@ -18,20 +18,73 @@ const (
 	GasTierHigh         uint64 = 10    // High gas tier
 	GasTierExtcode      uint64 = 700   // Extcode gas tier
 	GasTierBalance      uint64 = 400   // Balance gas tier
-	GasTierSLoad        uint64 = 800   // SLoad gas tier (was 200 before EIP-2929, 2200 before EIP-2200)
 	GasCreateByte       uint64 = 200   // Gas cost per byte of contract creation code
 	GasCallStipend      uint64 = 2300  // Free gas given at beginning of call
 	GasMemoryGrowthCost uint64 = 3     // Gas cost for memory growth per word (32 bytes)
 	GasStorageSet       uint64 = 20000 // Gas cost to set a storage slot from 0 to non-0
-	GasStorageUpdate    uint64 = 5000  // Gas cost to update a storage slot
-	GasStorageRefund    uint64 = 15000 // Gas refund for clearing a storage slot
+	GasStorageUpdate    uint64 = 5000  // Gas cost to update a non-zero storage slot, including its cold access
+	GasStorageClear     uint64 = 4800  // Gas refund for clearing a storage slot (EIP-3529)
+	GasSstoreSentry     uint64 = 2300  // SSTORE fails unless more gas than this is left (EIP-2200)
+	GasCopyWord         uint64 = 3     // Gas cost per word copied by *COPY operations
+	GasLog              uint64 = 375   // Base gas cost of a LOG operation
+	GasLogTopic         uint64 = 375   // Gas cost per topic of a LOG operation
+	GasLogData          uint64 = 8     // Gas cost per byte of data of a LOG operation
+	GasKeccak256        uint64 = 30    // Base gas cost of a KECCAK256 operation
+	GasKeccak256Word    uint64 = 6     // Gas cost per word hashed by KECCAK256
+	GasBlockhash        uint64 = 20    // Gas cost of a BLOCKHASH operation
+)
+
+// Account access and message call gas costs
+const (
+	GasWarmAccess             uint64 = 100   // Cost of accessing a warm account or slot (EIP-2929)
+	GasColdAccountAccess      uint64 = 2600  // Cost of accessing a cold account (EIP-2929)
+	GasColdSload              uint64 = 2100  // Cost of accessing a cold storage slot (EIP-2929)
+	GasCallValueTransfer      uint64 = 9000  // Extra cost of a call transferring value
+	GasCallNewAccount         uint64 = 25000 // Extra cost of a call creating a new account
+	GasCallGasDivisor         uint64 = 64    // A call can forward all but 1/64th of the remaining gas (EIP-150)
+	GasPerEmptyAccountCost    uint64 = 25000 // Intrinsic cost per authorization tuple (EIP-7702)
+	GasPerAuthBaseCost        uint64 = 12500 // Cost of an authorization tuple for an existing account (EIP-7702)
+	GasCreate                 uint64 = 32000 // Base cost of the CREATE and CREATE2 operations
+	GasSelfdestruct           uint64 = 5000  // Base cost of a SELFDESTRUCT operation
+	GasSelfdestructNewAccount uint64 = 25000 // Extra cost of a SELFDESTRUCT sending a balance to an empty account
+)
+
+// Transaction level gas costs
+const (
+	TxGas                     uint64 = 21000 // Base cost of every transaction
+	TxGasContractCreation     uint64 = 53000 // Base cost of a contract creation transaction
+	TxDataZeroGas             uint64 = 4     // Cost per zero byte of transaction data
+	TxDataNonZeroGas          uint64 = 16    // Cost per non-zero byte of transaction data (EIP-2028)
+	TxAccessListAddressGas    uint64 = 2400  // Cost per address in the access list (EIP-2930)
+	TxAccessListStorageKeyGas uint64 = 1900  // Cost per storage key in the access list (EIP-2930)
+	InitCodeWordGas           uint64 = 2     // Cost per word of initcode (EIP-3860)
+	RefundQuotient            uint64 = 5     // Maximum refund is gasUsed / RefundQuotient (EIP-3529)
+)
+
+// Blob gas parameters (EIP-4844, with the Prague schedule of EIP-7691)
+const (
+	GasPerBlob                       uint64 = 1 << 17                                // Blob gas consumed by a single blob
+	BlobTxMinBlobGasprice            uint64 = 1                                      // Minimum price of a unit of blob gas
+	BlobTxBlobGaspriceUpdateFraction uint64 = 5007716                                // Controls how fast the blob base fee changes
+	BlobTxTargetBlobsPerBlock        uint64 = 6                                      // Target number of blobs per block
+	BlobTxMaxBlobsPerBlock           uint64 = 9                                      // Maximum number of blobs per block
+	BlobTxHashVersion                byte   = 0x01                                   // Version byte of KZG versioned hashes
+	BlobTxTargetBlobGasPerBlock             = BlobTxTargetBlobsPerBlock * GasPerBlob // Target blob gas per block
+	BlobTxMaxBlobGasPerBlock                = BlobTxMaxBlobsPerBlock * GasPerBlob    // Maximum blob gas per block
+)
+
+// Execution limits
+const (
+	MaxCodeSize     = 24576           // Maximum size of deployed contract code (EIP-170)
+	MaxInitCodeSize = 2 * MaxCodeSize // Maximum size of initcode (EIP-3860)
+	CallCreateDepth = 1024            // Maximum depth of nested calls and creations
 )
 
 // GasMeter tracks gas usage and refunds during execution
 type GasMeter struct {
 	gasLimit    uint64
 	gasUsed     uint64
-	gasRefunded uint64
+	gasRefunded int64 // Negative in a frame undoing a refund granted by its parents
 }
 
 // NewGasMeter creates a new GasMeter with the specified gas limit
@@ -45,7 +98,7 @@ func NewGasMeter(gasLimit uint64) *GasMeter {
 
 // UseGas consumes the specified amount of gas and returns error if not enough gas is available
 func (g *GasMeter) UseGas(amount uint64) error {
-	if g.gasUsed+amount > g.gasLimit {
+	if amount > g.GasRemaining() {
 		g.gasUsed = g.gasLimit
 		return ErrOutOfGas
 	}
@@ -55,7 +108,38 @@ func (g *GasMeter) UseGas(amount uint64) error {
 
 // RefundGas adds the specified amount to the gas refund counter
 func (g *GasMeter) RefundGas(amount uint64) {
-	g.gasRefunded += amount
+	g.gasRefunded += int64(amount)
+}
+
+// SubRefund removes the specified amount from the gas refund counter, e.g. when
+// a cleared storage slot is set again
+func (g *GasMeter) SubRefund(amount uint64) {
+	g.gasRefunded -= int64(amount)
+}
+
+// MergeRefund adds the refunds of a child frame that succeeded, which can be
+// negative if it undid refunds of this frame
+func (g *GasMeter) MergeRefund(child *GasMeter) {
+	g.gasRefunded += child.gasRefunded
+}
+
+// ReturnGas gives back gas that was consumed but not used, e.g. the leftover
+// gas of a child call frame
+func (g *GasMeter) ReturnGas(amount uint64) {
+	if amount > g.gasUsed {
+		amount = g.gasUsed
+	}
+	g.gasUsed -= amount
+}
+
+// ResetRefund discards the refunds collected so far. It is used when a frame reverts.
+func (g *GasMeter) ResetRefund() {
+	g.gasRefunded = 0
+}
+
+// GasLimit returns the gas limit of the meter
+func (g *GasMeter) GasLimit() uint64 {
+	return g.gasLimit
 }
 
 // GasConsumed returns the amount of gas used so far
@@ -74,7 +158,10 @@ func (g *GasMeter) GasRemaining() uint64 {
 // GasRefunded returns the amount of gas that will be refunded at the end of execution
 // Note: Ethereum caps refunds at gasUsed/5, but we leave that calculation to the caller
 func (g *GasMeter) GasRefunded() uint64 {
-	return g.gasRefunded
+	if g.gasRefunded < 0 {
+		return 0
+	}
+	return uint64(g.gasRefunded)
 }
 
 // CalculateMemoryGasCost calculates the gas cost for expanding memory
@@ -0,0 +1,203 @@
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// journalEntry is a single modification of the world state that can be undone
+type journalEntry interface {
+	// revert undoes the change on the given state
+	revert(s *StateDB)
+}
+
+// journal is an ordered list of state modifications, used to roll the state
+// back to a snapshot when a call frame reverts
+type journal struct {
+	entries []journalEntry
+	// committed counts the modifications dropped by reset, so that lengths keep
+	// growing across resets and older ones can be recognised
+	committed int
+	// originals holds the values the storage slots written since the last reset
+	// had before their first write. A nil value means the slot did not exist.
+	originals map[common.Address]map[common.Hash][]byte
+}
+
+func newJournal() *journal {
+	return &journal{
+		entries:   make([]journalEntry, 0),
+		originals: make(map[common.Address]map[common.Hash][]byte),
+	}
+}
+
+// append records a new modification
+func (j *journal) append(entry journalEntry) {
+	j.entries = append(j.entries, entry)
+}
+
+// recordOriginal keeps the value of a storage slot about to be written, unless it
+// was already written since the last reset. Reverting the write does not drop it:
+// the slot goes back to a value it had since, which is still the original one.
+func (j *journal) recordOriginal(address common.Address, key common.Hash, value []byte) {
+	slots := j.originals[address]
+	if slots == nil {
+		slots = make(map[common.Hash][]byte)
+		j.originals[address] = slots
+	}
+	if _, ok := slots[key]; !ok {
+		slots[key] = value
+	}
+}
+
+// original returns the value a storage slot had at the last reset, if it was
+// written since
+func (j *journal) original(address common.Address, key common.Hash) ([]byte, bool) {
+	value, ok := j.originals[address][key]
+	return value, ok
+}
+
+// length returns the number of modifications recorded since the journal was created
+func (j *journal) length() int {
+	return j.committed + len(j.entries)
+}
+
+// since returns the modifications recorded after the given length. Lengths from
+// before the last reset return all of them.
+func (j *journal) since(length int) []journalEntry {
+	return j.entries[max(length-j.committed, 0):]
+}
+
+// reset drops all recorded modifications, they can no longer be reverted
+func (j *journal) reset() {
+	j.committed += len(j.entries)
+	j.entries = make([]journalEntry, 0)
+	j.originals = make(map[common.Address]map[common.Hash][]byte)
+}
+
+// revert undoes all modifications recorded after the given length, newest first.
+// Lengths from before the last reset refer to modifications that were dropped
+// and nothing is undone.
+func (j *journal) revert(s *StateDB, length int) {
+	if length < j.committed {
+		return
+	}
+	length -= j.committed
+	for i := len(j.entries) - 1; i >= length; i-- {
+		j.entries[i].revert(s)
+	}
+	j.entries = j.entries[:length]
+}
+
+type (
+	// createAccountChange records the creation of a previously missing account
+	createAccountChange struct {
+		address common.Address
+	}
+	// balanceChange records the balance of an account before it was modified
+	balanceChange struct {
+		address common.Address
+		prev    *uint256.Int
+	}
+	// nonceChange records the nonce of an account before it was modified
+	nonceChange struct {
+		address common.Address
+		prev    uint64
+	}
+	// codeChange records the code of an account before it was modified
+	codeChange struct {
+		address common.Address
+		prev    []byte
+	}
+	// createContractChange records a contract being created by the transaction
+	createContractChange struct {
+		address common.Address
+	}
+	// selfDestructChange records an account being marked for deletion
+	selfDestructChange struct {
+		address common.Address
+	}
+	// deleteAccountChange records the deletion of a self-destructed account
+	deleteAccountChange struct {
+		address common.Address
+		prev    *Account
+	}
+	// storageChange records the value of a storage slot before it was modified.
+	// A nil prev means the slot did not exist.
+	storageChange struct {
+		address common.Address
+		key     common.Hash
+		prev    []byte
+	}
+	// accessListAddAccountChange records an address being warmed
+	accessListAddAccountChange struct {
+		address common.Address
+	}
+	// accessListAddSlotChange records a storage slot being warmed
+	accessListAddSlotChange struct {
+		address common.Address
+		slot    common.Hash
+	}
+	// addLogChange records a log being emitted
+	addLogChange struct{}
+	// transientStorageChange records the value of a transient storage slot before
+	// it was modified. A nil prev means the slot was not set.
+	transientStorageChange struct {
+		address common.Address
+		key     common.Hash
+		prev    []byte
+	}
+)
+
+func (ch createAccountChange) revert(s *StateDB) {
+	delete(s.accounts, ch.address)
+}
+
+func (ch balanceChange) revert(s *StateDB) {
+	s.accounts[ch.address].Balance = ch.prev
+}
+
+func (ch nonceChange) revert(s *StateDB) {
+	s.accounts[ch.address].Nonce = ch.prev
+}
+
+func (ch codeChange) revert(s *StateDB) {
+	s.accounts[ch.address].Code = ch.prev
+}
+
+func (ch storageChange) revert(s *StateDB) {
+	s.accounts[ch.address].Storage.restore(ch.key, ch.prev)
+}
+
+func (ch accessListAddAccountChange) revert(s *StateDB) {
+	s.accessList.deleteAddress(ch.address)
+}
+
+func (ch accessListAddSlotChange) revert(s *StateDB) {
+	s.accessList.deleteSlot(ch.address, ch.slot)
+}
+
+func (ch addLogChange) revert(s *StateDB) {
+	// The logs may have been reset since, in which case there is nothing to undo
+	if len(s.logs) > 0 {
+		s.logs = s.logs[:len(s.logs)-1]
+	}
+}
+
+func (ch transientStorageChange) revert(s *StateDB) {
+	// The transient storage may have been cleared since, in which case there is nothing to undo
+	if storage := s.transientStorage[ch.address]; storage != nil {
+		storage.restore(ch.key, ch.prev)
+	}
+}
+
+func (ch createContractChange) revert(s *StateDB) {
+	delete(s.newContracts, ch.address)
+}
+
+func (ch selfDestructChange) revert(s *StateDB) {
+	delete(s.destructed, ch.address)
+}
+
+func (ch deleteAccountChange) revert(s *StateDB) {
+	s.accounts[ch.address] = ch.prev
+}
@@ -0,0 +1,10 @@
+package types
+
+import "github.com/ethereum/go-ethereum/common"
+
+// Log is a record emitted by the LOG0-LOG4 instructions
+type Log struct {
+	Address common.Address // Address of the contract that emitted the log
+	Topics  []common.Hash  // Indexed topics, at most four
+	Data    []byte         // Non-indexed data
+}
@@ -1,5 +1,7 @@
 package types
 
+import "fmt"
+
 type Opcode byte
 
 // Stop opcode
@@ -72,7 +74,7 @@ const (
 
 // Stack pop
 const (
-	POP = 0x50 // Remove item from stack
+	POP Opcode = 0x50 // Remove item from stack
 )
 
 // Stack push opcodes
@@ -194,5 +196,121 @@ const (
 	CREATE2      Opcode = 0xF5 // Create a new account with associated code at a predictable address
 	STATICCALL   Opcode = 0xFA // Static message-call into an account
 	REVERT       Opcode = 0xFD // Halt execution and revert state changes but return data and remaining gas
+	INVALID      Opcode = 0xFE // Designated invalid instruction
 	SELFDESTRUCT Opcode = 0xFF // Halt execution and register account for later deletion
 )
+
+// opcodeNames maps the opcodes to their mnemonics. The numbered PUSH, DUP,
+// SWAP and LOG operations are added in init.
+var opcodeNames = map[Opcode]string{
+	STOP:           "STOP",
+	ADD:            "ADD",
+	MUL:            "MUL",
+	SUB:            "SUB",
+	DIV:            "DIV",
+	SDIV:           "SDIV",
+	MOD:            "MOD",
+	SMOD:           "SMOD",
+	ADDMOD:         "ADDMOD",
+	MULMOD:         "MULMOD",
+	EXP:            "EXP",
+	SIGNEXTEND:     "SIGNEXTEND",
+	LT:             "LT",
+	GT:             "GT",
+	SLT:            "SLT",
+	SGT:            "SGT",
+	EQ:             "EQ",
+	ISZERO:         "ISZERO",
+	AND:            "AND",
+	OR:             "OR",
+	XOR:            "XOR",
+	NOT:            "NOT",
+	BYTE:           "BYTE",
+	SHL:            "SHL",
+	SHR:            "SHR",
+	SAR:            "SAR",
+	PC:             "PC",
+	GAS:            "GAS",
+	KECCAK256:      "KECCAK256",
+	ADDRESS:        "ADDRESS",
+	BALANCE:        "BALANCE",
+	ORIGIN:         "ORIGIN",
+	CALLER:         "CALLER",
+	CALLVALUE:      "CALLVALUE",
+	CALLDATALOAD:   "CALLDATALOAD",
+	CALLDATASIZE:   "CALLDATASIZE",
+	CALLDATACOPY:   "CALLDATACOPY",
+	CODESIZE:       "CODESIZE",
+	CODECOPY:       "CODECOPY",
+	GASPRICE:       "GASPRICE",
+	EXTCODESIZE:    "EXTCODESIZE",
+	EXTCODECOPY:    "EXTCODECOPY",
+	RETURNDATASIZE: "RETURNDATASIZE",
+	RETURNDATACOPY: "RETURNDATACOPY",
+	EXTCODEHASH:    "EXTCODEHASH",
+	BLOCKHASH:      "BLOCKHASH",
+	COINBASE:       "COINBASE",
+	TIMESTAMP:      "TIMESTAMP",
+	NUMBER:         "NUMBER",
+	PREVRANDAO:     "PREVRANDAO",
+	GASLIMIT:       "GASLIMIT",
+	CHAINID:        "CHAINID",
+	SELFBALANCE:    "SELFBALANCE",
+	BASEFEE:        "BASEFEE",
+	BLOBHASH:       "BLOBHASH",
+	BLOBBASEFEE:    "BLOBBASEFEE",
+	POP:            "POP",
+	PUSH0:          "PUSH0",
+	MLOAD:          "MLOAD",
+	MSTORE:         "MSTORE",
+	MSTORE8:        "MSTORE8",
+	MSIZE:          "MSIZE",
+	MCOPY:          "MCOPY",
+	SLOAD:          "SLOAD",
+	SSTORE:         "SSTORE",
+	TLOAD:          "TLOAD",
+	TSTORE:         "TSTORE",
+	JUMP:           "JUMP",
+	JUMPI:          "JUMPI",
+	JUMPDEST:       "JUMPDEST",
+	CREATE:         "CREATE",
+	CALL:           "CALL",
+	CALLCODE:       "CALLCODE",
+	RETURN:         "RETURN",
+	DELEGATECALL:   "DELEGATECALL",
+	CREATE2:        "CREATE2",
+	STATICCALL:     "STATICCALL",
+	REVERT:         "REVERT",
+	INVALID:        "INVALID",
+	SELFDESTRUCT:   "SELFDESTRUCT",
+}
+
+// opcodesByName is the reverse of opcodeNames
+var opcodesByName = make(map[string]Opcode)
+
+func init() {
+	for i := 1; i <= 32; i++ {
+		opcodeNames[PUSH1+Opcode(i-1)] = fmt.Sprintf("PUSH%d", i)
+	}
+	for i := 1; i <= 16; i++ {
+		opcodeNames[DUP1+Opcode(i-1)] = fmt.Sprintf("DUP%d", i)
+		opcodeNames[SWAP1+Opcode(i-1)] = fmt.Sprintf("SWAP%d", i)
+	}
+	for i := 0; i <= 4; i++ {
+		opcodeNames[LOG0+Opcode(i)] = fmt.Sprintf("LOG%d", i)
+	}
+	for op, name := range opcodeNames {
+		opcodesByName[name] = op
+	}
+}
+
+// OpcodeName returns the mnemonic of an opcode, empty if the opcode is not defined
+func OpcodeName(op Opcode) string {
+	return opcodeNames[op]
+}
+
+// OpcodeByName returns the opcode of a mnemonic
+func OpcodeByName(name string) (Opcode, bool) {
+	op, ok := opcodesByName[name]
+	return op, ok
+}
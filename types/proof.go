@@ -0,0 +1,189 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
+)
+
+// Errors returned when verifying a proof
+var (
+	ErrInvalidAccountProof = errors.New("invalid account proof")
+	ErrInvalidStorageProof = errors.New("invalid storage proof")
+)
+
+// errProofDelete is returned when something tries to remove a node from a proof
+var errProofDelete = errors.New("proof nodes cannot be deleted")
+
+// AccountResult is the proof of an account and some of its storage slots,
+// in the shape returned by eth_getProof (EIP-1186)
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the proof of a single storage slot
+type StorageResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+// proofList collects the trie nodes of a proof, from the root down, as hex strings
+type proofList []string
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, hexutil.Encode(value))
+	return nil
+}
+
+func (n *proofList) Delete(key []byte) error {
+	return errProofDelete
+}
+
+// newTrie builds a trie holding the given entries. It lives in memory only and
+// is used to generate proofs.
+func newTrie(entries []trieEntry) *trie.Trie {
+	tr := trie.NewEmpty(nil)
+	for _, entry := range entries {
+		tr.MustUpdate(entry.key, entry.value)
+	}
+	return tr
+}
+
+// prove returns the proof of a key in the given trie. Proofs of missing keys
+// prove their absence.
+func prove(tr *trie.Trie, key []byte) ([]string, error) {
+	proof := make(proofList, 0)
+	if err := tr.Prove(crypto.Keccak256(key), &proof); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// GetProof returns the Merkle proof of an account and of the given storage slots
+// against the current state root
+func (s *StateDB) GetProof(address common.Address, slots []common.Hash) (*AccountResult, error) {
+	accountProof, err := prove(newTrie(s.accountEntries()), address.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AccountResult{
+		Address:      address,
+		AccountProof: accountProof,
+		Balance:      (*hexutil.Big)(s.GetBalance(address).ToBig()),
+		CodeHash:     gethtypes.EmptyCodeHash,
+		StorageHash:  gethtypes.EmptyRootHash,
+		StorageProof: make([]StorageResult, 0, len(slots)),
+	}
+	// Empty accounts are not part of the trie, so they are proven absent
+	storageTrie := newTrie(nil)
+	if !s.Empty(address) {
+		account := s.getAccount(address)
+		result.CodeHash = crypto.Keccak256Hash(account.Code)
+		result.Nonce = hexutil.Uint64(account.Nonce)
+		result.StorageHash = s.StorageRoot(address)
+		storageTrie = newTrie(storageEntries(account))
+	}
+
+	for _, slot := range slots {
+		proof, err := prove(storageTrie, slot.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		value := new(uint256.Int).SetBytes(s.GetState(address, slot).Bytes())
+		result.StorageProof = append(result.StorageProof, StorageResult{
+			Key:   slot.Hex(),
+			Value: (*hexutil.Big)(value.ToBig()),
+			Proof: proof,
+		})
+	}
+	return result, nil
+}
+
+// verifyProof checks a proof against a trie root and returns the proven value,
+// nil if the proof shows that the key is absent
+func verifyProof(root common.Hash, key []byte, proof []string) ([]byte, error) {
+	// The empty trie holds no nodes, every key is absent from it
+	if root == gethtypes.EmptyRootHash && len(proof) == 0 {
+		return nil, nil
+	}
+	nodes := memorydb.New()
+	for _, encoded := range proof {
+		node, err := hexutil.Decode(encoded)
+		if err != nil {
+			return nil, err
+		}
+		nodes.Put(crypto.Keccak256(node), node)
+	}
+	return trie.VerifyProof(root, crypto.Keccak256(key), nodes)
+}
+
+// VerifyProof checks that an account proof and its storage proofs are valid
+// against the given state root and match the values they claim
+func VerifyProof(stateRoot common.Hash, result *AccountResult) error {
+	value, err := verifyProof(stateRoot, result.Address.Bytes(), result.AccountProof)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAccountProof, err)
+	}
+
+	var expected []byte
+	if result.Balance == nil {
+		return fmt.Errorf("%w: missing balance", ErrInvalidAccountProof)
+	}
+	balance, overflow := uint256.FromBig(result.Balance.ToInt())
+	if overflow {
+		return fmt.Errorf("%w: balance overflows 256 bits", ErrInvalidAccountProof)
+	}
+	account := &gethtypes.StateAccount{
+		Nonce:    uint64(result.Nonce),
+		Balance:  balance,
+		Root:     result.StorageHash,
+		CodeHash: result.CodeHash.Bytes(),
+	}
+	// An absent account is proven by an empty value
+	if account.Nonce != 0 || !balance.IsZero() || account.Root != gethtypes.EmptyRootHash || !bytes.Equal(account.CodeHash, gethtypes.EmptyCodeHash.Bytes()) {
+		expected, _ = rlp.EncodeToBytes(account)
+	}
+	if !bytes.Equal(value, expected) {
+		return fmt.Errorf("%w: account of %s does not match the proof", ErrInvalidAccountProof, result.Address.Hex())
+	}
+
+	for _, storage := range result.StorageProof {
+		key, err := hexutil.Decode(storage.Key)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidStorageProof, err)
+		}
+		value, err := verifyProof(result.StorageHash, common.BytesToHash(key).Bytes(), storage.Proof)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidStorageProof, err)
+		}
+
+		expected = nil
+		if storage.Value == nil {
+			return fmt.Errorf("%w: missing value of slot %s", ErrInvalidStorageProof, storage.Key)
+		}
+		if slot := storage.Value.ToInt(); slot.Sign() != 0 {
+			expected, _ = rlp.EncodeToBytes(slot.Bytes())
+		}
+		if !bytes.Equal(value, expected) {
+			return fmt.Errorf("%w: slot %s does not match the proof", ErrInvalidStorageProof, storage.Key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package types
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetProof(test *testing.T) {
+	state := NewStateDB()
+	contract := common.HexToAddress("0x2000")
+	state.SetNonce(contract, 1)
+	state.SetBalance(contract, uint256.NewInt(42))
+	state.SetCode(contract, common.FromHex("0x600160005500"))
+	state.SetState(contract, common.HexToHash("0x01"), common.HexToHash("0x2a"))
+	state.SetState(contract, common.HexToHash("0x02"), common.HexToHash("0xbeef"))
+	state.SetBalance(common.HexToAddress("0x1000"), uint256.NewInt(1))
+	root := state.IntermediateRoot()
+
+	tests := []struct {
+		name    string
+		address common.Address
+		slots   []common.Hash
+	}{
+		{"Existing account and slots", contract, []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")}},
+		{"Missing slot", contract, []common.Hash{common.HexToHash("0x03")}},
+		{"Missing account", common.HexToAddress("0x3000"), []common.Hash{common.HexToHash("0x01")}},
+	}
+	for _, tt := range tests {
+		test.Run(tt.name, func(test *testing.T) {
+			result, err := state.GetProof(tt.address, tt.slots)
+			require.NoError(test, err)
+			assert.NotEmpty(test, result.AccountProof)
+			assert.Len(test, result.StorageProof, len(tt.slots))
+			assert.NoError(test, VerifyProof(root, result))
+		})
+	}
+
+	test.Run("Proof values", func(test *testing.T) {
+		result, err := state.GetProof(contract, []common.Hash{common.HexToHash("0x01")})
+		require.NoError(test, err)
+		assert.Equal(test, hexutil.Uint64(1), result.Nonce)
+		assert.Equal(test, big.NewInt(42), result.Balance.ToInt())
+		assert.Equal(test, state.StorageRoot(contract), result.StorageHash)
+		assert.Equal(test, big.NewInt(0x2a), result.StorageProof[0].Value.ToInt())
+
+		encoded, err := json.Marshal(result)
+		require.NoError(test, err)
+		for _, field := range []string{"accountProof", "balance", "codeHash", "nonce", "storageHash", "storageProof"} {
+			assert.Contains(test, string(encoded), `"`+field+`"`)
+		}
+	})
+
+	test.Run("Tampered proofs are rejected", func(test *testing.T) {
+		result, err := state.GetProof(contract, []common.Hash{common.HexToHash("0x01")})
+		require.NoError(test, err)
+		assert.ErrorIs(test, VerifyProof(common.HexToHash("0x1234"), result), ErrInvalidAccountProof)
+
+		result.StorageProof[0].Value = (*hexutil.Big)(big.NewInt(0x2b))
+		assert.ErrorIs(test, VerifyProof(root, result), ErrInvalidStorageProof)
+
+		result.Balance = (*hexutil.Big)(big.NewInt(43))
+		assert.ErrorIs(test, VerifyProof(root, result), ErrInvalidAccountProof)
+	})
+
+	test.Run("Missing values are rejected", func(test *testing.T) {
+		result, err := state.GetProof(contract, []common.Hash{common.HexToHash("0x01")})
+		require.NoError(test, err)
+		result.StorageProof[0].Value = nil
+		assert.ErrorIs(test, VerifyProof(root, result), ErrInvalidStorageProof)
+
+		result.Balance = nil
+		assert.ErrorIs(test, VerifyProof(root, result), ErrInvalidAccountProof)
+	})
+
+	test.Run("Proof nodes cannot be deleted", func(test *testing.T) {
+		proof := make(proofList, 0)
+		require.NoError(test, proof.Put(nil, []byte{0x01}))
+		assert.Error(test, proof.Delete(nil))
+		assert.Len(test, proof, 1)
+	})
+}
@@ -13,48 +13,54 @@ var (
 	ErrStackUnderflow = errors.New("Stack Underflow") // ErrStackUnderflow is returned when the stack is empty
 )
 
-// Stack is a list of 32 bytes elements
+// initialStackCapacity is the capacity a new stack is allocated with. Few programs
+// go deeper, the stack grows on demand up to MAX_STACK_SIZE.
+const initialStackCapacity = 16
+
+// Stack is a list of 32 bytes elements. The elements are stored by value, so
+// pushing does not allocate.
 type Stack struct {
-	elem []*uint256.Int // A list of 32 bytes elements
+	elem []uint256.Int // A list of 32 bytes elements
 }
 
 func NewStack() *Stack {
 	return &Stack{
-		elem: make([]*uint256.Int, 0, MAX_STACK_SIZE), // Initialize the stack with a capacity of MAX_STACK_SIZE
+		elem: make([]uint256.Int, 0, initialStackCapacity),
 	}
 }
 
-// Push adds a new element to the stack
+// Push adds a copy of value to the stack
 func (stack *Stack) Push(value *uint256.Int) error {
 	if stack.Size() >= MAX_STACK_SIZE {
 		return ErrStackOverflow
 	}
-	stack.elem = append(stack.elem, value)
+	stack.elem = append(stack.elem, *value)
 	return nil
 }
 
-// Pop removes the last element from the stack
-func (stack *Stack) Pop() (*uint256.Int, error) {
+// Pop removes the last element from the stack and returns it
+func (stack *Stack) Pop() (uint256.Int, error) {
 	if stack.Size() == 0 {
-		return nil, ErrStackUnderflow
+		return uint256.Int{}, ErrStackUnderflow
 	}
 	value := stack.elem[stack.Size()-1]
 	stack.elem = stack.elem[:stack.Size()-1]
 	return value, nil
 }
 
-// Peek returns the last element from the stack without removing it
+// Peek returns the last element from the stack without removing it. The element
+// can be modified in place, the pointer is valid until the next push or pop.
 func (stack *Stack) Peek() (*uint256.Int, error) {
 	if stack.Size() == 0 {
 		return nil, ErrStackUnderflow
 	}
-	return stack.elem[stack.Size()-1], nil
+	return &stack.elem[stack.Size()-1], nil
 
 }
 
 // Swap swaps the n-th element from the top of the stack with the top element
 func (stack *Stack) Swap(n int) error {
-	if stack.Size() < 2 {
+	if stack.Size() < n+1 {
 		return ErrStackUnderflow
 	}
 	stack.elem[stack.Size()-1], stack.elem[stack.Size()-1-n] = stack.elem[stack.Size()-1-n], stack.elem[stack.Size()-1]
@@ -66,6 +72,9 @@ func (stack *Stack) Dup(n int) error {
 	if stack.Size() < n {
 		return ErrStackUnderflow
 	}
+	if stack.Size() >= MAX_STACK_SIZE {
+		return ErrStackOverflow
+	}
 	stack.elem = append(stack.elem, stack.elem[stack.Size()-n])
 	return nil
 }
@@ -90,12 +99,18 @@ func (stack *Stack) ToString() string {
 }
 
 // GetItem returns the n-th item from the top of the stack without removing it
-// n=0 is the top item, n=1 is the second item, etc.
+// n=0 is the top item, n=1 is the second item, etc. The pointer is valid until
+// the next push or pop.
 func (stack *Stack) GetItem(n int) (*uint256.Int, error) {
 	if stack.Size() <= n {
 		return nil, ErrStackUnderflow
 	}
-	return stack.elem[stack.Size()-1-n], nil
+	return &stack.elem[stack.Size()-1-n], nil
+}
+
+// Data returns the elements of the stack, from the bottom to the top
+func (stack *Stack) Data() []uint256.Int {
+	return stack.elem
 }
 
 // Size returns the current number of elements in the stack
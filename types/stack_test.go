@@ -83,6 +83,17 @@ func TestStack(t *testing.T) {
 			want: ErrStackUnderflow,
 			wantErr: true,
 		},
+		{
+			name: "Swap beyond the second element and Stack Underflow",
+			op: func(s *Stack) any {
+				s.Push(uint256.NewInt(32))
+				s.Push(uint256.NewInt(64))
+				err := s.Swap(2)
+				return err
+			},
+			want: ErrStackUnderflow,
+			wantErr: true,
+		},
 		{
 			name: "Duplicate",
 			op: func(s *Stack) any {
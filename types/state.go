@@ -0,0 +1,336 @@
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+// EmptyCodeHash is the Keccak-256 hash of empty code
+var EmptyCodeHash = crypto.Keccak256Hash(nil)
+
+// Account is the state of a single address in the world state
+type Account struct {
+	Nonce   uint64
+	Balance *uint256.Int
+	Code    []byte
+	Storage *Storage
+}
+
+// StateDB is the in-memory world state. Every modification is recorded in a
+// journal so that the state can be rolled back to an earlier snapshot.
+type StateDB struct {
+	accounts   map[common.Address]*Account
+	accessList *AccessList
+	logs       []*Log
+	journal    *journal
+
+	transientStorage map[common.Address]*TransientStorage // EIP-1153 storage, cleared after every transaction
+	newContracts     map[common.Address]bool              // Contracts created by the current transaction
+	destructed       map[common.Address]bool              // Accounts deleted at the end of the transaction (EIP-6780)
+}
+
+// NewStateDB creates an empty world state
+func NewStateDB() *StateDB {
+	return &StateDB{
+		accounts:         make(map[common.Address]*Account),
+		accessList:       NewAccessList(),
+		journal:          newJournal(),
+		transientStorage: make(map[common.Address]*TransientStorage),
+		newContracts:     make(map[common.Address]bool),
+		destructed:       make(map[common.Address]bool),
+	}
+}
+
+// getAccount returns the account at the given address, or nil if it does not exist
+func (s *StateDB) getAccount(address common.Address) *Account {
+	return s.accounts[address]
+}
+
+// getOrNewAccount returns the account at the given address, creating it if needed
+func (s *StateDB) getOrNewAccount(address common.Address) *Account {
+	account := s.accounts[address]
+	if account == nil {
+		account = s.createAccount(address)
+	}
+	return account
+}
+
+func (s *StateDB) createAccount(address common.Address) *Account {
+	storage := NewStorage()
+	storage.journal = s.journal
+	storage.owner = address
+
+	account := &Account{
+		Balance: uint256.NewInt(0),
+		Storage: storage,
+	}
+	s.accounts[address] = account
+	s.journal.append(createAccountChange{address: address})
+	return account
+}
+
+// CreateAccount creates an empty account at the given address if it does not exist yet
+func (s *StateDB) CreateAccount(address common.Address) {
+	s.getOrNewAccount(address)
+}
+
+// Exist reports whether an account exists at the given address
+func (s *StateDB) Exist(address common.Address) bool {
+	return s.getAccount(address) != nil
+}
+
+// Empty reports whether the account is missing or has zero nonce, zero balance and no code (EIP-161)
+func (s *StateDB) Empty(address common.Address) bool {
+	account := s.getAccount(address)
+	return account == nil || (account.Nonce == 0 && account.Balance.IsZero() && len(account.Code) == 0)
+}
+
+// ===== Balance =====
+
+// GetBalance returns the balance of the given address, zero if the account does not exist
+func (s *StateDB) GetBalance(address common.Address) *uint256.Int {
+	account := s.getAccount(address)
+	if account == nil {
+		return uint256.NewInt(0)
+	}
+	return new(uint256.Int).Set(account.Balance)
+}
+
+// SetBalance sets the balance of the given address
+func (s *StateDB) SetBalance(address common.Address, amount *uint256.Int) {
+	account := s.getOrNewAccount(address)
+	s.journal.append(balanceChange{address: address, prev: account.Balance})
+	account.Balance = new(uint256.Int).Set(amount)
+}
+
+// AddBalance adds amount to the balance of the given address
+func (s *StateDB) AddBalance(address common.Address, amount *uint256.Int) {
+	s.SetBalance(address, new(uint256.Int).Add(s.GetBalance(address), amount))
+}
+
+// SubBalance subtracts amount from the balance of the given address.
+// The caller is responsible for checking that the balance is sufficient.
+func (s *StateDB) SubBalance(address common.Address, amount *uint256.Int) {
+	s.SetBalance(address, new(uint256.Int).Sub(s.GetBalance(address), amount))
+}
+
+// ===== Nonce =====
+
+// GetNonce returns the nonce of the given address, zero if the account does not exist
+func (s *StateDB) GetNonce(address common.Address) uint64 {
+	account := s.getAccount(address)
+	if account == nil {
+		return 0
+	}
+	return account.Nonce
+}
+
+// SetNonce sets the nonce of the given address
+func (s *StateDB) SetNonce(address common.Address, nonce uint64) {
+	account := s.getOrNewAccount(address)
+	s.journal.append(nonceChange{address: address, prev: account.Nonce})
+	account.Nonce = nonce
+}
+
+// ===== Code =====
+
+// GetCode returns the code of the given address
+func (s *StateDB) GetCode(address common.Address) []byte {
+	account := s.getAccount(address)
+	if account == nil {
+		return nil
+	}
+	return account.Code
+}
+
+// GetCodeSize returns the size of the code of the given address
+func (s *StateDB) GetCodeSize(address common.Address) int {
+	return len(s.GetCode(address))
+}
+
+// GetCodeHash returns the Keccak-256 hash of the code of the given address.
+// Non-existent accounts return the zero hash.
+func (s *StateDB) GetCodeHash(address common.Address) common.Hash {
+	account := s.getAccount(address)
+	if account == nil {
+		return common.Hash{}
+	}
+	return crypto.Keccak256Hash(account.Code)
+}
+
+// SetCode sets the code of the given address
+func (s *StateDB) SetCode(address common.Address, code []byte) {
+	account := s.getOrNewAccount(address)
+	s.journal.append(codeChange{address: address, prev: account.Code})
+	account.Code = code
+}
+
+// ===== Storage =====
+
+// GetStorage returns the storage of the given address, creating the account if needed.
+// Writes through the returned Storage are journaled.
+func (s *StateDB) GetStorage(address common.Address) *Storage {
+	return s.getOrNewAccount(address).Storage
+}
+
+// GetState returns the value of a storage slot of the given address
+func (s *StateDB) GetState(address common.Address, key common.Hash) common.Hash {
+	account := s.getAccount(address)
+	if account == nil {
+		return common.Hash{}
+	}
+	return common.BytesToHash(account.Storage.Sload(key))
+}
+
+// GetCommittedState returns the value a storage slot of the given address had at
+// the start of the current transaction, before its writes (EIP-2200)
+func (s *StateDB) GetCommittedState(address common.Address, key common.Hash) common.Hash {
+	if value, ok := s.journal.original(address, key); ok {
+		return common.BytesToHash(value)
+	}
+	return s.GetState(address, key)
+}
+
+// SetState sets the value of a storage slot of the given address
+func (s *StateDB) SetState(address common.Address, key, value common.Hash) {
+	s.GetStorage(address).Sstore(key, value.Bytes())
+}
+
+// ===== Transient storage =====
+
+// GetTransientState returns the value of a transient storage slot of the given address (EIP-1153)
+func (s *StateDB) GetTransientState(address common.Address, key common.Hash) common.Hash {
+	storage := s.transientStorage[address]
+	if storage == nil {
+		return common.Hash{}
+	}
+	return common.BytesToHash(storage.Tload(key))
+}
+
+// SetTransientState sets the value of a transient storage slot of the given address
+func (s *StateDB) SetTransientState(address common.Address, key, value common.Hash) {
+	storage := s.transientStorage[address]
+	if storage == nil {
+		storage = NewTransientStorage()
+		s.transientStorage[address] = storage
+	}
+	s.journal.append(transientStorageChange{address: address, key: key, prev: storage.Tload(key)})
+	storage.Tstore(key, value.Bytes())
+}
+
+// ===== Contract lifecycle =====
+
+// CreateContract marks the account at the given address as a contract created by
+// the current transaction, which SELFDESTRUCT deletes (EIP-6780)
+func (s *StateDB) CreateContract(address common.Address) {
+	if !s.newContracts[address] {
+		s.newContracts[address] = true
+		s.journal.append(createContractChange{address: address})
+	}
+}
+
+// IsNewContract reports whether the contract was created by the current transaction
+func (s *StateDB) IsNewContract(address common.Address) bool {
+	return s.newContracts[address]
+}
+
+// SelfDestruct clears the balance of the account and deletes it at the end of the
+// transaction. Only contracts created by the current transaction can be deleted.
+func (s *StateDB) SelfDestruct(address common.Address) {
+	s.SetBalance(address, uint256.NewInt(0))
+	if !s.destructed[address] {
+		s.destructed[address] = true
+		s.journal.append(selfDestructChange{address: address})
+	}
+}
+
+// HasSelfDestructed reports whether the account is deleted at the end of the transaction
+func (s *StateDB) HasSelfDestructed(address common.Address) bool {
+	return s.destructed[address]
+}
+
+// Finalise ends the current transaction: the self-destructed accounts are deleted
+// and the transient storage is cleared
+func (s *StateDB) Finalise() {
+	for address := range s.destructed {
+		s.journal.append(deleteAccountChange{address: address, prev: s.accounts[address]})
+		delete(s.accounts, address)
+	}
+	s.transientStorage = make(map[common.Address]*TransientStorage)
+	s.newContracts = make(map[common.Address]bool)
+	s.destructed = make(map[common.Address]bool)
+}
+
+// ===== Access list =====
+
+// AddressInAccessList returns true if the address is warm
+func (s *StateDB) AddressInAccessList(address common.Address) bool {
+	return s.accessList.ContainsAddress(address)
+}
+
+// SlotInAccessList returns whether the address and the slot are warm
+func (s *StateDB) SlotInAccessList(address common.Address, slot common.Hash) (addressOk bool, slotOk bool) {
+	return s.accessList.Contains(address, slot)
+}
+
+// AddAddressToAccessList warms the given address
+func (s *StateDB) AddAddressToAccessList(address common.Address) {
+	if s.accessList.AddAddress(address) {
+		s.journal.append(accessListAddAccountChange{address: address})
+	}
+}
+
+// AddSlotToAccessList warms the given address and storage slot
+func (s *StateDB) AddSlotToAccessList(address common.Address, slot common.Hash) {
+	addrChange, slotChange := s.accessList.AddSlot(address, slot)
+	if addrChange {
+		s.journal.append(accessListAddAccountChange{address: address})
+	}
+	if slotChange {
+		s.journal.append(accessListAddSlotChange{address: address, slot: slot})
+	}
+}
+
+// ResetAccessList clears the access list. It is called at the start of every transaction.
+func (s *StateDB) ResetAccessList() {
+	s.accessList = NewAccessList()
+}
+
+// ===== Logs =====
+
+// AddLog records a log emitted by the current transaction
+func (s *StateDB) AddLog(log *Log) {
+	s.journal.append(addLogChange{})
+	s.logs = append(s.logs, log)
+}
+
+// Logs returns the logs emitted by the current transaction
+func (s *StateDB) Logs() []*Log {
+	return s.logs
+}
+
+// ResetLogs clears the logs. It is called at the start of every transaction.
+func (s *StateDB) ResetLogs() {
+	s.logs = nil
+}
+
+// ===== Snapshots =====
+
+// Snapshot returns an identifier for the current revision of the state
+func (s *StateDB) Snapshot() int {
+	return s.journal.length()
+}
+
+// RevertToSnapshot reverts all state changes made since the given revision
+func (s *StateDB) RevertToSnapshot(revision int) {
+	s.journal.revert(s, revision)
+}
+
+// ResetJournal commits all state changes made so far: revisions taken before it
+// can no longer be reverted to, and the current storage values become the
+// committed ones. It is called at the start of every transaction, since the logs
+// and access list are reset without being journaled.
+func (s *StateDB) ResetJournal() {
+	s.journal.reset()
+}
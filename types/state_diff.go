@@ -0,0 +1,149 @@
+package types
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/holiman/uint256"
+)
+
+// DiffAccount is the state of an account on one side of a StateDiff
+type DiffAccount struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   uint64                      `json:"nonce,omitempty"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// StateDiff lists the accounts modified between two points of execution, in the
+// shape of the prestate tracer in diff mode. Pre holds the full account before
+// the changes together with the original value of the modified slots, it omits
+// created accounts. Post only holds the modified fields and the new non-zero
+// slot values.
+type StateDiff struct {
+	Pre  map[common.Address]*DiffAccount `json:"pre"`
+	Post map[common.Address]*DiffAccount `json:"post"`
+}
+
+// accountOrigin is the original state of an account, recorded from the first
+// journal entry that modified each of its fields
+type accountOrigin struct {
+	created  bool
+	balance  *uint256.Int
+	nonce    *uint64
+	code     []byte
+	codeSeen bool
+	storage  map[common.Hash]common.Hash
+}
+
+// Diff returns the changes made to the state since the given revision, as
+// returned by Snapshot. It is computed from the journal, so the revision must
+// not have been reverted. Revisions from before the current transaction cover
+// its changes only.
+func (s *StateDB) Diff(revision int) *StateDiff {
+	origins := make(map[common.Address]*accountOrigin)
+	origin := func(address common.Address) *accountOrigin {
+		if origins[address] == nil {
+			origins[address] = &accountOrigin{storage: make(map[common.Hash]common.Hash)}
+		}
+		return origins[address]
+	}
+
+	// The first entry touching a field holds its value at the revision
+	for _, entry := range s.journal.since(revision) {
+		switch change := entry.(type) {
+		case createAccountChange:
+			origin(change.address).created = true
+		case balanceChange:
+			if o := origin(change.address); o.balance == nil {
+				o.balance = change.prev
+			}
+		case nonceChange:
+			if o := origin(change.address); o.nonce == nil {
+				prev := change.prev
+				o.nonce = &prev
+			}
+		case codeChange:
+			if o := origin(change.address); !o.codeSeen {
+				o.code, o.codeSeen = change.prev, true
+			}
+		case storageChange:
+			if o := origin(change.address); !hasKey(o.storage, change.key) {
+				o.storage[change.key] = common.BytesToHash(change.prev)
+			}
+		}
+	}
+
+	diff := &StateDiff{
+		Pre:  make(map[common.Address]*DiffAccount),
+		Post: make(map[common.Address]*DiffAccount),
+	}
+	for address, o := range origins {
+		account := s.getAccount(address)
+		// Accounts created empty are deleted at the end of the transaction (EIP-161)
+		if account == nil || (o.created && s.Empty(address)) {
+			continue
+		}
+
+		pre := &DiffAccount{
+			Balance: (*hexutil.Big)(account.Balance.ToBig()),
+			Nonce:   account.Nonce,
+			Code:    account.Code,
+		}
+		if o.balance != nil {
+			pre.Balance = (*hexutil.Big)(o.balance.ToBig())
+		}
+		if o.nonce != nil {
+			pre.Nonce = *o.nonce
+		}
+		if o.codeSeen {
+			pre.Code = o.code
+		}
+
+		post := &DiffAccount{}
+		modified := false
+		if o.created || pre.Balance.ToInt().Cmp(account.Balance.ToBig()) != 0 {
+			post.Balance, modified = (*hexutil.Big)(account.Balance.ToBig()), true
+		}
+		if o.created || pre.Nonce != account.Nonce {
+			post.Nonce, modified = account.Nonce, true
+		}
+		if o.created || !bytes.Equal(pre.Code, account.Code) {
+			post.Code, modified = account.Code, true
+		}
+		for key, prev := range o.storage {
+			current := common.BytesToHash(account.Storage.Sload(key))
+			if current == prev {
+				continue
+			}
+			modified = true
+			if prev != (common.Hash{}) {
+				if pre.Storage == nil {
+					pre.Storage = make(map[common.Hash]common.Hash)
+				}
+				pre.Storage[key] = prev
+			}
+			if current != (common.Hash{}) {
+				if post.Storage == nil {
+					post.Storage = make(map[common.Hash]common.Hash)
+				}
+				post.Storage[key] = current
+			}
+		}
+
+		if !modified {
+			continue
+		}
+		if !o.created {
+			diff.Pre[address] = pre
+		}
+		diff.Post[address] = post
+	}
+	return diff
+}
+
+func hasKey(storage map[common.Hash]common.Hash, key common.Hash) bool {
+	_, ok := storage[key]
+	return ok
+}
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateDiff(test *testing.T) {
+	sender := common.HexToAddress("0x1000")
+	contract := common.HexToAddress("0x2000")
+	created := common.HexToAddress("0x3000")
+	untouched := common.HexToAddress("0x4000")
+	slot1, slot2, slot3 := common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")
+
+	state := NewStateDB()
+	state.SetBalance(sender, uint256.NewInt(100))
+	state.SetCode(contract, common.FromHex("0x00"))
+	state.SetState(contract, slot1, common.HexToHash("0x01"))
+	state.SetState(contract, slot2, common.HexToHash("0x02"))
+	state.SetBalance(untouched, uint256.NewInt(1))
+	revision := state.Snapshot()
+
+	state.SetNonce(sender, 1)
+	state.SubBalance(sender, uint256.NewInt(30))
+	state.SetState(contract, slot1, common.HexToHash("0x0a")) // modified
+	state.SetState(contract, slot2, common.Hash{})            // cleared
+	state.SetState(contract, slot3, common.HexToHash("0x03")) // new
+	state.SetState(contract, slot3, common.HexToHash("0x04"))
+	state.AddBalance(created, uint256.NewInt(30))
+	state.SetBalance(untouched, uint256.NewInt(1))     // written back unchanged
+	state.CreateAccount(common.HexToAddress("0x5000")) // created empty
+
+	diff := state.Diff(revision)
+	assert.Len(test, diff.Pre, 2)
+	assert.Len(test, diff.Post, 3)
+
+	assert.Equal(test, big.NewInt(100), diff.Pre[sender].Balance.ToInt())
+	assert.Equal(test, uint64(0), diff.Pre[sender].Nonce)
+	assert.Equal(test, big.NewInt(70), diff.Post[sender].Balance.ToInt())
+	assert.Equal(test, uint64(1), diff.Post[sender].Nonce)
+
+	assert.Equal(test, map[common.Hash]common.Hash{slot1: common.HexToHash("0x01"), slot2: common.HexToHash("0x02")}, diff.Pre[contract].Storage)
+	assert.Equal(test, map[common.Hash]common.Hash{slot1: common.HexToHash("0x0a"), slot3: common.HexToHash("0x04")}, diff.Post[contract].Storage)
+	assert.Nil(test, diff.Post[contract].Balance)
+	assert.Nil(test, diff.Post[contract].Code)
+
+	assert.NotContains(test, diff.Pre, created)
+	assert.Equal(test, big.NewInt(30), diff.Post[created].Balance.ToInt())
+
+	encoded, err := json.Marshal(diff)
+	require.NoError(test, err)
+	assert.Contains(test, string(encoded), `"pre":`)
+	assert.Contains(test, string(encoded), `"post":`)
+}
+
+func TestStateDiffAfterReset(test *testing.T) {
+	account := common.HexToAddress("0x1000")
+	state := NewStateDB()
+	revision := state.Snapshot()
+	state.SetNonce(account, 1)
+	state.ResetJournal()
+	state.SetNonce(account, 2)
+
+	// Changes from before the reset are committed
+	diff := state.Diff(revision)
+	assert.Equal(test, uint64(1), diff.Pre[account].Nonce)
+	assert.Equal(test, uint64(2), diff.Post[account].Nonce)
+
+	state.RevertToSnapshot(revision)
+	assert.Equal(test, uint64(2), state.GetNonce(account))
+	state.RevertToSnapshot(state.Snapshot() - 1)
+	assert.Equal(test, uint64(1), state.GetNonce(account))
+}
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// trieEntry is a key/value pair of a secure trie, keyed by the Keccak-256 hash of the original key
+type trieEntry struct {
+	key   []byte
+	value []byte
+}
+
+// trieRoot computes the Merkle-Patricia root of the given entries. The stack trie
+// requires the keys to be inserted in ascending order.
+func trieRoot(entries []trieEntry) common.Hash {
+	if len(entries) == 0 {
+		return gethtypes.EmptyRootHash
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	stackTrie := trie.NewStackTrie(nil)
+	for _, entry := range entries {
+		// Keys are hashes of equal length, so the insertion cannot fail
+		stackTrie.Update(entry.key, entry.value)
+	}
+	return stackTrie.Hash()
+}
+
+// storageEntries returns the secure trie entries of the storage of an account.
+// Zero slots are not part of the trie.
+func storageEntries(account *Account) []trieEntry {
+	entries := make([]trieEntry, 0, len(account.Storage.elem))
+	for key, value := range account.Storage.elem {
+		value = common.TrimLeftZeroes(value)
+		if len(value) == 0 {
+			continue
+		}
+		encoded, _ := rlp.EncodeToBytes(value)
+		entries = append(entries, trieEntry{key: crypto.Keccak256(key.Bytes()), value: encoded})
+	}
+	return entries
+}
+
+// accountEntries returns the secure trie entries of all accounts. Empty accounts
+// are left out of the trie, as they would be deleted at the end of the
+// transaction that touched them (EIP-161).
+func (s *StateDB) accountEntries() []trieEntry {
+	entries := make([]trieEntry, 0, len(s.accounts))
+	for address := range s.accounts {
+		if s.Empty(address) {
+			continue
+		}
+		encoded, _ := rlp.EncodeToBytes(s.stateAccount(address))
+		entries = append(entries, trieEntry{key: crypto.Keccak256(address.Bytes()), value: encoded})
+	}
+	return entries
+}
+
+// stateAccount returns the consensus representation of an account
+func (s *StateDB) stateAccount(address common.Address) *gethtypes.StateAccount {
+	account := s.getAccount(address)
+	return &gethtypes.StateAccount{
+		Nonce:    account.Nonce,
+		Balance:  account.Balance,
+		Root:     s.StorageRoot(address),
+		CodeHash: crypto.Keccak256(account.Code),
+	}
+}
+
+// StorageRoot returns the root of the storage trie of the given address
+func (s *StateDB) StorageRoot(address common.Address) common.Hash {
+	account := s.getAccount(address)
+	if account == nil {
+		return gethtypes.EmptyRootHash
+	}
+	return trieRoot(storageEntries(account))
+}
+
+// IntermediateRoot computes the root of the account trie of the current state
+func (s *StateDB) IntermediateRoot() common.Hash {
+	return trieRoot(s.accountEntries())
+}
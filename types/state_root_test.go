@@ -0,0 +1,43 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntermediateRoot(test *testing.T) {
+	assert.Equal(test, gethtypes.EmptyRootHash, NewStateDB().IntermediateRoot())
+
+	// The root must match the one computed by go-ethereum for the same accounts
+	reference, _ := state.New(gethtypes.EmptyRootHash, state.NewDatabaseForTesting())
+	ours := NewStateDB()
+
+	eoa := common.HexToAddress("0x1000")
+	contract := common.HexToAddress("0x2000")
+	empty := common.HexToAddress("0x3000")
+	reference.SetNonce(eoa, 7, tracing.NonceChangeUnspecified)
+	reference.SetBalance(eoa, uint256.NewInt(1_000_000), tracing.BalanceChangeUnspecified)
+	reference.SetNonce(contract, 1, tracing.NonceChangeUnspecified)
+	reference.SetCode(contract, common.FromHex("0x600160005500"))
+	reference.SetState(contract, common.HexToHash("0x01"), common.HexToHash("0x2a"))
+	reference.SetState(contract, common.HexToHash("0xff"), common.HexToHash("0xdeadbeef"))
+
+	ours.SetNonce(eoa, 7)
+	ours.SetBalance(eoa, uint256.NewInt(1_000_000))
+	ours.SetNonce(contract, 1)
+	ours.SetCode(contract, common.FromHex("0x600160005500"))
+	ours.SetState(contract, common.HexToHash("0x01"), common.HexToHash("0x2a"))
+	ours.SetState(contract, common.HexToHash("0xff"), common.HexToHash("0xdeadbeef"))
+	// Zero slots and empty accounts are not part of the tries
+	ours.SetState(contract, common.HexToHash("0x02"), common.Hash{})
+	ours.CreateAccount(empty)
+
+	assert.Equal(test, reference.IntermediateRoot(true), ours.IntermediateRoot())
+	assert.Equal(test, reference.GetStorageRoot(contract), ours.StorageRoot(contract))
+}
@@ -6,6 +6,11 @@ import (
 
 type Storage struct {
 	elem map[common.Hash][]byte
+
+	// journal and owner are set when the storage belongs to an account of a
+	// StateDB, so that writes can be reverted
+	journal *journal
+	owner   common.Address
 }
 
 func NewStorage() *Storage {
@@ -26,5 +31,20 @@ func (s *Storage) Sstore(key common.Hash, value []byte) {
 	if value == nil {
 		return
 	}
+	if s.journal != nil {
+		prev := s.Sload(key)
+		s.journal.append(storageChange{address: s.owner, key: key, prev: prev})
+		s.journal.recordOriginal(s.owner, key, prev)
+	}
+	s.elem[key] = value
+}
+
+// restore sets a slot back to a previous value without journaling it.
+// A nil value removes the slot.
+func (s *Storage) restore(key common.Hash, value []byte) {
+	if value == nil {
+		delete(s.elem, key)
+		return
+	}
 	s.elem[key] = value
 }
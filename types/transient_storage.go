@@ -26,3 +26,12 @@ func (ts *TransientStorage) Tstore(key common.Hash, value []byte) {
 	}
 	ts.data[key] = value
 }
+
+// restore sets a slot back to a previous value. A nil value removes the slot.
+func (ts *TransientStorage) restore(key common.Hash, value []byte) {
+	if value == nil {
+		delete(ts.data, key)
+		return
+	}
+	ts.data[key] = value
+}